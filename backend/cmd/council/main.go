@@ -19,9 +19,14 @@ import (
 	"github.com/sainaif/council/internal/middleware"
 	"github.com/sainaif/council/internal/routes"
 	"github.com/sainaif/council/internal/services/auth"
+	"github.com/sainaif/council/internal/services/classifier"
 	"github.com/sainaif/council/internal/services/copilot"
 	"github.com/sainaif/council/internal/services/council"
 	"github.com/sainaif/council/internal/services/elo"
+	"github.com/sainaif/council/internal/services/judge"
+	"github.com/sainaif/council/internal/services/lifecycle"
+	"github.com/sainaif/council/internal/services/snapshot"
+	"github.com/sainaif/council/internal/store"
 	"github.com/sainaif/council/internal/websocket"
 )
 
@@ -40,14 +45,23 @@ func main() {
 	log.Printf("Configuration loaded:")
 	log.Printf("  Environment: %s", cfg.Env)
 	log.Printf("  Port: %s", cfg.Port)
-	log.Printf("  Database: %s", cfg.DatabasePath)
+	if cfg.DatabaseURL != "" {
+		log.Printf("  Database: PostgreSQL (DATABASE_URL)")
+	} else {
+		log.Printf("  Database: %s", cfg.DatabasePath)
+	}
 	log.Printf("  Frontend URL: %s", cfg.FrontendURL)
 	log.Printf("  OAuth Callback: %s", cfg.OAuthCallbackURL())
 	log.Printf("  Log Level: %s", cfg.LogLevel)
 	log.Printf("  GitHub OAuth: %s...", cfg.GitHubClientID[:min(8, len(cfg.GitHubClientID))])
 
 	// Initialize database
-	db, err := database.New(cfg.DatabasePath)
+	var db *database.DB
+	if cfg.DatabaseURL != "" {
+		db, err = database.NewPostgres(cfg.DatabaseURL)
+	} else {
+		db, err = database.New(cfg.DatabasePath)
+	}
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -67,24 +81,75 @@ func main() {
 	// Initialize services
 	log.Println("Initializing services...")
 	authService := auth.NewGitHubAuth(cfg)
+	tokenVault, err := auth.NewVault(db, authService, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize token vault: %v", err)
+	}
+	apiTokens := auth.NewAPITokens(db)
+	roleService := auth.NewRoleService(db, authService, cfg)
+	accessPolicy := auth.NewAccessPolicy(db, authService, roleService, cfg)
 	copilotService := copilot.NewService()
 	log.Println("Copilot service initialized (per-user authentication via OAuth)")
 
-	eloService := elo.NewCalculator(db)
-	wsHub := websocket.NewHub()
-	councilService := council.NewOrchestrator(db, copilotService, eloService, wsHub)
+	eloService := elo.NewCalculator(db, cfg.Elo)
+	judgeTracker := judge.NewTracker(db)
+	categoryClassifier := classifier.NewService(db, copilotService, cfg.Classifier)
+	leases := database.NewLeases(db, cfg.InstanceID)
+	broker := websocket.Broker(websocket.NewMemoryBroker())
+	if cfg.BrokerURL != "" {
+		redisBroker, err := websocket.NewRedisBroker(context.Background(), cfg.BrokerURL, cfg.InstanceID)
+		if err != nil {
+			log.Fatalf("Failed to connect to event broker: %v", err)
+		}
+		broker = redisBroker
+		log.Printf("Relaying events between instances as %s", cfg.InstanceID)
+	}
+	sessionStore := store.NewSessionStore(db)
+	eventLog := websocket.NewEventLog(db, cfg.EventBufferSize, time.Duration(cfg.EventRetentionDays)*24*time.Hour)
+	wsHub := websocket.NewHub(eventLog, sessionStore, websocket.HubConfig{
+		PingInterval:         time.Duration(cfg.WSPingInterval) * time.Second,
+		PongTimeout:          time.Duration(cfg.WSPongTimeout) * time.Second,
+		MaxClientsPerUser:    cfg.WSMaxConnectionsPerUser,
+		MaxClientsPerSession: cfg.WSMaxConnectionsPerSession,
+		Broker:               broker,
+	})
+	modelStore := store.NewModelStore(db, cfg.Elo.Default.InitialRating)
+	categoryStore := store.NewCategoryStore(db)
+	settingsStore := store.NewSettingsStore(db)
+	userStore := store.NewUserStore(db)
+	councilService := council.NewOrchestrator(leases, sessionStore, modelStore, categoryStore, userStore, copilotService, eloService, judgeTracker, categoryClassifier, wsHub)
 
 	// Start WebSocket hub
 	go wsHub.Run()
 	log.Println("WebSocket hub started")
 
+	// Start failing sessions left behind by stopped instances
+	go councilService.Run()
+
+	// Start daily leaderboard snapshots
+	snapshotService := snapshot.NewService(db, leases)
+	go snapshotService.Run()
+	log.Println("Leaderboard snapshots started")
+
+	// Start model retirement and rating decay
+	lifecycleService := lifecycle.NewService(db, leases, eloService, cfg.ModelRetireAfterDays)
+	go lifecycleService.Run()
+	log.Println("Model lifecycle maintenance started")
+
+	// Auth middleware
+	authMiddleware := middleware.NewAuthMiddleware(cfg.SessionSecret, tokenVault, apiTokens, roleService, accessPolicy)
+
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(authService, db, cfg)
-	councilHandler := handlers.NewCouncilHandler(councilService, db)
-	modelHandler := handlers.NewModelHandler(db, copilotService)
-	rankingHandler := handlers.NewRankingHandler(db)
-	analyticsHandler := handlers.NewAnalyticsHandler(db)
-	settingsHandler := handlers.NewSettingsHandler(db)
+	authHandler := handlers.NewAuthHandler(authService, tokenVault, roleService, accessPolicy, userStore, settingsStore, cfg)
+	councilHandler := handlers.NewCouncilHandler(councilService, sessionStore, categoryStore, authMiddleware)
+	modelHandler := handlers.NewModelHandler(modelStore, copilotService, lifecycleService)
+	rankingHandler := handlers.NewRankingHandler(store.NewRatingStore(db, cfg.Elo.Default.InitialRating), modelStore, categoryStore, settingsStore, judgeTracker)
+	analyticsHandler := handlers.NewAnalyticsHandler(store.NewAnalyticsStore(db, cfg.Elo.Default.InitialRating))
+	settingsHandler := handlers.NewSettingsHandler(settingsStore)
+	categoryHandler := handlers.NewCategoryHandler(categoryStore)
+	userHandler := handlers.NewUserHandler(userStore, roleService)
+	tokenHandler := handlers.NewTokenHandler(apiTokens)
+	hubHandler := handlers.NewHubHandler(wsHub)
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
@@ -110,9 +175,6 @@ func main() {
 		AllowCredentials: true,
 	}))
 
-	// Auth middleware
-	authMiddleware := middleware.NewAuthMiddleware(cfg.SessionSecret)
-
 	// Setup routes
 	routes.Setup(app, routes.Handlers{
 		Auth:      authHandler,
@@ -121,7 +183,11 @@ func main() {
 		Ranking:   rankingHandler,
 		Analytics: analyticsHandler,
 		Settings:  settingsHandler,
-	}, authMiddleware, wsHub)
+		Category:  categoryHandler,
+		User:      userHandler,
+		Token:     tokenHandler,
+		Hub:       hubHandler,
+	}, authMiddleware)
 
 	// Serve static frontend files in production
 	if !cfg.IsDev {
@@ -143,6 +209,15 @@ func main() {
 		// Stop WebSocket hub
 		wsHub.Shutdown()
 
+		// Stop looking for orphaned sessions
+		councilService.Shutdown()
+
+		// Stop leaderboard snapshots
+		snapshotService.Shutdown()
+
+		// Stop model lifecycle maintenance
+		lifecycleService.Shutdown()
+
 		// Close Copilot sessions
 		copilotService.Shutdown()
 
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/joho/godotenv"
@@ -22,8 +23,14 @@ type Config struct {
 	// Session
 	SessionSecret string
 
+	// Key for encrypting stored GitHub tokens; derived from SessionSecret
+	// when unset
+	TokenEncryptionKey string
+
 	// Database
 	DatabasePath string
+	// PostgreSQL connection URL; when set it is used instead of DatabasePath
+	DatabaseURL string
 
 	// Server
 	Port string
@@ -39,6 +46,84 @@ type Config struct {
 
 	// Logging
 	LogLevel string
+
+	// Rating system
+	Elo EloConfig
+
+	// Models missing from Copilot's model list for this many days are
+	// marked inactive (0 disables)
+	ModelRetireAfterDays int
+
+	// Session events kept in memory per session for WebSocket replay, and
+	// days persisted events are kept (0 keeps them forever)
+	EventBufferSize    int
+	EventRetentionDays int
+
+	// WebSocket heartbeats: seconds between pings, and seconds without a
+	// pong or message before a connection is closed
+	WSPingInterval int
+	WSPongTimeout  int
+	// Live event connections allowed per user and per session (0 is unlimited)
+	WSMaxConnectionsPerUser    int
+	WSMaxConnectionsPerSession int
+
+	// Running several instances: the broker relaying live events between
+	// them ("redis://..."; empty keeps events in this process) and the name
+	// this instance claims jobs under
+	BrokerURL  string
+	InstanceID string
+
+	// Category classification for sessions started without a category
+	Classifier ClassifierConfig
+
+	// Access control: bootstrap admins by GitHub username or by membership
+	// of an org ("org") or team ("org/team"), and the role of everyone else
+	AdminUsers  []string
+	AdminTeams  []string
+	DefaultRole string // "member" or "viewer"
+
+	// Sign-in allowlist: when either list is set, only these usernames and
+	// members of these orgs ("org") or teams ("org/team") may sign in.
+	// Membership is rechecked every AccessRecheckHours.
+	AllowedUsers       []string
+	AllowedOrgs        []string
+	AccessRecheckHours int
+}
+
+// ClassifierConfig selects how uncategorized questions are assigned a category
+type ClassifierConfig struct {
+	Mode          string  // "off", "keyword" or "model"
+	Model         string  // Copilot model used in "model" mode
+	MinConfidence float64 // Results below this leave the session uncategorized
+}
+
+// EloParams holds the tunable parameters of the ELO rating system
+type EloParams struct {
+	InitialRating    int `json:"initial_rating"`
+	KFactorNew       int `json:"k_factor_new"`    // Players with < ProvisionalGames games
+	KFactorNormal    int `json:"k_factor_normal"` // Regular players
+	KFactorPro       int `json:"k_factor_pro"`    // Top performers (rating > ProThreshold)
+	ProvisionalGames int `json:"provisional_games"`
+	ProThreshold     int `json:"pro_threshold"`
+}
+
+// EloConfig holds the deployment-wide rating parameters and per-category overrides
+type EloConfig struct {
+	Default    EloParams
+	Categories map[string]EloParams // key: category name
+
+	// Inactivity decay: after DecayAfterDays without games, a rating moves
+	// DecayRate of the way back to the initial rating per day (0 disables)
+	DecayAfterDays int
+	DecayRate      float64
+}
+
+// ForCategory returns the parameters for a category, falling back to the defaults
+func (e EloConfig) ForCategory(name string) EloParams {
+	if p, ok := e.Categories[name]; ok {
+		return p
+	}
+	return e.Default
 }
 
 func Load() (*Config, error) {
@@ -51,6 +136,7 @@ func Load() (*Config, error) {
 		GitHubClientID:     getEnv("GITHUB_CLIENT_ID", DefaultGitHubClientID),
 		GitHubClientSecret: getEnv("GITHUB_CLIENT_SECRET", DefaultGitHubClientSecret),
 		SessionSecret:      getEnv("SESSION_SECRET", ""),
+		TokenEncryptionKey: getEnv("TOKEN_ENCRYPTION_KEY", ""),
 		DatabasePath:       getEnv("DATABASE_PATH", filepath.Join(dataDir, "council.db")),
 		Port:               getEnv("PORT", "8080"),
 		Host:               getEnv("HOST", "0.0.0.0"),
@@ -61,6 +147,74 @@ func Load() (*Config, error) {
 
 	cfg.IsDev = cfg.Env == "development"
 
+	elo, err := loadEloConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Elo = elo
+
+	if cfg.ModelRetireAfterDays, err = getEnvInt("MODEL_RETIRE_AFTER_DAYS", 14); err != nil {
+		return nil, err
+	}
+
+	if cfg.EventBufferSize, err = getEnvInt("EVENT_BUFFER_SIZE", 1024); err != nil {
+		return nil, err
+	}
+	if cfg.EventBufferSize < 1 {
+		return nil, fmt.Errorf("EVENT_BUFFER_SIZE must be at least 1")
+	}
+	if cfg.EventRetentionDays, err = getEnvInt("EVENT_RETENTION_DAYS", 7); err != nil {
+		return nil, err
+	}
+
+	if cfg.WSPingInterval, err = getEnvInt("WS_PING_INTERVAL", 30); err != nil {
+		return nil, err
+	}
+	if cfg.WSPongTimeout, err = getEnvInt("WS_PONG_TIMEOUT", 75); err != nil {
+		return nil, err
+	}
+	if cfg.WSPingInterval < 1 || cfg.WSPongTimeout <= cfg.WSPingInterval {
+		return nil, fmt.Errorf("WS_PING_INTERVAL must be at least 1 and less than WS_PONG_TIMEOUT")
+	}
+	if cfg.WSMaxConnectionsPerUser, err = getEnvInt("WS_MAX_CONNECTIONS_PER_USER", 20); err != nil {
+		return nil, err
+	}
+	if cfg.WSMaxConnectionsPerSession, err = getEnvInt("WS_MAX_CONNECTIONS_PER_SESSION", 50); err != nil {
+		return nil, err
+	}
+
+	cfg.DatabaseURL = getEnv("DATABASE_URL", "")
+	if cfg.DatabaseURL != "" && !strings.HasPrefix(cfg.DatabaseURL, "postgres://") && !strings.HasPrefix(cfg.DatabaseURL, "postgresql://") {
+		return nil, fmt.Errorf("DATABASE_URL must be a postgres:// or postgresql:// URL")
+	}
+
+	cfg.BrokerURL = getEnv("BROKER_URL", "")
+	if cfg.BrokerURL != "" && !strings.HasPrefix(cfg.BrokerURL, "redis://") && !strings.HasPrefix(cfg.BrokerURL, "rediss://") {
+		return nil, fmt.Errorf("BROKER_URL must be a redis:// or rediss:// URL")
+	}
+	if cfg.InstanceID, err = instanceID(); err != nil {
+		return nil, err
+	}
+
+	classifier, err := loadClassifierConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Classifier = classifier
+
+	cfg.AdminUsers = getEnvList("ADMIN_USERS")
+	cfg.AdminTeams = getEnvList("ADMIN_TEAMS")
+	cfg.DefaultRole = strings.ToLower(getEnv("DEFAULT_ROLE", "member"))
+	if cfg.DefaultRole != "member" && cfg.DefaultRole != "viewer" {
+		return nil, fmt.Errorf("DEFAULT_ROLE must be member or viewer")
+	}
+
+	cfg.AllowedUsers = getEnvList("ALLOWED_USERS")
+	cfg.AllowedOrgs = getEnvList("ALLOWED_ORGS")
+	if cfg.AccessRecheckHours, err = getEnvInt("ACCESS_RECHECK_HOURS", 24); err != nil {
+		return nil, err
+	}
+
 	// Set frontend URL based on environment
 	if cfg.IsDev {
 		cfg.FrontendURL = getEnv("FRONTEND_URL", "http://localhost:5173")
@@ -118,6 +272,123 @@ func loadOrGenerateSecret(dataDir string) (string, error) {
 	return secret, nil
 }
 
+func loadEloConfig() (EloConfig, error) {
+	var cfg EloConfig
+	var err error
+
+	defaults := []struct {
+		key   string
+		value int
+		dst   *int
+	}{
+		{"ELO_INITIAL_RATING", 1500, &cfg.Default.InitialRating},
+		{"ELO_K_FACTOR_NEW", 25, &cfg.Default.KFactorNew},
+		{"ELO_K_FACTOR_NORMAL", 15, &cfg.Default.KFactorNormal},
+		{"ELO_K_FACTOR_PRO", 10, &cfg.Default.KFactorPro},
+		{"ELO_PROVISIONAL_GAMES", 30, &cfg.Default.ProvisionalGames},
+		{"ELO_PRO_THRESHOLD", 2000, &cfg.Default.ProThreshold},
+	}
+	for _, d := range defaults {
+		if *d.dst, err = getEnvInt(d.key, d.value); err != nil {
+			return cfg, err
+		}
+	}
+
+	if cfg.DecayAfterDays, err = getEnvInt("ELO_DECAY_AFTER_DAYS", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.DecayRate, err = getEnvFloat("ELO_DECAY_RATE", 0.01); err != nil {
+		return cfg, err
+	}
+	if cfg.DecayRate < 0 || cfg.DecayRate > 1 {
+		return cfg, fmt.Errorf("ELO_DECAY_RATE must be between 0 and 1")
+	}
+
+	cfg.Categories, err = parseEloOverrides(getEnv("ELO_CATEGORY_OVERRIDES", ""), cfg.Default)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid ELO_CATEGORY_OVERRIDES: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func loadClassifierConfig() (ClassifierConfig, error) {
+	cfg := ClassifierConfig{
+		Mode:  strings.ToLower(getEnv("CLASSIFIER_MODE", "off")),
+		Model: getEnv("CLASSIFIER_MODEL", "gpt-4o-mini"),
+	}
+
+	switch cfg.Mode {
+	case "off", "keyword", "model":
+	default:
+		return cfg, fmt.Errorf("CLASSIFIER_MODE must be one of off, keyword, model")
+	}
+
+	var err error
+	if cfg.MinConfidence, err = getEnvFloat("CLASSIFIER_MIN_CONFIDENCE", 0.3); err != nil {
+		return cfg, err
+	}
+	if cfg.MinConfidence < 0 || cfg.MinConfidence > 1 {
+		return cfg, fmt.Errorf("CLASSIFIER_MIN_CONFIDENCE must be between 0 and 1")
+	}
+
+	return cfg, nil
+}
+
+// parseEloOverrides parses per-category overrides of the form
+// "creative:k_new=40,k_normal=25;math:k_pro=8". Unset keys inherit the defaults.
+func parseEloOverrides(raw string, defaults EloParams) (map[string]EloParams, error) {
+	overrides := make(map[string]EloParams)
+	if strings.TrimSpace(raw) == "" {
+		return overrides, nil
+	}
+
+	for _, entry := range strings.Split(raw, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, settings, ok := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("expected <category>:<key>=<value>, got %q", entry)
+		}
+
+		params := defaults
+		for _, kv := range strings.Split(settings, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(kv), "=")
+			if !ok {
+				return nil, fmt.Errorf("expected <key>=<value> in %q", entry)
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s in category %s: %w", key, name, err)
+			}
+
+			switch strings.TrimSpace(key) {
+			case "initial":
+				params.InitialRating = n
+			case "k_new":
+				params.KFactorNew = n
+			case "k_normal":
+				params.KFactorNormal = n
+			case "k_pro":
+				params.KFactorPro = n
+			case "provisional_games":
+				params.ProvisionalGames = n
+			case "pro_threshold":
+				params.ProThreshold = n
+			default:
+				return nil, fmt.Errorf("unknown key %q in category %s", key, name)
+			}
+		}
+		overrides[name] = params
+	}
+
+	return overrides, nil
+}
+
 func (c *Config) validate() error {
 	if c.GitHubClientID == "" {
 		return fmt.Errorf("GITHUB_CLIENT_ID is required")
@@ -131,6 +402,9 @@ func (c *Config) validate() error {
 	if len(c.SessionSecret) < 32 {
 		return fmt.Errorf("SESSION_SECRET must be at least 32 characters")
 	}
+	if c.TokenEncryptionKey != "" && len(c.TokenEncryptionKey) < 32 {
+		return fmt.Errorf("TOKEN_ENCRYPTION_KEY must be at least 32 characters")
+	}
 	return nil
 }
 
@@ -145,9 +419,62 @@ func (c *Config) OAuthCallbackURL() string {
 	return fmt.Sprintf("%s/auth/callback", c.FrontendURL)
 }
 
+// instanceID returns INSTANCE_ID, or the host name with a random suffix so
+// restarts and replicas on one host are told apart
+func instanceID() (string, error) {
+	if id := getEnv("INSTANCE_ID", ""); id != "" {
+		return id, nil
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "council"
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate instance id: %w", err)
+	}
+	return host + "-" + hex.EncodeToString(suffix), nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+func getEnvFloat(key string, defaultValue float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number: %w", key, err)
+	}
+	return f, nil
+}
+
+// getEnvList splits a comma-separated variable, dropping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer: %w", key, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEloOverrides(t *testing.T) {
+	defaults := EloParams{InitialRating: 1500, KFactorNew: 25, KFactorNormal: 15, KFactorPro: 10, ProvisionalGames: 30, ProThreshold: 2000}
+	with := func(change func(p *EloParams)) EloParams {
+		p := defaults
+		change(&p)
+		return p
+	}
+
+	tests := []struct {
+		name    string
+		raw     string
+		want    map[string]EloParams
+		wantErr bool
+	}{
+		{"empty", " ", map[string]EloParams{}, false},
+		{"single key", "math:k_pro=8", map[string]EloParams{
+			"math": with(func(p *EloParams) { p.KFactorPro = 8 }),
+		}, false},
+		{"several categories", "creative: k_new=40, k_normal=25 ;math:initial=1400;", map[string]EloParams{
+			"creative": with(func(p *EloParams) { p.KFactorNew, p.KFactorNormal = 40, 25 }),
+			"math":     with(func(p *EloParams) { p.InitialRating = 1400 }),
+		}, false},
+		{"missing category", ":k_new=40", nil, true},
+		{"missing value", "math:k_new", nil, true},
+		{"invalid number", "math:k_new=lots", nil, true},
+		{"unknown key", "math:k_huge=1", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseEloOverrides(tt.raw, defaults)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseEloOverrides(%q) = %+v, want %+v", tt.name, tt.raw, got, tt.want)
+		}
+	}
+
+	config := EloConfig{Default: defaults, Categories: map[string]EloParams{"math": with(func(p *EloParams) { p.KFactorPro = 8 })}}
+	if got := config.ForCategory("math").KFactorPro; got != 8 {
+		t.Errorf("ForCategory(math).KFactorPro = %d, want 8", got)
+	}
+	if got := config.ForCategory("coding"); got != defaults {
+		t.Errorf("ForCategory(coding) = %+v, want the defaults", got)
+	}
+}
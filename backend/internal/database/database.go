@@ -12,11 +12,14 @@ import (
 	_ "modernc.org/sqlite"
 )
 
-//go:embed migrations/*.sql
+// Each dialect has its own migrations, numbered alike
+//
+//go:embed migrations/*.sql migrations_postgres/*.sql
 var embedMigrations embed.FS
 
 type DB struct {
 	*sql.DB
+	Dialect Dialect
 }
 
 func New(dbPath string) (*DB, error) {
@@ -46,23 +49,36 @@ func New(dbPath string) (*DB, error) {
 	db.SetMaxOpenConns(1) // SQLite works best with single connection
 	db.SetMaxIdleConns(1)
 
-	return &DB{db}, nil
+	return &DB{DB: db, Dialect: SQLite}, nil
 }
 
 func (db *DB) Migrate() error {
-	goose.SetBaseFS(embedMigrations)
-
-	if err := goose.SetDialect("sqlite3"); err != nil {
-		return fmt.Errorf("failed to set dialect: %w", err)
+	dir, err := db.migrations()
+	if err != nil {
+		return err
 	}
 
-	if err := goose.Up(db.DB, "migrations"); err != nil {
+	if err := goose.Up(db.DB, dir); err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
 	return nil
 }
 
+// migrations points goose at the dialect's migrations and returns their directory
+func (db *DB) migrations() (string, error) {
+	goose.SetBaseFS(embedMigrations)
+
+	dialect, dir := "sqlite3", "migrations"
+	if db.Dialect == Postgres {
+		dialect, dir = "postgres", "migrations_postgres"
+	}
+	if err := goose.SetDialect(dialect); err != nil {
+		return "", fmt.Errorf("failed to set dialect: %w", err)
+	}
+	return dir, nil
+}
+
 func (db *DB) Close() error {
 	return db.DB.Close()
 }
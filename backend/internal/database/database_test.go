@@ -0,0 +1,124 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+// TestUncategorizedRatingsMigration checks that migration 020 folds
+// duplicate uncategorized rows and keeps new ones from appearing
+func TestUncategorizedRatingsMigration(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		db, err := New(":memory:")
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+		testUncategorizedRatingsMigration(t, db)
+	})
+	t.Run("postgres", func(t *testing.T) {
+		testUncategorizedRatingsMigration(t, openPostgres(t))
+	})
+}
+
+func testUncategorizedRatingsMigration(t *testing.T, db *DB) {
+	dir, err := db.migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := goose.UpTo(db.DB, dir, 19); err != nil {
+		t.Fatalf("migrate to 019: %v", err)
+	}
+
+	exec := func(query string, args ...interface{}) error {
+		_, err := db.Exec(query, args...)
+		return err
+	}
+	mustExec := func(query string, args ...interface{}) {
+		t.Helper()
+		if err := exec(query, args...); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	mustExec(`INSERT INTO models (id, display_name, provider) VALUES ('m1', 'M1', 'test'), ('m2', 'M2', 'test')`)
+
+	rating := `INSERT INTO model_ratings (model_id, category_id, rating, wins, losses, draws, last_game_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	mustExec(rating, "m1", nil, 1500, 1, 0, 0, "2026-01-01 00:00:00")
+	mustExec(rating, "m1", nil, 1520, 0, 1, 0, "2026-02-01 00:00:00")
+	mustExec(rating, "m1", nil, 1510, 2, 0, 1, "2026-01-15 00:00:00")
+	mustExec(rating, "m2", nil, 1450, 0, 2, 0, "2026-01-01 00:00:00")
+	mustExec(rating, "m1", 2, 1600, 4, 0, 0, "2026-01-01 00:00:00")
+	matchup := `INSERT INTO matchups (model_a_id, model_b_id, category_id, model_a_wins, model_b_wins, draws) VALUES (?, ?, ?, ?, ?, ?)`
+	mustExec(matchup, "m1", "m2", nil, 1, 0, 0)
+	mustExec(matchup, "m1", "m2", nil, 0, 1, 1)
+	mustExec(matchup, "m1", "m2", 2, 3, 0, 0)
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	tests := []struct {
+		model      string
+		category   interface{}
+		rating     int
+		wins       int
+		losses     int
+		draws      int
+		lastGameAt string
+	}{
+		{"m1", nil, 1510, 3, 1, 1, "2026-02-01"}, // Latest rating, summed games
+		{"m2", nil, 1450, 0, 2, 0, "2026-01-01"},
+		{"m1", 2, 1600, 4, 0, 0, "2026-01-01"},
+	}
+	for _, tt := range tests {
+		rows, err := db.Query(`
+			SELECT rating, wins, losses, draws, last_game_at FROM model_ratings
+			WHERE model_id = ? AND COALESCE(category_id, 0) = COALESCE(?, 0)
+		`, tt.model, tt.category)
+		if err != nil {
+			t.Fatalf("ratings of %s in %v: %v", tt.model, tt.category, err)
+		}
+		count := 0
+		for rows.Next() {
+			count++
+			var rating, wins, losses, draws int
+			var lastGameAt time.Time
+			if err := rows.Scan(&rating, &wins, &losses, &draws, &lastGameAt); err != nil {
+				t.Fatalf("scan rating: %v", err)
+			}
+			got := lastGameAt.Format("2006-01-02")
+			if rating != tt.rating || wins != tt.wins || losses != tt.losses || draws != tt.draws || got != tt.lastGameAt {
+				t.Errorf("%s in %v = %d %d-%d-%d %s, want %d %d-%d-%d %s", tt.model, tt.category,
+					rating, wins, losses, draws, got, tt.rating, tt.wins, tt.losses, tt.draws, tt.lastGameAt)
+			}
+		}
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			t.Fatalf("ratings: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("%s in %v has %d rows, want 1", tt.model, tt.category, count)
+		}
+	}
+
+	var count, aWins, bWins, draws int
+	err = db.QueryRow(`
+		SELECT COUNT(*), MAX(model_a_wins), MAX(model_b_wins), MAX(draws) FROM matchups
+		WHERE model_a_id = 'm1' AND model_b_id = 'm2' AND category_id IS NULL
+	`).Scan(&count, &aWins, &bWins, &draws)
+	if err != nil {
+		t.Fatalf("matchup: %v", err)
+	}
+	if count != 1 || aWins != 1 || bWins != 1 || draws != 1 {
+		t.Errorf("uncategorized matchup = %d rows, %d-%d-%d, want 1 row, 1-1-1", count, aWins, bWins, draws)
+	}
+
+	if err := exec(rating, "m1", nil, 1500, 0, 0, 0, nil); err == nil {
+		t.Errorf("inserted a second uncategorized rating")
+	}
+	if err := exec(matchup, "m1", "m2", nil, 0, 0, 0); err == nil {
+		t.Errorf("inserted a second uncategorized matchup")
+	}
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"fmt"
+)
+
+// Dialect is the SQL flavor of the database. Queries are written for both:
+// placeholders are always "?", and the few expressions that differ come
+// from the dialect.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// Day formats a timestamp column as its YYYY-MM-DD date
+func (d Dialect) Day(column string) string {
+	if d == Postgres {
+		return fmt.Sprintf("to_char(%s, 'YYYY-MM-DD')", column)
+	}
+	return fmt.Sprintf("date(%s)", column)
+}
+
+// Greatest returns the larger of two values
+func (d Dialect) Greatest(a, b string) string {
+	if d == Postgres {
+		return fmt.Sprintf("GREATEST(%s, %s)", a, b)
+	}
+	return fmt.Sprintf("MAX(%s, %s)", a, b)
+}
+
+// JSONIndex extracts element i of a JSON array stored as text
+func (d Dialect) JSONIndex(column string, i int) string {
+	if d == Postgres {
+		return fmt.Sprintf("(%s::jsonb ->> %d)", column, i)
+	}
+	return fmt.Sprintf("json_extract(%s, '$[%d]')", column, i)
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"time"
+)
+
+// LeaseGrace is added to a job's interval when leasing it, so the holder
+// renews its lease before anyone else can take it
+const LeaseGrace = 5 * time.Minute
+
+// leaseTimeFormat matches CURRENT_TIMESTAMP so lease times compare as text
+const leaseTimeFormat = "2006-01-02 15:04:05"
+
+// Leases lets one instance at a time run a named job when several
+// instances share the database. A lease is held until it expires or its
+// holder renews it.
+type Leases struct {
+	db     *DB
+	holder string
+}
+
+// NewLeases creates leases held in the name of the given instance
+func NewLeases(db *DB, holder string) *Leases {
+	return &Leases{db: db, holder: holder}
+}
+
+// Holder returns the instance the leases are taken for
+func (l *Leases) Holder() string {
+	return l.holder
+}
+
+// Acquire takes or renews the named lease for ttl. It reports false while
+// another instance holds an unexpired lease.
+func (l *Leases) Acquire(name string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	result, err := l.db.Exec(`
+		INSERT INTO job_leases (name, holder, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE job_leases.holder = excluded.holder OR job_leases.expires_at < ?
+	`, name, l.holder, now.Add(ttl).Format(leaseTimeFormat), now.Format(leaseTimeFormat))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Release gives up the named lease if this instance holds it
+func (l *Leases) Release(name string) error {
+	_, err := l.db.Exec(`DELETE FROM job_leases WHERE name = ? AND holder = ?`, name, l.holder)
+	return err
+}
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Record the rating parameters that produced each change
+ALTER TABLE elo_history ADD COLUMN k_factor INTEGER;
+ALTER TABLE elo_history ADD COLUMN params TEXT;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE elo_history DROP COLUMN params;
+ALTER TABLE elo_history DROP COLUMN k_factor;
+
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Daily copies of model_ratings for point-in-time leaderboards
+CREATE TABLE leaderboard_snapshots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    snapshot_date DATE NOT NULL,
+    model_id TEXT NOT NULL REFERENCES models(id) ON DELETE CASCADE,
+    category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL,
+    rating INTEGER NOT NULL,
+    wins INTEGER NOT NULL DEFAULT 0,
+    losses INTEGER NOT NULL DEFAULT 0,
+    draws INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_leaderboard_snapshots_date ON leaderboard_snapshots(snapshot_date);
+CREATE INDEX idx_leaderboard_snapshots_model ON leaderboard_snapshots(model_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_leaderboard_snapshots_model;
+DROP INDEX IF EXISTS idx_leaderboard_snapshots_date;
+DROP TABLE IF EXISTS leaderboard_snapshots;
+
+-- +goose StatementEnd
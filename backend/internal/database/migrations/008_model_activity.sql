@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Last time Copilot offered the model to any user
+ALTER TABLE models ADD COLUMN last_seen_at DATETIME;
+UPDATE models SET last_seen_at = CURRENT_TIMESTAMP;
+
+-- Last rated game and last inactivity decay per rating
+ALTER TABLE model_ratings ADD COLUMN last_game_at DATETIME;
+ALTER TABLE model_ratings ADD COLUMN decayed_at DATETIME;
+UPDATE model_ratings SET last_game_at = updated_at;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE model_ratings DROP COLUMN decayed_at;
+ALTER TABLE model_ratings DROP COLUMN last_game_at;
+ALTER TABLE models DROP COLUMN last_seen_at;
+
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- How the session's category was chosen: user, keyword, model or override
+ALTER TABLE sessions ADD COLUMN category_source TEXT;
+ALTER TABLE sessions ADD COLUMN category_confidence REAL;
+UPDATE sessions SET category_source = 'user' WHERE category_id IS NOT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE sessions DROP COLUMN category_confidence;
+ALTER TABLE sessions DROP COLUMN category_source;
+
+-- +goose StatementEnd
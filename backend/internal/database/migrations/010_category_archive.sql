@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Archived categories keep their ratings but are hidden from pickers and the classifier
+ALTER TABLE categories ADD COLUMN archived_at DATETIME;
+ALTER TABLE categories ADD COLUMN updated_at DATETIME;
+UPDATE categories SET updated_at = created_at;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE categories DROP COLUMN updated_at;
+ALTER TABLE categories DROP COLUMN archived_at;
+
+-- +goose StatementEnd
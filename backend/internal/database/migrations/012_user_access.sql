@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Result of the last sign-in allowlist check per user
+ALTER TABLE user_preferences ADD COLUMN access_checked_at DATETIME;
+ALTER TABLE user_preferences ADD COLUMN access_denied_at DATETIME;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE user_preferences DROP COLUMN access_denied_at;
+ALTER TABLE user_preferences DROP COLUMN access_checked_at;
+
+-- +goose StatementEnd
@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Full-text index over session questions and syntheses. It keeps its own copy
+-- of the text because rowids of the sessions table are not stable.
+CREATE VIRTUAL TABLE IF NOT EXISTS session_search USING fts5(
+    session_id UNINDEXED,
+    question,
+    synthesis,
+    tokenize = 'porter unicode61'
+);
+
+-- Full-text index over responses, reading the text from the responses table
+CREATE VIRTUAL TABLE IF NOT EXISTS response_search USING fts5(
+    content,
+    content = 'responses',
+    content_rowid = 'id',
+    tokenize = 'porter unicode61'
+);
+
+CREATE TRIGGER IF NOT EXISTS session_search_insert AFTER INSERT ON sessions BEGIN
+    INSERT INTO session_search (session_id, question, synthesis) VALUES (new.id, new.question, new.synthesis);
+END;
+
+CREATE TRIGGER IF NOT EXISTS session_search_update AFTER UPDATE OF question, synthesis ON sessions BEGIN
+    UPDATE session_search SET question = new.question, synthesis = new.synthesis WHERE session_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS session_search_delete AFTER DELETE ON sessions BEGIN
+    DELETE FROM session_search WHERE session_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS response_search_insert AFTER INSERT ON responses BEGIN
+    INSERT INTO response_search (rowid, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS response_search_update AFTER UPDATE OF content ON responses BEGIN
+    INSERT INTO response_search (response_search, rowid, content) VALUES ('delete', old.id, old.content);
+    INSERT INTO response_search (rowid, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS response_search_delete AFTER DELETE ON responses BEGIN
+    INSERT INTO response_search (response_search, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+
+-- Index what is already there
+INSERT INTO session_search (session_id, question, synthesis)
+SELECT id, question, synthesis FROM sessions;
+
+INSERT INTO response_search (response_search) VALUES ('rebuild');
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TRIGGER IF EXISTS response_search_delete;
+DROP TRIGGER IF EXISTS response_search_update;
+DROP TRIGGER IF EXISTS response_search_insert;
+DROP TRIGGER IF EXISTS session_search_delete;
+DROP TRIGGER IF EXISTS session_search_update;
+DROP TRIGGER IF EXISTS session_search_insert;
+DROP TABLE IF EXISTS response_search;
+DROP TABLE IF EXISTS session_search;
+
+-- +goose StatementEnd
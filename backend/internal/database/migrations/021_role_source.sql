@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Where a user's role came from: 'team' for admins granted through
+-- ADMIN_TEAMS, which is taken back when they leave the team, 'manual' for
+-- roles set by an admin. Roles stored before this column are kept as manual.
+ALTER TABLE user_preferences ADD COLUMN role_source TEXT CHECK(role_source IN ('team', 'manual'));
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE user_preferences DROP COLUMN role_source;
+
+-- +goose StatementEnd
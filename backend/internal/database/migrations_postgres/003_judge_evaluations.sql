@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Per-session agreement of each judging model with a reference ranking
+CREATE TABLE judge_evaluations (
+    id BIGSERIAL PRIMARY KEY,
+    session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    judge_id TEXT NOT NULL REFERENCES models(id) ON DELETE CASCADE,
+    reference TEXT NOT NULL CHECK(reference IN ('consensus', 'user')),
+    agreement DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_judge_evaluations_judge ON judge_evaluations(judge_id);
+CREATE INDEX idx_judge_evaluations_session ON judge_evaluations(session_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_judge_evaluations_session;
+DROP INDEX IF EXISTS idx_judge_evaluations_judge;
+DROP TABLE IF EXISTS judge_evaluations;
+
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Kendall's W (coefficient of concordance) across all voters of a session
+ALTER TABLE sessions ADD COLUMN agreement_w DOUBLE PRECISION;
+
+-- Pairwise Kendall tau between voters of a session
+CREATE TABLE vote_agreements (
+    id BIGSERIAL PRIMARY KEY,
+    session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    voter_a TEXT NOT NULL,
+    voter_b TEXT NOT NULL,
+    tau DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(session_id, voter_a, voter_b)
+);
+
+CREATE INDEX idx_vote_agreements_session ON vote_agreements(session_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_vote_agreements_session;
+DROP TABLE IF EXISTS vote_agreements;
+ALTER TABLE sessions DROP COLUMN agreement_w;
+
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Per-session head-to-head outcomes behind the aggregated matchups table
+CREATE TABLE matchup_results (
+    id BIGSERIAL PRIMARY KEY,
+    session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    model_a_id TEXT NOT NULL REFERENCES models(id) ON DELETE CASCADE,
+    model_b_id TEXT NOT NULL REFERENCES models(id) ON DELETE CASCADE,
+    category_id INTEGER REFERENCES categories(id),
+    winner_id TEXT REFERENCES models(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_matchup_results_models ON matchup_results(model_a_id, model_b_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_matchup_results_models;
+DROP TABLE IF EXISTS matchup_results;
+
+-- +goose StatementEnd
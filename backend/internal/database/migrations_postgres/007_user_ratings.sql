@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Personal ELO ratings computed from a single user's sessions
+CREATE TABLE user_model_ratings (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    model_id TEXT NOT NULL REFERENCES models(id) ON DELETE CASCADE,
+    category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL,
+    rating INTEGER NOT NULL,
+    wins INTEGER DEFAULT 0,
+    losses INTEGER DEFAULT 0,
+    draws INTEGER DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Expression index so uncategorized (NULL) ratings stay unique per user and model
+CREATE UNIQUE INDEX idx_user_model_ratings_unique ON user_model_ratings(user_id, model_id, COALESCE(category_id, 0));
+
+-- Personal ELO history, mirroring elo_history
+CREATE TABLE user_elo_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    model_id TEXT NOT NULL REFERENCES models(id) ON DELETE CASCADE,
+    category_id INTEGER REFERENCES categories(id),
+    session_id TEXT REFERENCES sessions(id) ON DELETE SET NULL,
+    old_rating INTEGER NOT NULL,
+    new_rating INTEGER NOT NULL,
+    change INTEGER NOT NULL,
+    reason TEXT,
+    k_factor INTEGER,
+    params TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_elo_history_user_model ON user_elo_history(user_id, model_id);
+
+-- Leaderboard scope shown by default: the global or the personal track
+ALTER TABLE user_preferences ADD COLUMN rating_scope TEXT DEFAULT 'global' CHECK(rating_scope IN ('global', 'me'));
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE user_preferences DROP COLUMN rating_scope;
+DROP INDEX IF EXISTS idx_user_elo_history_user_model;
+DROP TABLE IF EXISTS user_elo_history;
+DROP INDEX IF EXISTS idx_user_model_ratings_unique;
+DROP TABLE IF EXISTS user_model_ratings;
+
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- How the session's category was chosen: user, keyword, model or override
+ALTER TABLE sessions ADD COLUMN category_source TEXT;
+ALTER TABLE sessions ADD COLUMN category_confidence DOUBLE PRECISION;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE sessions DROP COLUMN category_confidence;
+ALTER TABLE sessions DROP COLUMN category_source;
+
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Instance role per user; NULL falls back to the configured default role
+ALTER TABLE user_preferences ADD COLUMN role TEXT CHECK(role IN ('admin', 'member', 'viewer'));
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE user_preferences DROP COLUMN role;
+
+-- +goose StatementEnd
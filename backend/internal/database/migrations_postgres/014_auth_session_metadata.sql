@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Device details shown when users review their active sessions, and when
+-- the session's JWT expires
+ALTER TABLE auth_sessions ADD COLUMN user_agent TEXT;
+ALTER TABLE auth_sessions ADD COLUMN ip_address TEXT;
+ALTER TABLE auth_sessions ADD COLUMN expires_at TIMESTAMP;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE auth_sessions DROP COLUMN expires_at;
+ALTER TABLE auth_sessions DROP COLUMN ip_address;
+ALTER TABLE auth_sessions DROP COLUMN user_agent;
+
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Named jobs one instance at a time may run when several share the database
+CREATE TABLE IF NOT EXISTS job_leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- The instance executing a session, renewed while it runs so sessions of a
+-- stopped instance can be failed
+ALTER TABLE sessions ADD COLUMN claimed_by TEXT;
+ALTER TABLE sessions ADD COLUMN claim_expires_at TIMESTAMP;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE sessions DROP COLUMN claim_expires_at;
+ALTER TABLE sessions DROP COLUMN claimed_by;
+DROP TABLE IF EXISTS job_leases;
+
+-- +goose StatementEnd
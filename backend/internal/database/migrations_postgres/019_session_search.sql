@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Full-text search vectors, kept up to date by Postgres as the text changes.
+-- Questions weigh more than syntheses.
+ALTER TABLE sessions ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', question), 'A') ||
+    setweight(to_tsvector('english', COALESCE(synthesis, '')), 'B')
+) STORED;
+
+ALTER TABLE responses ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    to_tsvector('english', content)
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_search ON sessions USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_responses_search ON responses USING GIN (search_vector);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_responses_search;
+DROP INDEX IF EXISTS idx_sessions_search;
+ALTER TABLE responses DROP COLUMN search_vector;
+ALTER TABLE sessions DROP COLUMN search_vector;
+
+-- +goose StatementEnd
@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- UNIQUE(model_id, category_id) lets NULL categories repeat, so key
+-- ratings and head-to-head records on COALESCE(category_id, 0) like
+-- user_model_ratings. Duplicate uncategorized ratings are folded into the
+-- oldest row first, keeping the latest rating and the summed game counts.
+UPDATE model_ratings SET
+    rating = (SELECT d.rating FROM model_ratings d
+              WHERE d.model_id = model_ratings.model_id AND d.category_id IS NULL
+              ORDER BY d.id DESC LIMIT 1),
+    wins = (SELECT SUM(d.wins) FROM model_ratings d WHERE d.model_id = model_ratings.model_id AND d.category_id IS NULL),
+    losses = (SELECT SUM(d.losses) FROM model_ratings d WHERE d.model_id = model_ratings.model_id AND d.category_id IS NULL),
+    draws = (SELECT SUM(d.draws) FROM model_ratings d WHERE d.model_id = model_ratings.model_id AND d.category_id IS NULL),
+    last_game_at = (SELECT MAX(d.last_game_at) FROM model_ratings d WHERE d.model_id = model_ratings.model_id AND d.category_id IS NULL),
+    updated_at = (SELECT MAX(d.updated_at) FROM model_ratings d WHERE d.model_id = model_ratings.model_id AND d.category_id IS NULL)
+WHERE category_id IS NULL
+  AND id = (SELECT MIN(d.id) FROM model_ratings d WHERE d.model_id = model_ratings.model_id AND d.category_id IS NULL);
+
+DELETE FROM model_ratings
+WHERE category_id IS NULL
+  AND id > (SELECT MIN(d.id) FROM model_ratings d WHERE d.model_id = model_ratings.model_id AND d.category_id IS NULL);
+
+CREATE UNIQUE INDEX idx_model_ratings_model_category ON model_ratings(model_id, COALESCE(category_id, 0));
+
+-- Same for head-to-head records
+UPDATE matchups SET
+    model_a_wins = (SELECT SUM(d.model_a_wins) FROM matchups d
+                    WHERE d.model_a_id = matchups.model_a_id AND d.model_b_id = matchups.model_b_id AND d.category_id IS NULL),
+    model_b_wins = (SELECT SUM(d.model_b_wins) FROM matchups d
+                    WHERE d.model_a_id = matchups.model_a_id AND d.model_b_id = matchups.model_b_id AND d.category_id IS NULL),
+    draws = (SELECT SUM(d.draws) FROM matchups d
+             WHERE d.model_a_id = matchups.model_a_id AND d.model_b_id = matchups.model_b_id AND d.category_id IS NULL)
+WHERE category_id IS NULL
+  AND id = (SELECT MIN(d.id) FROM matchups d
+            WHERE d.model_a_id = matchups.model_a_id AND d.model_b_id = matchups.model_b_id AND d.category_id IS NULL);
+
+DELETE FROM matchups
+WHERE category_id IS NULL
+  AND id > (SELECT MIN(d.id) FROM matchups d
+            WHERE d.model_a_id = matchups.model_a_id AND d.model_b_id = matchups.model_b_id AND d.category_id IS NULL);
+
+CREATE UNIQUE INDEX idx_matchups_pair_category ON matchups(model_a_id, model_b_id, COALESCE(category_id, 0));
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_matchups_pair_category;
+DROP INDEX IF EXISTS idx_model_ratings_model_category;
+
+-- +goose StatementEnd
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"no placeholders", `SELECT 1`, `SELECT 1`},
+		{"numbered in order", `SELECT * FROM t WHERE a = ? AND b IN (?, ?)`, `SELECT * FROM t WHERE a = $1 AND b IN ($2, $3)`},
+		{"string literal", `SELECT '?' FROM t WHERE a = ?`, `SELECT '?' FROM t WHERE a = $1`},
+		{"escaped quote in literal", `SELECT 'it''s ?' WHERE a = ?`, `SELECT 'it''s ?' WHERE a = $1`},
+		{"quoted identifier", `SELECT "odd?name" FROM t WHERE a = ?`, `SELECT "odd?name" FROM t WHERE a = $1`},
+		{"comment", "SELECT a -- why?\nFROM t WHERE a = ?", "SELECT a -- why?\nFROM t WHERE a = $1"},
+		{"trailing comment", `SELECT ? -- done?`, `SELECT $1 -- done?`},
+		{"unterminated literal", `SELECT ?, 'oops?`, `SELECT $1, 'oops?`},
+	}
+	for _, tt := range tests {
+		if got := rebind(tt.query); got != tt.want {
+			t.Errorf("%s: rebind(%q) = %q, want %q", tt.name, tt.query, got, tt.want)
+		}
+	}
+}
+
+// TestPostgres runs the PostgreSQL migrations and placeholder rewriting
+// against TEST_DATABASE_URL, which it wipes
+func TestPostgres(t *testing.T) {
+	db := openPostgres(t)
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	migrations, err := fs.Glob(embedMigrations, "migrations_postgres/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var version int
+	if err := db.QueryRow(`SELECT MAX(version_id) FROM goose_db_version`).Scan(&version); err != nil {
+		t.Fatalf("migration version: %v", err)
+	}
+	if version != len(migrations) {
+		t.Errorf("migrated to version %d, want %d", version, len(migrations))
+	}
+
+	var literal, name string
+	var count int
+	err = db.QueryRow(`
+		SELECT '?', name, (SELECT COUNT(*) FROM categories WHERE name IN (?, ?)) -- any?
+		FROM categories WHERE name = ?
+	`, "coding", "math", "coding").Scan(&literal, &name, &count)
+	if err != nil {
+		t.Fatalf("query with placeholders: %v", err)
+	}
+	if literal != "?" || name != "coding" || count != 2 {
+		t.Errorf("query with placeholders = %q, %q, %d, want \"?\", \"coding\", 2", literal, name, count)
+	}
+}
+
+// openPostgres connects to TEST_DATABASE_URL and empties it
+func openPostgres(t *testing.T) *DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := NewPostgres(url)
+	if err != nil {
+		t.Fatalf("NewPostgres: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	for _, query := range []string{`DROP SCHEMA public CASCADE`, `CREATE SCHEMA public`} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	return db
+}
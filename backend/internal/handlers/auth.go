@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -8,19 +9,23 @@ import (
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/sainaif/council/internal/config"
-	"github.com/sainaif/council/internal/database"
 	"github.com/sainaif/council/internal/middleware"
 	"github.com/sainaif/council/internal/services/auth"
+	"github.com/sainaif/council/internal/store"
 )
 
 type AuthHandler struct {
-	auth *auth.GitHubAuth
-	db   *database.DB
-	cfg  *config.Config
+	auth     *auth.GitHubAuth
+	vault    *auth.Vault
+	roles    *auth.RoleService
+	access   *auth.AccessPolicy
+	users    store.UserStore
+	settings store.SettingsStore
+	cfg      *config.Config
 }
 
-func NewAuthHandler(auth *auth.GitHubAuth, db *database.DB, cfg *config.Config) *AuthHandler {
-	return &AuthHandler{auth: auth, db: db, cfg: cfg}
+func NewAuthHandler(auth *auth.GitHubAuth, vault *auth.Vault, roles *auth.RoleService, access *auth.AccessPolicy, users store.UserStore, settings store.SettingsStore, cfg *config.Config) *AuthHandler {
+	return &AuthHandler{auth: auth, vault: vault, roles: roles, access: access, users: users, settings: settings, cfg: cfg}
 }
 
 func (h *AuthHandler) InitiateOAuth(c *fiber.Ctx) error {
@@ -88,24 +93,54 @@ func (h *AuthHandler) Callback(c *fiber.Ctx) error {
 	}
 	log.Printf("[AUTH] GitHub user authenticated: %s (ID: %d)", user.Login, user.ID)
 
-	// Create or update user preferences
 	// Use fmt.Sprintf to convert int64 to string to match JWT token's UserID format
 	userID := fmt.Sprintf("%d", user.ID)
-	_, err = h.db.Exec(`
-		INSERT INTO user_preferences (user_id, github_username, github_avatar_url, updated_at)
-		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
-		ON CONFLICT(user_id) DO UPDATE SET
-			github_username = ?,
-			github_avatar_url = ?,
-			updated_at = CURRENT_TIMESTAMP
-	`, userID, user.Login, user.AvatarURL, user.Login, user.AvatarURL)
+
+	// Enforce the sign-in allowlist
+	if err := h.access.Check(c.Context(), user.Login, token); err != nil {
+		if errors.Is(err, auth.ErrAccessDenied) {
+			log.Printf("[AUTH] Sign-in denied for %s: not in an allowed organization or team", user.Login)
+			h.access.Record(userID, false)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   true,
+				"message": "Your GitHub account is not allowed to sign in to this instance",
+			})
+		}
+		log.Printf("[AUTH] Failed to verify organization membership for %s: %v", user.Login, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to verify organization membership",
+		})
+	}
+
+	// Remember the user's profile for sharing and the admin user list
+	if err := h.users.Save(store.User{ID: userID, Username: user.Login, AvatarURL: user.AvatarURL}); err != nil {
+		log.Printf("[AUTH] Failed to save profile of %s: %v", user.Login, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to create session",
+		})
+	}
+
+	h.access.Record(userID, true)
+
+	// Promote members of the configured admin orgs/teams
+	h.roles.BootstrapAtLogin(c.Context(), userID, token)
+
+	// Keep the OAuth tokens server-side; the JWT only references the session
+	sessionID, err := h.vault.Create(userID, token, auth.SessionMeta{
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		IPAddress: c.IP(),
+	})
 	if err != nil {
-		// Log but don't fail - user can still use the app
-		log.Printf("Failed to update user preferences: %v", err)
+		log.Printf("[AUTH] Failed to store session for %s: %v", user.Login, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to create session",
+		})
 	}
 
-	// Create JWT token (include OAuth access token for Copilot SDK)
-	jwtToken, err := h.auth.CreateToken(user, token.AccessToken)
+	jwtToken, err := h.auth.CreateToken(user, sessionID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
@@ -130,14 +165,18 @@ func (h *AuthHandler) Callback(c *fiber.Ctx) error {
 }
 
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
-	// Clear token cookie
-	c.Cookie(&fiber.Cookie{
-		Name:     "council_token",
-		Value:    "",
-		Expires:  time.Now().Add(-1 * time.Hour),
-		HTTPOnly: true,
-		Path:     "/",
-	})
+	// Revoke the session and its GitHub token. An expired JWT still
+	// identifies the session to revoke.
+	if tokenCookie := c.Cookies("council_token"); tokenCookie != "" {
+		if claims, err := h.auth.ParseToken(tokenCookie); err == nil {
+			err := h.vault.Revoke(c.Context(), claims.SessionID, claims.UserID)
+			if err != nil && !errors.Is(err, auth.ErrSessionInvalid) {
+				log.Printf("[AUTH] Failed to revoke session for %s: %v", claims.Username, err)
+			}
+		}
+	}
+
+	h.clearTokenCookie(c)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -154,22 +193,108 @@ func (h *AuthHandler) Me(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get additional user info from database
-	var language, uiDensity string
-	err := h.db.QueryRow(`
-		SELECT COALESCE(language, 'en'), COALESCE(ui_density, 'comfortable')
-		FROM user_preferences WHERE user_id = ?
-	`, claims.UserID).Scan(&language, &uiDensity)
+	settings, err := h.settings.Get(claims.UserID)
+	if err != nil {
+		log.Printf("[AUTH] Failed to get settings of user %s: %v", claims.Username, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to get user",
+		})
+	}
+
+	role, err := h.roles.Get(claims.UserID, claims.Username)
 	if err != nil {
-		language = "en"
-		uiDensity = "comfortable"
+		log.Printf("[AUTH] Failed to get role of user %s: %v", claims.Username, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to get user",
+		})
 	}
 
 	return c.JSON(fiber.Map{
 		"user_id":    claims.UserID,
 		"username":   claims.Username,
 		"avatar_url": claims.AvatarURL,
-		"language":   language,
-		"ui_density": uiDensity,
+		"role":       role,
+		"language":   settings.Language,
+		"ui_density": settings.UIDensity,
+	})
+}
+
+// Sessions lists the current user's active sign-ins
+func (h *AuthHandler) Sessions(c *fiber.Ctx) error {
+	claims := middleware.GetClaims(c)
+
+	sessions, err := h.vault.ListSessions(claims.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to list sessions",
+		})
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == claims.SessionID
+	}
+
+	return c.JSON(sessions)
+}
+
+// RevokeSession signs out one of the current user's sessions. Revoking the
+// current session also clears its cookie.
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	claims := middleware.GetClaims(c)
+	sessionID := c.Params("id")
+
+	err := h.vault.Revoke(c.Context(), sessionID, claims.UserID)
+	if errors.Is(err, auth.ErrSessionInvalid) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Session not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to revoke session",
+		})
+	}
+
+	if sessionID == claims.SessionID {
+		h.clearTokenCookie(c)
+	}
+	log.Printf("[AUTH] User %s revoked a session", claims.Username)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"current": sessionID == claims.SessionID,
+	})
+}
+
+// RevokeOtherSessions signs the current user out everywhere else
+func (h *AuthHandler) RevokeOtherSessions(c *fiber.Ctx) error {
+	claims := middleware.GetClaims(c)
+
+	revoked, err := h.vault.RevokeOtherSessions(c.Context(), claims.UserID, claims.SessionID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to revoke sessions",
+		})
+	}
+	log.Printf("[AUTH] User %s revoked %d other session(s)", claims.Username, revoked)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"revoked": revoked,
+	})
+}
+
+func (h *AuthHandler) clearTokenCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "council_token",
+		Value:    "",
+		Expires:  time.Now().Add(-1 * time.Hour),
+		HTTPOnly: true,
+		Path:     "/",
 	})
 }
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/sainaif/council/internal/store"
+)
+
+// categoryNamePattern keeps names usable in URLs and ELO_CATEGORY_OVERRIDES
+var categoryNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// reservedCategoryNames collide with routes under /api/rankings or with
+// the label used for sessions without a category
+var reservedCategoryNames = map[string]bool{
+	"global":            true,
+	"judges":            true,
+	"history":           true,
+	"as-of":             true,
+	store.Uncategorized: true,
+}
+
+type CategoryHandler struct {
+	categories store.CategoryStore
+}
+
+func NewCategoryHandler(categories store.CategoryStore) *CategoryHandler {
+	return &CategoryHandler{categories: categories}
+}
+
+type CategoryRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Archived    *bool   `json:"archived,omitempty"`
+}
+
+type MergeCategoryRequest struct {
+	Into int64 `json:"into"`
+}
+
+// List returns all categories, hiding archived ones unless include_archived is set
+func (h *CategoryHandler) List(c *fiber.Ctx) error {
+	categories, err := h.categories.List(c.QueryBool("include_archived"))
+	if err != nil {
+		log.Printf("[CATEGORIES] Failed to list categories: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to list categories",
+		})
+	}
+	return c.JSON(categories)
+}
+
+// Create adds a new category
+func (h *CategoryHandler) Create(c *fiber.Ctx) error {
+	var req CategoryRequest
+	if err := c.BodyParser(&req); err != nil || req.Name == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Category name required",
+		})
+	}
+
+	name, err := normalizeCategoryName(*req.Name)
+	if err != nil {
+		return err
+	}
+	description := ""
+	if req.Description != nil {
+		description = *req.Description
+	}
+
+	if err := h.requireFreeName(name, 0); err != nil {
+		return err
+	}
+
+	id, err := h.categories.Create(name, description)
+	if err != nil {
+		log.Printf("[CATEGORIES] Failed to create category %q: %v", name, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to create category",
+		})
+	}
+
+	log.Printf("[CATEGORIES] Created category %q (id %d)", name, id)
+
+	cat, err := h.categories.Get(id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to load category",
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(cat)
+}
+
+// Update renames, re-describes, archives or restores a category
+func (h *CategoryHandler) Update(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid category ID",
+		})
+	}
+
+	var req CategoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	if _, err := h.category(int64(id), "Category not found"); err != nil {
+		return err
+	}
+
+	update := store.CategoryUpdate{Description: req.Description, Archived: req.Archived}
+	if req.Name != nil {
+		name, err := normalizeCategoryName(*req.Name)
+		if err != nil {
+			return err
+		}
+		if err := h.requireFreeName(name, int64(id)); err != nil {
+			return err
+		}
+		update.Name = &name
+	}
+
+	if err := h.categories.Update(int64(id), update); err != nil {
+		log.Printf("[CATEGORIES] Failed to update category %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to update category",
+		})
+	}
+
+	cat, err := h.categories.Get(int64(id))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to load category",
+		})
+	}
+	return c.JSON(cat)
+}
+
+// Delete removes a category that nothing refers to yet. Categories with
+// sessions or ratings have to be archived or merged instead.
+func (h *CategoryHandler) Delete(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid category ID",
+		})
+	}
+
+	used, err := h.categories.InUse(int64(id))
+	if err != nil {
+		log.Printf("[CATEGORIES] Failed to check whether category %d is in use: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to delete category",
+		})
+	}
+	if used {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "Category is in use; archive or merge it instead",
+		})
+	}
+
+	err = h.categories.Delete(int64(id))
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Category not found",
+		})
+	}
+	if err != nil {
+		log.Printf("[CATEGORIES] Failed to delete category %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to delete category",
+		})
+	}
+
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// Merge folds a category into another one, see store.CategoryStore.Merge
+func (h *CategoryHandler) Merge(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid category ID",
+		})
+	}
+	from := int64(id)
+
+	var req MergeCategoryRequest
+	if err := c.BodyParser(&req); err != nil || req.Into == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Target category required",
+		})
+	}
+	if req.Into == from {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Cannot merge a category into itself",
+		})
+	}
+
+	source, err := h.category(from, "Category not found")
+	if err != nil {
+		return err
+	}
+	target, err := h.category(req.Into, "Target category not found")
+	if err != nil {
+		return err
+	}
+
+	if err := h.categories.Merge(from, req.Into); err != nil {
+		log.Printf("[CATEGORIES] Failed to merge category %d into %d: %v", from, req.Into, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to merge categories",
+		})
+	}
+
+	log.Printf("[CATEGORIES] Merged category %q into %q", source.Name, target.Name)
+	return c.JSON(fiber.Map{
+		"success": true,
+		"merged":  source.Name,
+		"into":    target,
+	})
+}
+
+// category returns a category, or an error response when it is missing or
+// cannot be read
+func (h *CategoryHandler) category(id int64, missing string) (*store.Category, error) {
+	cat, err := h.categories.Get(id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, fiber.NewError(fiber.StatusNotFound, missing)
+	}
+	if err != nil {
+		log.Printf("[CATEGORIES] Failed to load category %d: %v", id, err)
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to load category")
+	}
+	return cat, nil
+}
+
+// requireFreeName returns an error response if a category other than
+// exceptID already has the name
+func (h *CategoryHandler) requireFreeName(name string, exceptID int64) error {
+	taken, err := h.categories.NameTaken(name, exceptID)
+	if err != nil {
+		log.Printf("[CATEGORIES] Failed to check category name %q: %v", name, err)
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to check category name")
+	}
+	if taken {
+		return fiber.NewError(fiber.StatusConflict, "Category already exists")
+	}
+	return nil
+}
+
+func normalizeCategoryName(raw string) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(raw))
+	if !categoryNamePattern.MatchString(name) {
+		return "", fiber.NewError(fiber.StatusBadRequest, "Category name must be 1-32 lowercase letters, digits, '-' or '_'")
+	}
+	if reservedCategoryNames[name] {
+		return "", fiber.NewError(fiber.StatusBadRequest, "Category name is reserved")
+	}
+	return name, nil
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/sainaif/council/internal/services/auth"
+	"github.com/sainaif/council/internal/services/council"
+	"github.com/sainaif/council/internal/store"
+	ws "github.com/sainaif/council/internal/websocket"
+)
+
+type FollowUpCommand struct {
+	Question string `json:"question"`
+}
+
+// Commands returns the runner for commands a user sends over a council
+// WebSocket. They go through the same orchestrator methods and checks as
+// the REST endpoints. A socket outlives the request that opened it, so the
+// user's session, access and role are checked again for every command.
+func (h *CouncilHandler) Commands(opened *auth.Claims) ws.CommandFunc {
+	return func(ctx context.Context, cmd *ws.Command) (interface{}, error) {
+		claims, role, err := h.authMw.Revalidate(ctx, opened)
+		if err != nil {
+			return nil, revalidateError(opened, err)
+		}
+
+		switch cmd.Type {
+		case ws.CommandSubscribe:
+			return nil, h.commandCanView(cmd.SessionID, claims.UserID)
+		case ws.CommandVote:
+			return nil, h.voteCommand(ctx, claims, role, cmd)
+		case ws.CommandCancel:
+			return nil, h.cancelCommand(ctx, claims, role, cmd)
+		case ws.CommandInjectFollowUp:
+			return h.followUpCommand(ctx, claims, role, cmd)
+		}
+		return nil, &ws.CommandError{Code: ws.CodeUnknownCommand, Message: "Unknown command: " + cmd.Type}
+	}
+}
+
+// voteCommand submits the user's ranking, like Vote
+func (h *CouncilHandler) voteCommand(ctx context.Context, claims *auth.Claims, role auth.Role, cmd *ws.Command) error {
+	if err := commandRequireMember(role); err != nil {
+		return err
+	}
+	if err := h.commandCanView(cmd.SessionID, claims.UserID); err != nil {
+		return err
+	}
+
+	var req VoteRequest
+	if err := commandData(cmd, &req); err != nil {
+		return err
+	}
+	if len(req.RankedResponses) == 0 {
+		return &ws.CommandError{Code: ws.CodeBadRequest, Message: "Ranked responses required"}
+	}
+
+	return h.orchestrator.SubmitUserVote(ctx, cmd.SessionID, claims.UserID, req.RankedResponses)
+}
+
+// cancelCommand stops the user's session, like Cancel
+func (h *CouncilHandler) cancelCommand(ctx context.Context, claims *auth.Claims, role auth.Role, cmd *ws.Command) error {
+	if err := commandRequireMember(role); err != nil {
+		return err
+	}
+	if err := h.commandRequireOwner(ctx, cmd.SessionID, claims.UserID, "Cannot cancel another user's session"); err != nil {
+		return err
+	}
+	err := h.orchestrator.CancelSession(ctx, cmd.SessionID)
+	if errors.Is(err, council.ErrSessionFinished) {
+		return &ws.CommandError{Code: ws.CodeBadRequest, Message: "Session already finished"}
+	}
+	return err
+}
+
+// followUpCommand starts a council that continues the user's session with
+// a new question. The orchestrator cannot change a running council, so the
+// follow-up runs as its own session; subscribe to it to watch.
+func (h *CouncilHandler) followUpCommand(ctx context.Context, claims *auth.Claims, role auth.Role, cmd *ws.Command) (interface{}, error) {
+	if err := commandRequireMember(role); err != nil {
+		return nil, err
+	}
+	if err := h.commandRequireOwner(ctx, cmd.SessionID, claims.UserID, "Cannot follow up on another user's session"); err != nil {
+		return nil, err
+	}
+	if claims.AccessToken == "" {
+		return nil, &ws.CommandError{Code: ws.CodeForbidden, Message: "GitHub Copilot access required. Please log out and log in again."}
+	}
+
+	var req FollowUpCommand
+	if err := commandData(cmd, &req); err != nil {
+		return nil, err
+	}
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		return nil, &ws.CommandError{Code: ws.CodeBadRequest, Message: "question is required"}
+	}
+
+	startReq, err := h.orchestrator.FollowUpRequest(ctx, cmd.SessionID, question)
+	if err != nil {
+		return nil, &ws.CommandError{Code: ws.CodeBadRequest, Message: err.Error()}
+	}
+	session, err := h.orchestrator.StartSession(ctx, claims.UserID, claims.AccessToken, startReq)
+	if err != nil {
+		log.Printf("[COUNCIL] Failed to start follow-up of session %s for user %s: %v", cmd.SessionID, claims.UserID, err)
+		return nil, &ws.CommandError{Code: ws.CodeBadRequest, Message: err.Error()}
+	}
+
+	log.Printf("[COUNCIL] Follow-up session %s started from %s", session.ID, cmd.SessionID)
+	return map[string]interface{}{
+		"session_id": session.ID,
+		"status":     session.Status,
+		"ws_url":     "/ws/council/" + session.ID,
+	}, nil
+}
+
+// commandCanView allows the owner and users the session is shared with
+func (h *CouncilHandler) commandCanView(sessionID, userID string) error {
+	allowed, err := h.orchestrator.CanView(sessionID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return &ws.CommandError{Code: ws.CodeNotFound, Message: "Session not found"}
+	}
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &ws.CommandError{Code: ws.CodeForbidden, Message: "Not allowed to watch this session"}
+	}
+	return nil
+}
+
+// commandRequireOwner allows only the session's owner
+func (h *CouncilHandler) commandRequireOwner(ctx context.Context, sessionID, userID, message string) error {
+	session, err := h.orchestrator.GetSession(ctx, sessionID)
+	if err != nil {
+		return &ws.CommandError{Code: ws.CodeNotFound, Message: "Session not found"}
+	}
+	if session.UserID != userID {
+		return &ws.CommandError{Code: ws.CodeForbidden, Message: message}
+	}
+	return nil
+}
+
+// revalidateError turns a failed Revalidate into the command's error
+func revalidateError(claims *auth.Claims, err error) error {
+	switch {
+	case errors.Is(err, auth.ErrSessionInvalid):
+		return &ws.CommandError{Code: ws.CodeUnauthorized, Message: "Session expired"}
+	case errors.Is(err, auth.ErrAccessDenied):
+		return &ws.CommandError{Code: ws.CodeForbidden, Message: "Access revoked: your GitHub account is no longer allowed on this instance"}
+	}
+	log.Printf("[COUNCIL] Failed to revalidate socket of user %s: %v", claims.UserID, err)
+	return &ws.CommandError{Code: ws.CodeInternal, Message: "Could not verify access"}
+}
+
+func commandRequireMember(role auth.Role) error {
+	if !role.AtLeast(auth.RoleMember) {
+		return &ws.CommandError{Code: ws.CodeForbidden, Message: "This action requires the member role"}
+	}
+	return nil
+}
+
+// commandData decodes a command's data into v
+func commandData(cmd *ws.Command, v interface{}) error {
+	if len(cmd.Data) == 0 {
+		return &ws.CommandError{Code: ws.CodeBadRequest, Message: "Command data required"}
+	}
+	if err := json.Unmarshal(cmd.Data, v); err != nil {
+		return &ws.CommandError{Code: ws.CodeBadRequest, Message: "Invalid command data"}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	fastws "github.com/fasthttp/websocket"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/oauth2"
+
+	"github.com/sainaif/council/internal/config"
+	"github.com/sainaif/council/internal/database"
+	"github.com/sainaif/council/internal/middleware"
+	"github.com/sainaif/council/internal/services/auth"
+	"github.com/sainaif/council/internal/services/classifier"
+	"github.com/sainaif/council/internal/services/copilot"
+	"github.com/sainaif/council/internal/services/council"
+	"github.com/sainaif/council/internal/services/elo"
+	"github.com/sainaif/council/internal/services/judge"
+	"github.com/sainaif/council/internal/store"
+	ws "github.com/sainaif/council/internal/websocket"
+)
+
+type socketEnv struct {
+	db    *database.DB
+	roles *auth.RoleService
+	url   string
+	jwt   string
+	sid   string
+}
+
+// newSocketEnv serves the session WebSocket like the route setup and signs
+// in user 42, who owns session s1
+func newSocketEnv(t *testing.T) *socketEnv {
+	t.Helper()
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	cfg := &config.Config{SessionSecret: "test-secret", DefaultRole: "member"}
+	gh := auth.NewGitHubAuth(cfg)
+	vault, err := auth.NewVault(db, gh, cfg)
+	if err != nil {
+		t.Fatalf("NewVault: %v", err)
+	}
+	roles := auth.NewRoleService(db, gh, cfg)
+	authMw := middleware.NewAuthMiddleware(cfg.SessionSecret, vault, auth.NewAPITokens(db), roles, auth.NewAccessPolicy(db, gh, roles, cfg))
+
+	users := store.NewUserStore(db)
+	sessions := store.NewSessionStore(db)
+	if err := users.Save(store.User{ID: "42", Username: "bob"}); err != nil {
+		t.Fatalf("save user: %v", err)
+	}
+	if err := sessions.Create(&store.Session{ID: "s1", UserID: "42", Question: "Why?", Mode: "standard", Status: "pending", Config: "{}"}); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	hub := ws.NewHub(ws.NewEventLog(db, 1000, time.Hour), sessions, ws.HubConfig{PingInterval: time.Minute, PongTimeout: time.Minute})
+	go hub.Run()
+	t.Cleanup(hub.Shutdown)
+	copilotService := copilot.NewService()
+	orchestrator := council.NewOrchestrator(database.NewLeases(db, "test"), sessions, store.NewModelStore(db, 1500), store.NewCategoryStore(db), users,
+		copilotService, elo.NewCalculator(db, cfg.Elo), judge.NewTracker(db), classifier.NewService(db, copilotService, cfg.Classifier), hub)
+	councilHandler := NewCouncilHandler(orchestrator, sessions, store.NewCategoryStore(db), authMw)
+	hubHandler := NewHubHandler(hub)
+
+	app := fiber.New()
+	app.Get("/ws/council/:id", authMw.RequiredWS(), councilHandler.AuthorizeStream, websocket.New(hubHandler.SessionSocket))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = app.Listener(ln) }()
+	t.Cleanup(func() { _ = app.Shutdown() })
+
+	sid, err := vault.Create("42", &oauth2.Token{AccessToken: "gho_test"}, auth.SessionMeta{})
+	if err != nil {
+		t.Fatalf("create vault session: %v", err)
+	}
+	jwt, err := gh.CreateToken(&auth.GitHubUser{ID: 42, Login: "bob"}, sid)
+	if err != nil {
+		t.Fatalf("create JWT: %v", err)
+	}
+
+	return &socketEnv{db: db, roles: roles, url: "ws://" + ln.Addr().String(), jwt: jwt, sid: sid}
+}
+
+func (e *socketEnv) dial(t *testing.T, path string) *fastws.Conn {
+	t.Helper()
+	header := http.Header{"Authorization": {"Bearer " + e.jwt}}
+	conn, _, err := fastws.DefaultDialer.Dial(e.url+path, header)
+	if err != nil {
+		t.Fatalf("dial %s: %v", path, err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+type commandReply struct {
+	ID    string          `json:"id"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// send runs a command and returns its reply, skipping session events
+func send(t *testing.T, conn *fastws.Conn, id, cmdType string, data interface{}) commandReply {
+	t.Helper()
+	raw, _ := json.Marshal(data)
+	if err := conn.WriteJSON(ws.Command{ID: id, Type: cmdType, Data: raw}); err != nil {
+		t.Fatalf("send %s: %v", cmdType, err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var r commandReply
+		if err := conn.ReadJSON(&r); err != nil {
+			t.Fatalf("read reply to %s: %v", cmdType, err)
+		}
+		if r.ID == id {
+			return r
+		}
+	}
+}
+
+func errorCode(t *testing.T, r commandReply) string {
+	t.Helper()
+	if r.Event != ws.EventCommandError {
+		return ""
+	}
+	var cmdErr ws.CommandError
+	if err := json.Unmarshal(r.Data, &cmdErr); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	return cmdErr.Code
+}
+
+func TestSocketCommandsRevalidate(t *testing.T) {
+	env := newSocketEnv(t)
+	conn := env.dial(t, "/ws/council/s1")
+	vote := VoteRequest{RankedResponses: []string{"Response A", "Response B"}}
+
+	if r := send(t, conn, "1", ws.CommandVote, vote); r.Event != ws.EventCommandAck {
+		t.Fatalf("vote = %s %s, want an ack", r.Event, r.Data)
+	}
+
+	// Demoted to viewer while the socket stays open
+	if err := env.roles.Set("42", auth.RoleViewer); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if code := errorCode(t, send(t, conn, "2", ws.CommandVote, vote)); code != ws.CodeForbidden {
+		t.Errorf("vote as viewer = %q, want %s", code, ws.CodeForbidden)
+	}
+	if err := env.roles.Set("42", auth.RoleMember); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Signed out everywhere: the vault session is revoked
+	if _, err := env.db.Exec(`UPDATE auth_sessions SET access_token_enc = NULL, revoked_at = CURRENT_TIMESTAMP WHERE id = ?`, env.sid); err != nil {
+		t.Fatalf("revoke session: %v", err)
+	}
+	for i, cmdType := range []string{ws.CommandVote, ws.CommandCancel, ws.CommandInjectFollowUp, ws.CommandSubscribe} {
+		r := send(t, conn, strings.Repeat("x", i+3), cmdType, map[string]string{"question": "And?"})
+		if code := errorCode(t, r); code != ws.CodeUnauthorized {
+			t.Errorf("%s after revoking the session = %q, want %s", cmdType, code, ws.CodeUnauthorized)
+		}
+	}
+
+	var votes int
+	if err := env.db.QueryRow(`SELECT COUNT(*) FROM votes WHERE session_id = 's1'`).Scan(&votes); err != nil {
+		t.Fatalf("count votes: %v", err)
+	}
+	if votes != 1 {
+		t.Errorf("session has %d votes, want only the one sent while signed in", votes)
+	}
+}
@@ -1,22 +1,27 @@
 package handlers
 
 import (
+	"errors"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 
-	"github.com/sainaif/council/internal/database"
 	"github.com/sainaif/council/internal/middleware"
 	"github.com/sainaif/council/internal/services/council"
+	"github.com/sainaif/council/internal/store"
 )
 
 type CouncilHandler struct {
 	orchestrator *council.Orchestrator
-	db           *database.DB
+	sessions     store.SessionStore
+	categories   store.CategoryStore
+	authMw       *middleware.AuthMiddleware
 }
 
-func NewCouncilHandler(orchestrator *council.Orchestrator, db *database.DB) *CouncilHandler {
-	return &CouncilHandler{orchestrator: orchestrator, db: db}
+func NewCouncilHandler(orchestrator *council.Orchestrator, sessions store.SessionStore, categories store.CategoryStore, authMw *middleware.AuthMiddleware) *CouncilHandler {
+	return &CouncilHandler{orchestrator: orchestrator, sessions: sessions, categories: categories, authMw: authMw}
 }
 
 type StartCouncilRequest struct {
@@ -101,6 +106,21 @@ func (h *CouncilHandler) Get(c *fiber.Ctx) error {
 		})
 	}
 
+	// Only the owner and users it was shared with may read a session
+	allowed, err := h.orchestrator.CanView(sessionID, middleware.GetUserID(c))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Session not found",
+		})
+	}
+	if !allowed {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":   true,
+			"message": "Not allowed to view this session",
+		})
+	}
+
 	session, err := h.orchestrator.GetSession(c.Context(), sessionID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -135,6 +155,28 @@ func (h *CouncilHandler) Vote(c *fiber.Ctx) error {
 		})
 	}
 
+	// Only the owner and users it was shared with may vote on a session
+	allowed, err := h.orchestrator.CanView(sessionID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Session not found",
+		})
+	}
+	if err != nil {
+		log.Printf("[COUNCIL] Failed to authorize vote on session %s: %v", sessionID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Could not verify access",
+		})
+	}
+	if !allowed {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":   true,
+			"message": "Not allowed to vote on this session",
+		})
+	}
+
 	if err := h.orchestrator.SubmitUserVote(c.Context(), sessionID, userID, req.RankedResponses); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
@@ -197,7 +239,15 @@ func (h *CouncilHandler) Cancel(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.orchestrator.CancelSession(c.Context(), sessionID); err != nil {
+	err = h.orchestrator.CancelSession(c.Context(), sessionID)
+	if errors.Is(err, council.ErrSessionFinished) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "Session already finished",
+		})
+	}
+	if err != nil {
+		log.Printf("[COUNCIL] Failed to cancel session %s: %v", sessionID, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
 			"message": "Failed to cancel session",
@@ -210,6 +260,73 @@ func (h *CouncilHandler) Cancel(c *fiber.Ctx) error {
 	})
 }
 
+type SessionCategoryRequest struct {
+	CategoryID *int64 `json:"category_id"` // null marks the session uncategorized
+}
+
+// SetCategory overrides the category of the user's session, re-attributing
+// its rating changes if it was already rated
+func (h *CouncilHandler) SetCategory(c *fiber.Ctx) error {
+	sessionID := c.Params("id")
+	userID := middleware.GetUserID(c)
+
+	var req SessionCategoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	session, err := h.orchestrator.GetSession(c.Context(), sessionID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Session not found",
+		})
+	}
+
+	// Verify ownership
+	if session.UserID != userID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":   true,
+			"message": "Cannot change another user's session",
+		})
+	}
+
+	if req.CategoryID != nil {
+		archived, err := h.categories.Archived(*req.CategoryID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Printf("[COUNCIL] Failed to look up category %d: %v", *req.CategoryID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Failed to update category",
+			})
+		}
+		if err != nil || archived {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "Category not found",
+			})
+		}
+	}
+
+	reattributed, err := h.orchestrator.OverrideCategory(c.Context(), sessionID, req.CategoryID)
+	if err != nil {
+		log.Printf("[COUNCIL] Failed to override category for session %s: %v", sessionID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to update category",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":              true,
+		"category_id":          req.CategoryID,
+		"ratings_reattributed": reattributed,
+	})
+}
+
 // History returns the user's session history
 func (h *CouncilHandler) History(c *fiber.Ctx) error {
 	userID := middleware.GetUserID(c)
@@ -225,14 +342,7 @@ func (h *CouncilHandler) History(c *fiber.Ctx) error {
 		limit = 100
 	}
 
-	sessions := make([]map[string]interface{}, 0)
-	rows, err := h.db.Query(`
-		SELECT id, question, mode, status, created_at, completed_at
-		FROM sessions
-		WHERE user_id = ?
-		ORDER BY created_at DESC
-		LIMIT ?
-	`, userID, limit)
+	sessions, err := h.sessions.History(userID, limit)
 	if err != nil {
 		log.Printf("[COUNCIL] Failed to fetch history for user %s: %v", userID, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -240,31 +350,79 @@ func (h *CouncilHandler) History(c *fiber.Ctx) error {
 			"message": "Failed to fetch history",
 		})
 	}
-	defer func() { _ = rows.Close() }()
 
-	for rows.Next() {
-		var id, question, mode, status string
-		var createdAt string
-		var completedAt *string
-		if err := rows.Scan(&id, &question, &mode, &status, &createdAt, &completedAt); err != nil {
-			continue
+	log.Printf("[COUNCIL] Fetched %d sessions for user %s", len(sessions), userID)
+	return c.JSON(sessions)
+}
+
+// Search finds sessions the user owns or was shared by the text of their
+// questions, responses and syntheses
+func (h *CouncilHandler) Search(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   true,
+			"message": "Unauthorized",
+		})
+	}
+
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "q is required",
+		})
+	}
+
+	query := store.SearchQuery{
+		Text:     text,
+		UserID:   userID,
+		Mode:     c.Query("mode"),
+		Status:   c.Query("status"),
+		ModelID:  c.Query("model"),
+		Category: c.Query("category"),
+		Limit:    c.QueryInt("limit", 20),
+		Offset:   c.QueryInt("offset", 0),
+	}
+	if query.Limit < 1 || query.Limit > 100 {
+		query.Limit = 20
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(store.DateFormat, v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "from must be a date (YYYY-MM-DD)",
+			})
+		}
+		query.From = t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(store.DateFormat, v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "to must be a date (YYYY-MM-DD)",
+			})
 		}
+		query.Until = t.AddDate(0, 0, 1) // to is inclusive
+	}
 
-		// Get response count for this session
-		var responseCount int
-		_ = h.db.QueryRow(`SELECT COUNT(*) FROM responses WHERE session_id = ?`, id).Scan(&responseCount)
-
-		sessions = append(sessions, map[string]interface{}{
-			"id":             id,
-			"question":       question,
-			"mode":           mode,
-			"status":         status,
-			"created_at":     createdAt,
-			"completed_at":   completedAt,
-			"response_count": responseCount,
+	results, err := h.sessions.Search(query)
+	if err != nil {
+		log.Printf("[COUNCIL] Failed to search sessions for user %s: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to search sessions",
 		})
 	}
 
-	log.Printf("[COUNCIL] Fetched %d sessions for user %s", len(sessions), userID)
-	return c.JSON(sessions)
+	return c.JSON(fiber.Map{
+		"results": results,
+		"limit":   query.Limit,
+		"offset":  query.Offset,
+	})
 }
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/sainaif/council/internal/middleware"
+	ws "github.com/sainaif/council/internal/websocket"
+)
+
+// HubHandler serves live session events over WebSockets and Server-Sent
+// Events, and the hub's admin views
+type HubHandler struct {
+	hub *ws.Hub
+}
+
+func NewHubHandler(hub *ws.Hub) *HubHandler {
+	return &HubHandler{hub: hub}
+}
+
+// SessionSocket streams a session's events to the owner and users it was
+// shared with. ?last_seq=N replays the events after N (0 for all of them).
+func (h *HubHandler) SessionSocket(c *websocket.Conn) {
+	if rejectSocket(h.hub, c) {
+		return
+	}
+
+	userID, _ := c.Locals("userID").(string)
+	commands, _ := c.Locals("wsCommands").(ws.CommandFunc)
+	h.hub.HandleConnection(c, c.Params("id"), userID, parseLastSeq(c.Query("last_seq")), commands)
+}
+
+// UserSocket streams all of the user's sessions on one socket: lifecycle
+// events, or every event with ?streams=all
+func (h *HubHandler) UserSocket(c *websocket.Conn) {
+	if rejectSocket(h.hub, c) {
+		return
+	}
+
+	userID, _ := c.Locals("userID").(string)
+	commands, _ := c.Locals("wsCommands").(ws.CommandFunc)
+	h.hub.HandleUserConnection(c, userID, c.Query("streams") == "all", commands)
+}
+
+// rejectSocket closes an upgraded connection that was refused before the
+// upgrade, with the close code chosen then
+func rejectSocket(hub *ws.Hub, c *websocket.Conn) bool {
+	code, _ := c.Locals("wsCloseCode").(int)
+	if code == 0 {
+		return false
+	}
+	reason, _ := c.Locals("wsCloseReason").(string)
+	hub.Reject(c, code, reason)
+	return true
+}
+
+// Events is the Server-Sent Events alternative to the session WebSocket.
+// Browsers resume with Last-Event-ID; ?last_seq=N works like on the WebSocket.
+func (h *HubHandler) Events(c *fiber.Ctx) error {
+	sessionID := c.Params("id")
+	userID := middleware.GetUserID(c)
+
+	lastSeq := parseLastSeq(c.Get("Last-Event-ID"))
+	if lastSeq < 0 {
+		lastSeq = parseLastSeq(c.Query("last_seq"))
+	}
+
+	client, err := h.hub.Subscribe(sessionID, userID, c.IP(), lastSeq)
+	if errors.Is(err, ws.ErrHubClosed) {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error":   true,
+			"message": "Server is shutting down",
+		})
+	}
+	if err != nil {
+		log.Printf("[EVENTS] Refused event stream of session %s for user %s: %v", sessionID, userID, err)
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error":   true,
+			"message": "Too many live connections",
+		})
+	}
+
+	return h.hub.StreamSSE(c, client)
+}
+
+// Metrics returns the hub's counters
+func (h *HubHandler) Metrics(c *fiber.Ctx) error {
+	return c.JSON(h.hub.Metrics())
+}
+
+// Stats returns the hub's counters along with every connected client
+func (h *HubHandler) Stats(c *fiber.Ctx) error {
+	return c.JSON(h.hub.Stats())
+}
+
+// parseLastSeq reads the sequence number a client resumes after, or -1 when
+// it is missing or invalid so only new events are sent
+func parseLastSeq(v string) int64 {
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+		return n
+	}
+	return -1
+}
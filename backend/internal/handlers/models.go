@@ -1,22 +1,24 @@
 package handlers
 
 import (
-	"database/sql"
+	"log"
 
 	"github.com/gofiber/fiber/v2"
 
-	"github.com/sainaif/council/internal/database"
 	"github.com/sainaif/council/internal/middleware"
 	"github.com/sainaif/council/internal/services/copilot"
+	"github.com/sainaif/council/internal/services/lifecycle"
+	"github.com/sainaif/council/internal/store"
 )
 
 type ModelHandler struct {
-	db      *database.DB
-	copilot *copilot.Service
+	models    store.ModelStore
+	copilot   *copilot.Service
+	lifecycle *lifecycle.Service
 }
 
-func NewModelHandler(db *database.DB, copilot *copilot.Service) *ModelHandler {
-	return &ModelHandler{db: db, copilot: copilot}
+func NewModelHandler(models store.ModelStore, copilot *copilot.Service, lifecycle *lifecycle.Service) *ModelHandler {
+	return &ModelHandler{models: models, copilot: copilot, lifecycle: lifecycle}
 }
 
 type ModelResponse struct {
@@ -32,6 +34,18 @@ type ModelResponse struct {
 	Capabilities []string `json:"capabilities,omitempty"`
 }
 
+// setStats fills in the model's overall record
+func (mr *ModelResponse) setStats(stats store.ModelStats) {
+	mr.Rating = stats.Rating
+	mr.Wins = stats.Wins
+	mr.Losses = stats.Losses
+	mr.Draws = stats.Draws
+	mr.GamesPlayed = stats.Wins + stats.Losses + stats.Draws
+	if mr.GamesPlayed > 0 {
+		mr.WinRate = float64(stats.Wins) / float64(mr.GamesPlayed)
+	}
+}
+
 func (h *ModelHandler) List(c *fiber.Ctx) error {
 	// Get user's access token from JWT claims
 	claims := middleware.GetClaims(c)
@@ -51,42 +65,34 @@ func (h *ModelHandler) List(c *fiber.Ctx) error {
 		})
 	}
 
+	// Keep offered models active so they are not retired
+	seen := make([]string, len(models))
+	for i, m := range models {
+		seen[i] = m.ID
+	}
+	if err := h.lifecycle.MarkSeen(seen); err != nil {
+		log.Printf("[MODELS] Failed to mark models as seen: %v", err)
+	}
+
 	// Enrich with ratings from database
 	var response []ModelResponse
 	for _, m := range models {
+		stats, err := h.models.Stats(m.ID)
+		if err != nil {
+			log.Printf("[MODELS] Failed to get stats for model %s: %v", m.ID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Failed to get model ratings",
+			})
+		}
+
 		mr := ModelResponse{
 			ID:           m.ID,
 			DisplayName:  m.DisplayName,
 			Provider:     m.Provider,
-			Rating:       1500, // Default
 			Capabilities: m.Capabilities,
 		}
-
-		// Get aggregated stats
-		var wins, losses, draws int
-		err := h.db.QueryRow(`
-			SELECT COALESCE(SUM(wins), 0), COALESCE(SUM(losses), 0), COALESCE(SUM(draws), 0)
-			FROM model_ratings WHERE model_id = ?
-		`, m.ID).Scan(&wins, &losses, &draws)
-		if err == nil {
-			mr.Wins = wins
-			mr.Losses = losses
-			mr.Draws = draws
-			mr.GamesPlayed = wins + losses + draws
-			if mr.GamesPlayed > 0 {
-				mr.WinRate = float64(wins) / float64(mr.GamesPlayed)
-			}
-		}
-
-		// Get average rating
-		var avgRating sql.NullFloat64
-		_ = h.db.QueryRow(`
-			SELECT AVG(rating) FROM model_ratings WHERE model_id = ?
-		`, m.ID).Scan(&avgRating)
-		if avgRating.Valid {
-			mr.Rating = int(avgRating.Float64)
-		}
-
+		mr.setStats(stats)
 		response = append(response, mr)
 	}
 
@@ -120,65 +126,31 @@ func (h *ModelHandler) Get(c *fiber.Ctx) error {
 		})
 	}
 
+	categoryStats, err := h.models.CategoryStats(modelID)
+	if err != nil {
+		log.Printf("[MODELS] Failed to get category stats for model %s: %v", modelID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to get model stats",
+		})
+	}
+
+	stats, err := h.models.Stats(modelID)
+	if err != nil {
+		log.Printf("[MODELS] Failed to get stats for model %s: %v", modelID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to get model stats",
+		})
+	}
+
 	mr := ModelResponse{
 		ID:           model.ID,
 		DisplayName:  model.DisplayName,
 		Provider:     model.Provider,
-		Rating:       1500,
 		Capabilities: model.Capabilities,
 	}
-
-	// Get stats per category
-	type CategoryStats struct {
-		CategoryID   int     `json:"category_id"`
-		CategoryName string  `json:"category_name"`
-		Rating       int     `json:"rating"`
-		Wins         int     `json:"wins"`
-		Losses       int     `json:"losses"`
-		Draws        int     `json:"draws"`
-		WinRate      float64 `json:"win_rate"`
-	}
-
-	var categoryStats []CategoryStats
-	rows, err := h.db.Query(`
-		SELECT c.id, c.name, COALESCE(mr.rating, 1500), COALESCE(mr.wins, 0),
-			   COALESCE(mr.losses, 0), COALESCE(mr.draws, 0)
-		FROM categories c
-		LEFT JOIN model_ratings mr ON c.id = mr.category_id AND mr.model_id = ?
-	`, modelID)
-	if err == nil {
-		defer func() { _ = rows.Close() }()
-		for rows.Next() {
-			var cs CategoryStats
-			_ = rows.Scan(&cs.CategoryID, &cs.CategoryName, &cs.Rating, &cs.Wins, &cs.Losses, &cs.Draws)
-			total := cs.Wins + cs.Losses + cs.Draws
-			if total > 0 {
-				cs.WinRate = float64(cs.Wins) / float64(total)
-			}
-			categoryStats = append(categoryStats, cs)
-		}
-	}
-
-	// Get overall stats
-	var wins, losses, draws int
-	_ = h.db.QueryRow(`
-		SELECT COALESCE(SUM(wins), 0), COALESCE(SUM(losses), 0), COALESCE(SUM(draws), 0)
-		FROM model_ratings WHERE model_id = ?
-	`, modelID).Scan(&wins, &losses, &draws)
-
-	mr.Wins = wins
-	mr.Losses = losses
-	mr.Draws = draws
-	mr.GamesPlayed = wins + losses + draws
-	if mr.GamesPlayed > 0 {
-		mr.WinRate = float64(wins) / float64(mr.GamesPlayed)
-	}
-
-	var avgRating sql.NullFloat64
-	_ = h.db.QueryRow(`SELECT AVG(rating) FROM model_ratings WHERE model_id = ?`, modelID).Scan(&avgRating)
-	if avgRating.Valid {
-		mr.Rating = int(avgRating.Float64)
-	}
+	mr.setStats(stats)
 
 	return c.JSON(fiber.Map{
 		"model":          mr,
@@ -200,45 +172,14 @@ func (h *ModelHandler) History(c *fiber.Ctx) error {
 		limit = 100
 	}
 
-	type HistoryEntry struct {
-		SessionID  string `json:"session_id"`
-		CategoryID *int64 `json:"category_id,omitempty"`
-		OldRating  int    `json:"old_rating"`
-		NewRating  int    `json:"new_rating"`
-		Change     int    `json:"change"`
-		Reason     string `json:"reason"`
-		CreatedAt  string `json:"created_at"`
-	}
-
-	var history []HistoryEntry
-	rows, err := h.db.Query(`
-		SELECT session_id, category_id, old_rating, new_rating, change, reason, created_at
-		FROM elo_history
-		WHERE model_id = ?
-		ORDER BY created_at DESC
-		LIMIT ?
-	`, modelID, limit)
+	history, err := h.models.History(modelID, limit)
 	if err != nil {
+		log.Printf("[MODELS] Failed to get history for model %s: %v", modelID, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
 			"message": "Failed to get history",
 		})
 	}
-	defer func() { _ = rows.Close() }()
-
-	for rows.Next() {
-		var h HistoryEntry
-		var categoryID sql.NullInt64
-		var sessionID sql.NullString
-		_ = rows.Scan(&sessionID, &categoryID, &h.OldRating, &h.NewRating, &h.Change, &h.Reason, &h.CreatedAt)
-		if sessionID.Valid {
-			h.SessionID = sessionID.String
-		}
-		if categoryID.Valid {
-			h.CategoryID = &categoryID.Int64
-		}
-		history = append(history, h)
-	}
 
 	return c.JSON(history)
 }
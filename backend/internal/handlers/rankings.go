@@ -1,19 +1,50 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 
-	"github.com/sainaif/council/internal/database"
+	"github.com/sainaif/council/internal/middleware"
+	"github.com/sainaif/council/internal/services/judge"
+	"github.com/sainaif/council/internal/store"
+)
+
+// seriesResolutions maps a resolution name to the label of a time's bucket
+var seriesResolutions = map[string]func(time.Time) string{
+	"hour":  func(t time.Time) string { return t.Format("2006-01-02 15:00") },
+	"day":   func(t time.Time) string { return t.Format(store.DateFormat) },
+	"week":  weekBucket,
+	"month": func(t time.Time) string { return t.Format("2006-01") },
+}
+
+// weekBucket labels a time with its year and week number, weeks starting on
+// Monday and days before the first Monday in week 00
+func weekBucket(t time.Time) string {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return fmt.Sprintf("%d-W%02d", t.Year(), (t.YearDay()+6-daysSinceMonday)/7)
+}
+
+// Rating scopes: the instance-wide leaderboard or the user's personal one
+const (
+	ScopeGlobal = "global"
+	ScopeMe     = "me"
 )
 
 type RankingHandler struct {
-	db *database.DB
+	ratings    store.RatingStore
+	models     store.ModelStore
+	categories store.CategoryStore
+	settings   store.SettingsStore
+	judges     *judge.Tracker
 }
 
-func NewRankingHandler(db *database.DB) *RankingHandler {
-	return &RankingHandler{db: db}
+func NewRankingHandler(ratings store.RatingStore, models store.ModelStore, categories store.CategoryStore, settings store.SettingsStore, judges *judge.Tracker) *RankingHandler {
+	return &RankingHandler{ratings: ratings, models: models, categories: categories, settings: settings, judges: judges}
 }
 
 type RankingEntry struct {
@@ -28,6 +59,70 @@ type RankingEntry struct {
 	WinRate     float64 `json:"win_rate"`
 	GamesPlayed int     `json:"games_played"`
 	Trend       int     `json:"trend"` // Recent rating change
+	IsActive    bool    `json:"is_active"`
+}
+
+// rankStandings numbers leaderboard standings in order
+func rankStandings(standings []store.Standing) []RankingEntry {
+	rankings := make([]RankingEntry, 0, len(standings))
+	for i, st := range standings {
+		e := RankingEntry{
+			Rank:        i + 1,
+			ModelID:     st.ModelID,
+			DisplayName: st.DisplayName,
+			Provider:    st.Provider,
+			Rating:      st.Rating,
+			Wins:        st.Wins,
+			Losses:      st.Losses,
+			Draws:       st.Draws,
+			GamesPlayed: st.Wins + st.Losses + st.Draws,
+			Trend:       st.Trend,
+			IsActive:    st.IsActive,
+		}
+		if e.GamesPlayed > 0 {
+			e.WinRate = float64(e.Wins) / float64(e.GamesPlayed)
+		}
+		rankings = append(rankings, e)
+	}
+	return rankings
+}
+
+// resolveStatus returns the model status filter from the query, which
+// defaults to active models only
+func resolveStatus(c *fiber.Ctx) (store.ModelStatus, error) {
+	switch status := store.ModelStatus(c.Query("status", string(store.ModelsActive))); status {
+	case store.ModelsActive, store.ModelsInactive, store.ModelsAll:
+		return status, nil
+	}
+	return "", fiber.NewError(fiber.StatusBadRequest, "status must be 'active', 'inactive' or 'all'")
+}
+
+// resolveScope returns the rating scope from the query, falling back to the
+// user's stored preference
+func (h *RankingHandler) resolveScope(c *fiber.Ctx) (string, error) {
+	scope := c.Query("scope")
+	if scope == "" {
+		settings, err := h.settings.Get(middleware.GetUserID(c))
+		if err != nil {
+			log.Printf("[RANKINGS] Failed to get rating scope preference: %v", err)
+			return "", fiber.NewError(fiber.StatusInternalServerError, "Failed to get rankings")
+		}
+		scope = settings.RatingScope
+	}
+
+	if scope != ScopeGlobal && scope != ScopeMe {
+		return "", fiber.NewError(fiber.StatusBadRequest, "scope must be 'me' or 'global'")
+	}
+	return scope, nil
+}
+
+// scopeUser returns the user whose ratings a scope selects, or "" for the
+// instance-wide ratings
+func scopeUser(c *fiber.Ctx, scope string) string {
+	if scope == ScopeMe {
+		return middleware.GetUserID(c)
+	}
+	return ""
 }
 
 func (h *RankingHandler) Global(c *fiber.Ctx) error {
@@ -36,56 +131,203 @@ func (h *RankingHandler) Global(c *fiber.Ctx) error {
 		limit = 100
 	}
 
-	rankings := make([]RankingEntry, 0) // Initialize as empty slice, not nil
-	rows, err := h.db.Query(`
-		SELECT
-			m.id, m.display_name, m.provider,
-			COALESCE(AVG(mr.rating), 1500) as avg_rating,
-			COALESCE(SUM(mr.wins), 0) as wins,
-			COALESCE(SUM(mr.losses), 0) as losses,
-			COALESCE(SUM(mr.draws), 0) as draws
-		FROM models m
-		LEFT JOIN model_ratings mr ON m.id = mr.model_id
-		WHERE m.is_active = 1
-		GROUP BY m.id
-		ORDER BY avg_rating DESC
-		LIMIT ?
-	`, limit)
+	scope, err := h.resolveScope(c)
+	if err != nil {
+		return err
+	}
+	status, err := resolveStatus(c)
 	if err != nil {
+		return err
+	}
+
+	standings, err := h.ratings.Leaderboard(store.LeaderboardQuery{
+		UserID: scopeUser(c, scope),
+		Status: status,
+		Limit:  limit,
+	})
+	if err != nil {
+		log.Printf("[RANKINGS] Failed to get rankings: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
 			"message": "Failed to get rankings",
 		})
 	}
-	defer func() { _ = rows.Close() }()
 
-	rank := 1
-	for rows.Next() {
-		var e RankingEntry
-		var avgRating float64
-		_ = rows.Scan(&e.ModelID, &e.DisplayName, &e.Provider, &avgRating, &e.Wins, &e.Losses, &e.Draws)
-		e.Rating = int(avgRating)
-		e.Rank = rank
-		e.GamesPlayed = e.Wins + e.Losses + e.Draws
-		if e.GamesPlayed > 0 {
-			e.WinRate = float64(e.Wins) / float64(e.GamesPlayed)
+	return c.JSON(rankStandings(standings))
+}
+
+// Judges returns the judge leaderboard ordered by voting reliability
+func (h *RankingHandler) Judges(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 20)
+	if limit > 100 {
+		limit = 100
+	}
+
+	judges, err := h.judges.Leaderboard(limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to get judge rankings",
+		})
+	}
+
+	return c.JSON(judges)
+}
+
+// History returns rating time series for several models and categories.
+// Each point holds the last rating within the bucket plus the net change.
+func (h *RankingHandler) History(c *fiber.Ctx) error {
+	models := splitList(c.Query("models"))
+	if len(models) == 0 || len(models) > 10 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Between 1 and 10 models required",
+		})
+	}
+
+	resolution := c.Query("resolution", "day")
+	bucketOf, ok := seriesResolutions[resolution]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "resolution must be one of: hour, day, week, month",
+		})
+	}
+
+	from := time.Now().UTC().AddDate(0, 0, -30)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(store.DateFormat, v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "from must be a date (YYYY-MM-DD)",
+			})
+		}
+		from = t
+	}
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(store.DateFormat, v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "to must be a date (YYYY-MM-DD)",
+			})
 		}
+		to = t
+	}
+
+	type Point struct {
+		Bucket string `json:"bucket"`
+		Rating int    `json:"rating"`
+		Change int    `json:"change"`
+		Games  int    `json:"games"`
+	}
+	type Series struct {
+		ModelID    string  `json:"model_id"`
+		CategoryID *int64  `json:"category_id,omitempty"`
+		Category   string  `json:"category"`
+		Points     []Point `json:"points"`
+	}
+
+	changes, err := h.ratings.History(store.HistoryQuery{
+		Models:     models,
+		Categories: splitList(c.Query("categories")),
+		From:       from,
+		Until:      to.AddDate(0, 0, 1), // to is inclusive
+	})
+	if err != nil {
+		log.Printf("[RANKINGS] Failed to get rating history: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to get rating history",
+		})
+	}
 
-		// Get recent trend
-		var recentChange sql.NullInt64
-		_ = h.db.QueryRow(`
-			SELECT SUM(change) FROM elo_history
-			WHERE model_id = ? AND created_at > datetime('now', '-7 days')
-		`, e.ModelID).Scan(&recentChange)
-		if recentChange.Valid {
-			e.Trend = int(recentChange.Int64)
+	series := make([]*Series, 0)
+	var current *Series
+	for _, ch := range changes {
+		bucket := bucketOf(ch.CreatedAt.UTC())
+
+		if current == nil || current.ModelID != ch.ModelID || current.Category != ch.Category {
+			current = &Series{ModelID: ch.ModelID, CategoryID: ch.CategoryID, Category: ch.Category, Points: []Point{}}
+			series = append(series, current)
 		}
 
-		rankings = append(rankings, e)
-		rank++
+		// Rows are chronological, so the last row of a bucket holds its closing rating
+		n := len(current.Points)
+		if n > 0 && current.Points[n-1].Bucket == bucket {
+			current.Points[n-1].Rating = ch.Rating
+			current.Points[n-1].Change += ch.Change
+			current.Points[n-1].Games++
+			continue
+		}
+		current.Points = append(current.Points, Point{Bucket: bucket, Rating: ch.Rating, Change: ch.Change, Games: 1})
 	}
 
-	return c.JSON(rankings)
+	return c.JSON(fiber.Map{
+		"resolution": resolution,
+		"from":       from.Format(store.DateFormat),
+		"to":         to.Format(store.DateFormat),
+		"series":     series,
+	})
+}
+
+// AsOf returns the leaderboard from the latest snapshot on or before a date
+func (h *RankingHandler) AsOf(c *fiber.Ctx) error {
+	date := c.Query("date")
+	if _, err := time.Parse(store.DateFormat, date); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "date must be a date (YYYY-MM-DD)",
+		})
+	}
+
+	limit := c.QueryInt("limit", 20)
+	if limit > 100 {
+		limit = 100
+	}
+
+	snapshotDate, err := h.ratings.LatestSnapshot(date)
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "No leaderboard snapshot on or before " + date,
+		})
+	}
+	if err != nil {
+		log.Printf("[RANKINGS] Failed to find leaderboard snapshot: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to get rankings",
+		})
+	}
+
+	var categoryID *int64
+	category := c.Query("category")
+	if category != "" {
+		id, err := h.categoryID(category)
+		if err != nil {
+			return err
+		}
+		categoryID = &id
+	}
+
+	standings, err := h.ratings.SnapshotLeaderboard(snapshotDate, categoryID, limit)
+	if err != nil {
+		log.Printf("[RANKINGS] Failed to get snapshot rankings: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to get rankings",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"date":          date,
+		"snapshot_date": snapshotDate,
+		"category":      category,
+		"rankings":      rankStandings(standings),
+	})
 }
 
 func (h *RankingHandler) ByCategory(c *fiber.Ctx) error {
@@ -102,54 +344,43 @@ func (h *RankingHandler) ByCategory(c *fiber.Ctx) error {
 		limit = 100
 	}
 
-	// Get category ID
-	var categoryID int64
-	err := h.db.QueryRow(`SELECT id FROM categories WHERE name = ?`, category).Scan(&categoryID)
+	scope, err := h.resolveScope(c)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error":   true,
-			"message": "Category not found",
-		})
+		return err
+	}
+	status, err := resolveStatus(c)
+	if err != nil {
+		return err
 	}
 
-	var rankings []RankingEntry
-	rows, err := h.db.Query(`
-		SELECT
-			m.id, m.display_name, m.provider,
-			COALESCE(mr.rating, 1500),
-			COALESCE(mr.wins, 0),
-			COALESCE(mr.losses, 0),
-			COALESCE(mr.draws, 0)
-		FROM models m
-		LEFT JOIN model_ratings mr ON m.id = mr.model_id AND mr.category_id = ?
-		WHERE m.is_active = 1
-		ORDER BY COALESCE(mr.rating, 1500) DESC
-		LIMIT ?
-	`, categoryID, limit)
+	categoryID, err := h.categoryID(category)
+	if err != nil {
+		return err
+	}
+
+	standings, err := h.ratings.Leaderboard(store.LeaderboardQuery{
+		UserID:     scopeUser(c, scope),
+		CategoryID: &categoryID,
+		Status:     status,
+		Limit:      limit,
+	})
 	if err != nil {
+		log.Printf("[RANKINGS] Failed to get rankings for category %s: %v", category, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
 			"message": "Failed to get rankings",
 		})
 	}
-	defer func() { _ = rows.Close() }()
 
-	rank := 1
-	for rows.Next() {
-		var e RankingEntry
-		_ = rows.Scan(&e.ModelID, &e.DisplayName, &e.Provider, &e.Rating, &e.Wins, &e.Losses, &e.Draws)
-		e.Rank = rank
-		e.GamesPlayed = e.Wins + e.Losses + e.Draws
-		if e.GamesPlayed > 0 {
-			e.WinRate = float64(e.Wins) / float64(e.GamesPlayed)
-		}
-		rankings = append(rankings, e)
-		rank++
+	var rankings []RankingEntry
+	if len(standings) > 0 {
+		rankings = rankStandings(standings)
 	}
 
 	return c.JSON(fiber.Map{
 		"category":    category,
 		"category_id": categoryID,
+		"scope":       scope,
 		"rankings":    rankings,
 	})
 }
@@ -170,77 +401,110 @@ func (h *RankingHandler) HeadToHead(c *fiber.Ctx) error {
 		modelA, modelB = modelB, modelA
 	}
 
-	type MatchupStats struct {
-		CategoryID   *int64  `json:"category_id,omitempty"`
-		CategoryName *string `json:"category_name,omitempty"`
-		ModelAWins   int     `json:"model_a_wins"`
-		ModelBWins   int     `json:"model_b_wins"`
-		Draws        int     `json:"draws"`
-		TotalGames   int     `json:"total_games"`
+	overall, err := h.ratings.Matchup(modelA, modelB)
+	if err != nil {
+		log.Printf("[RANKINGS] Failed to get matchup of %s and %s: %v", modelA, modelB, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to get matchup data",
+		})
 	}
 
-	var overall MatchupStats
-	var byCategory []MatchupStats
-
-	// Get overall matchup
-	err := h.db.QueryRow(`
-		SELECT COALESCE(SUM(model_a_wins), 0), COALESCE(SUM(model_b_wins), 0), COALESCE(SUM(draws), 0)
-		FROM matchups
-		WHERE model_a_id = ? AND model_b_id = ?
-	`, modelA, modelB).Scan(&overall.ModelAWins, &overall.ModelBWins, &overall.Draws)
-	if err != nil && err != sql.ErrNoRows {
+	byCategory, err := h.ratings.MatchupsByCategory(modelA, modelB)
+	if err != nil {
+		log.Printf("[RANKINGS] Failed to get matchups of %s and %s by category: %v", modelA, modelB, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
 			"message": "Failed to get matchup data",
 		})
 	}
-	overall.TotalGames = overall.ModelAWins + overall.ModelBWins + overall.Draws
-
-	// Get matchups by category
-	rows, err := h.db.Query(`
-		SELECT c.id, c.name, COALESCE(m.model_a_wins, 0), COALESCE(m.model_b_wins, 0), COALESCE(m.draws, 0)
-		FROM categories c
-		LEFT JOIN matchups m ON c.id = m.category_id AND m.model_a_id = ? AND m.model_b_id = ?
-	`, modelA, modelB)
-	if err == nil {
-		defer func() { _ = rows.Close() }()
-		for rows.Next() {
-			var ms MatchupStats
-			var catID int64
-			var catName string
-			_ = rows.Scan(&catID, &catName, &ms.ModelAWins, &ms.ModelBWins, &ms.Draws)
-			ms.CategoryID = &catID
-			ms.CategoryName = &catName
-			ms.TotalGames = ms.ModelAWins + ms.ModelBWins + ms.Draws
-			byCategory = append(byCategory, ms)
-		}
-	}
 
-	// Get model info
-	type ModelInfo struct {
-		ID          string `json:"id"`
-		DisplayName string `json:"display_name"`
-		Provider    string `json:"provider"`
-		Rating      int    `json:"rating"`
+	limit := c.QueryInt("limit", 50)
+	if limit > 100 {
+		limit = 100
 	}
 
-	var infoA, infoB ModelInfo
-	_ = h.db.QueryRow(`
-		SELECT m.id, m.display_name, m.provider, COALESCE(AVG(mr.rating), 1500)
-		FROM models m LEFT JOIN model_ratings mr ON m.id = mr.model_id
-		WHERE m.id = ? GROUP BY m.id
-	`, modelA).Scan(&infoA.ID, &infoA.DisplayName, &infoA.Provider, &infoA.Rating)
+	encounters, err := h.ratings.Encounters(modelA, modelB, limit)
+	if err != nil {
+		log.Printf("[RANKINGS] Failed to get encounters of %s and %s: %v", modelA, modelB, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to get matchup data",
+		})
+	}
 
-	_ = h.db.QueryRow(`
-		SELECT m.id, m.display_name, m.provider, COALESCE(AVG(mr.rating), 1500)
-		FROM models m LEFT JOIN model_ratings mr ON m.id = mr.model_id
-		WHERE m.id = ? GROUP BY m.id
-	`, modelB).Scan(&infoB.ID, &infoB.DisplayName, &infoB.Provider, &infoB.Rating)
+	infoA, err := h.modelInfo(modelA)
+	if err != nil {
+		log.Printf("[RANKINGS] Failed to get model %s: %v", modelA, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to get matchup data",
+		})
+	}
+	infoB, err := h.modelInfo(modelB)
+	if err != nil {
+		log.Printf("[RANKINGS] Failed to get model %s: %v", modelB, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to get matchup data",
+		})
+	}
 
 	return c.JSON(fiber.Map{
 		"model_a":     infoA,
 		"model_b":     infoB,
 		"overall":     overall,
 		"by_category": byCategory,
+		"encounters":  encounters,
 	})
 }
+
+// ModelInfo identifies a model in a head-to-head comparison
+type ModelInfo struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	Provider    string `json:"provider"`
+	Rating      int    `json:"rating"`
+}
+
+// modelInfo describes a model with its overall rating. Models that never
+// took part in a council are left blank.
+func (h *RankingHandler) modelInfo(modelID string) (ModelInfo, error) {
+	model, err := h.models.Get(modelID)
+	if errors.Is(err, store.ErrNotFound) {
+		return ModelInfo{}, nil
+	}
+	if err != nil {
+		return ModelInfo{}, err
+	}
+
+	stats, err := h.models.Stats(modelID)
+	if err != nil {
+		return ModelInfo{}, err
+	}
+	return ModelInfo{ID: model.ID, DisplayName: model.DisplayName, Provider: model.Provider, Rating: stats.Rating}, nil
+}
+
+// categoryID looks up a category by name, answering 404 when it does not exist
+func (h *RankingHandler) categoryID(name string) (int64, error) {
+	id, err := h.categories.IDByName(name)
+	if errors.Is(err, store.ErrNotFound) {
+		return 0, fiber.NewError(fiber.StatusNotFound, "Category not found")
+	}
+	if err != nil {
+		log.Printf("[RANKINGS] Failed to look up category %s: %v", name, err)
+		return 0, fiber.NewError(fiber.StatusInternalServerError, "Failed to get rankings")
+	}
+	return id, nil
+}
+
+// splitList parses a comma-separated query value, dropping empty items
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/sainaif/council/internal/middleware"
+	"github.com/sainaif/council/internal/services/council"
+	"github.com/sainaif/council/internal/store"
+	ws "github.com/sainaif/council/internal/websocket"
+)
+
+type ShareRequest struct {
+	Username string `json:"username"`
+}
+
+// AuthorizeStream runs before the WebSocket upgrade and decides whether the
+// user may watch the session. Refusals are sent as a close code once the
+// connection is upgraded, since browsers cannot read the HTTP status of a
+// failed upgrade.
+func (h *CouncilHandler) AuthorizeStream(c *fiber.Ctx) error {
+	if middleware.WSRejected(c) {
+		return c.Next()
+	}
+
+	sessionID := c.Params("id")
+	userID := middleware.GetUserID(c)
+
+	code, reason := 0, ""
+	allowed, err := h.orchestrator.CanView(sessionID, userID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		code, reason = ws.CloseNotFound, "Session not found"
+	case err != nil:
+		log.Printf("[COUNCIL] Failed to authorize stream for session %s: %v", sessionID, err)
+		code, reason = ws.CloseForbidden, "Could not verify access"
+	case userID == "":
+		code, reason = ws.CloseUnauthorized, "Unauthorized"
+	case !allowed:
+		code, reason = ws.CloseForbidden, "Not allowed to watch this session"
+	}
+
+	c.Locals("wsCloseCode", code)
+	c.Locals("wsCloseReason", reason)
+	if code == 0 {
+		c.Locals("wsCommands", h.Commands(middleware.GetClaims(c)))
+	}
+	return c.Next()
+}
+
+// AuthorizeUserStream runs before the /ws/user upgrade; only signed-in
+// users get a channel for their sessions
+func (h *CouncilHandler) AuthorizeUserStream(c *fiber.Ctx) error {
+	if middleware.WSRejected(c) {
+		return c.Next()
+	}
+
+	code, reason := 0, ""
+	if middleware.GetUserID(c) == "" {
+		code, reason = ws.CloseUnauthorized, "Unauthorized"
+	} else {
+		c.Locals("wsCommands", h.Commands(middleware.GetClaims(c)))
+	}
+
+	c.Locals("wsCloseCode", code)
+	c.Locals("wsCloseReason", reason)
+	return c.Next()
+}
+
+// AuthorizeEvents lets the owner and users the session is shared with
+// through to its Server-Sent Events stream
+func (h *CouncilHandler) AuthorizeEvents(c *fiber.Ctx) error {
+	sessionID := c.Params("id")
+	allowed, err := h.orchestrator.CanView(sessionID, middleware.GetUserID(c))
+	if errors.Is(err, store.ErrNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, "Session not found")
+	}
+	if err != nil {
+		log.Printf("[COUNCIL] Failed to authorize event stream for session %s: %v", sessionID, err)
+		return fiber.NewError(fiber.StatusInternalServerError, "Could not verify access")
+	}
+	if !allowed {
+		return fiber.NewError(fiber.StatusForbidden, "Not allowed to watch this session")
+	}
+	return c.Next()
+}
+
+// Shares lists who the user's session is shared with
+func (h *CouncilHandler) Shares(c *fiber.Ctx) error {
+	sessionID := c.Params("id")
+	if err := h.requireOwner(c, sessionID); err != nil {
+		return err
+	}
+
+	shares, err := h.orchestrator.SessionShares(sessionID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to list shares",
+		})
+	}
+	return c.JSON(shares)
+}
+
+// Share lets another user watch and read the session
+func (h *CouncilHandler) Share(c *fiber.Ctx) error {
+	sessionID := c.Params("id")
+	if err := h.requireOwner(c, sessionID); err != nil {
+		return err
+	}
+
+	var req ShareRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Username) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "username is required",
+		})
+	}
+
+	share, err := h.orchestrator.ShareSession(sessionID, middleware.GetUserID(c), strings.TrimSpace(req.Username))
+	if errors.Is(err, council.ErrUserNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "User has not signed in to this instance",
+		})
+	}
+	if errors.Is(err, council.ErrShareWithOwner) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Cannot share a session with its owner",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to share session",
+		})
+	}
+
+	log.Printf("[COUNCIL] Session %s shared with %s", sessionID, share.Username)
+	return c.Status(fiber.StatusCreated).JSON(share)
+}
+
+// Unshare withdraws another user's access to the session
+func (h *CouncilHandler) Unshare(c *fiber.Ctx) error {
+	sessionID := c.Params("id")
+	if err := h.requireOwner(c, sessionID); err != nil {
+		return err
+	}
+
+	removed, err := h.orchestrator.UnshareSession(sessionID, c.Params("userId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to remove share",
+		})
+	}
+	if !removed {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Share not found",
+		})
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// requireOwner returns an error response unless the current user owns the session
+func (h *CouncilHandler) requireOwner(c *fiber.Ctx, sessionID string) error {
+	session, err := h.sessions.Get(sessionID)
+	if errors.Is(err, store.ErrNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, "Session not found")
+	}
+	if err != nil {
+		log.Printf("[COUNCIL] Failed to look up session %s: %v", sessionID, err)
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to look up session")
+	}
+	if session.UserID != middleware.GetUserID(c) {
+		return fiber.NewError(fiber.StatusForbidden, "Only the session owner can manage sharing")
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/sainaif/council/internal/middleware"
+	"github.com/sainaif/council/internal/services/auth"
+)
+
+const (
+	defaultTokenDays = 30
+	maxTokenDays     = 365
+	maxTokenName     = 100
+)
+
+type TokenHandler struct {
+	tokens *auth.APITokens
+}
+
+func NewTokenHandler(tokens *auth.APITokens) *TokenHandler {
+	return &TokenHandler{tokens: tokens}
+}
+
+type CreateTokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty"`
+}
+
+// List returns the current user's active personal API tokens
+func (h *TokenHandler) List(c *fiber.Ctx) error {
+	tokens, err := h.tokens.List(middleware.GetUserID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to list API tokens",
+		})
+	}
+	return c.JSON(tokens)
+}
+
+// Create mints a personal API token. The token is only shown in this response.
+func (h *TokenHandler) Create(c *fiber.Ctx) error {
+	var req CreateTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxTokenName {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Token name must be between 1 and 100 characters",
+		})
+	}
+
+	var scopes []string
+	seen := make(map[string]bool)
+	for _, scope := range req.Scopes {
+		if !auth.ValidScope(scope) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "Unknown scope: " + scope + " (valid: " + strings.Join(auth.Scopes, ", ") + ")",
+			})
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "At least one scope is required",
+		})
+	}
+
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultTokenDays
+	}
+	if days < 1 || days > maxTokenDays {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "expires_in_days must be between 1 and 365",
+		})
+	}
+
+	plaintext, token, err := h.tokens.Create(middleware.GetUserID(c), req.Name, scopes, time.Duration(days)*24*time.Hour)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to create API token",
+		})
+	}
+	log.Printf("[AUTH] User %s created API token %q with scopes %s", middleware.GetUsername(c), req.Name, strings.Join(scopes, ","))
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"token":     plaintext,
+		"api_token": token,
+	})
+}
+
+// Revoke disables one of the current user's tokens
+func (h *TokenHandler) Revoke(c *fiber.Ctx) error {
+	revoked, err := h.tokens.Revoke(middleware.GetUserID(c), c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to revoke API token",
+		})
+	}
+	if !revoked {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "API token not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{"success": true})
+}
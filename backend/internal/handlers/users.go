@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/sainaif/council/internal/middleware"
+	"github.com/sainaif/council/internal/services/auth"
+	"github.com/sainaif/council/internal/store"
+)
+
+type UserHandler struct {
+	users store.UserStore
+	roles *auth.RoleService
+}
+
+func NewUserHandler(users store.UserStore, roles *auth.RoleService) *UserHandler {
+	return &UserHandler{users: users, roles: roles}
+}
+
+type UserEntry struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	AvatarURL string    `json:"avatar_url"`
+	Role      auth.Role `json:"role"`
+}
+
+type RoleRequest struct {
+	Role auth.Role `json:"role"`
+}
+
+// List returns every user who has signed in, with their effective role
+func (h *UserHandler) List(c *fiber.Ctx) error {
+	users, err := h.entries()
+	if err != nil {
+		log.Printf("[ADMIN] Failed to list users: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to list users",
+		})
+	}
+	return c.JSON(users)
+}
+
+// SetRole changes a user's stored role
+func (h *UserHandler) SetRole(c *fiber.Ctx) error {
+	userID := c.Params("id")
+
+	var req RoleRequest
+	if err := c.BodyParser(&req); err != nil || !req.Role.Valid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Role must be admin, member or viewer",
+		})
+	}
+
+	users, err := h.entries()
+	if err != nil {
+		log.Printf("[ADMIN] Failed to load users: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to load users",
+		})
+	}
+
+	var target *UserEntry
+	admins := 0
+	for i := range users {
+		if users[i].UserID == userID {
+			target = &users[i]
+		}
+		if users[i].Role == auth.RoleAdmin {
+			admins++
+		}
+	}
+	if target == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "User not found",
+		})
+	}
+
+	if target.Role == auth.RoleAdmin && req.Role != auth.RoleAdmin {
+		// Roles from ADMIN_USERS cannot be overridden here
+		if h.roles.IsConfiguredAdmin(target.Username) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   true,
+				"message": "User is an admin through ADMIN_USERS",
+			})
+		}
+		if admins == 1 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   true,
+				"message": "Cannot remove the last admin",
+			})
+		}
+	}
+
+	if err := h.roles.Set(userID, req.Role); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to update role",
+		})
+	}
+
+	log.Printf("[ADMIN] %s set role of %s to %s", middleware.GetUsername(c), target.Username, req.Role)
+	target.Role = req.Role
+	return c.JSON(target)
+}
+
+// entries returns every user with their effective role
+func (h *UserHandler) entries() ([]UserEntry, error) {
+	users, err := h.users.List()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]UserEntry, len(users))
+	for i, u := range users {
+		role, err := h.roles.Get(u.ID, u.Username)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = UserEntry{UserID: u.ID, Username: u.Username, AvatarURL: u.AvatarURL, Role: role}
+	}
+	return entries, nil
+}
@@ -1,20 +1,81 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"log"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 
 	"github.com/sainaif/council/internal/services/auth"
+	ws "github.com/sainaif/council/internal/websocket"
 )
 
 type AuthMiddleware struct {
-	secretKey string
+	secretKey   string
+	vault       *auth.Vault
+	tokens      *auth.APITokens
+	roles       *auth.RoleService
+	access      *auth.AccessPolicy
+	tokenRoutes []tokenRoute
 }
 
-func NewAuthMiddleware(secretKey string) *AuthMiddleware {
-	return &AuthMiddleware{secretKey: secretKey}
+// tokenRoute is a route personal API tokens may call, and the scope needed
+type tokenRoute struct {
+	method   string
+	segments []string
+	scope    string
+}
+
+func NewAuthMiddleware(secretKey string, vault *auth.Vault, tokens *auth.APITokens, roles *auth.RoleService, access *auth.AccessPolicy) *AuthMiddleware {
+	return &AuthMiddleware{secretKey: secretKey, vault: vault, tokens: tokens, roles: roles, access: access}
+}
+
+// AllowToken lets personal API tokens with the given scope call a route.
+// Paths use ":param" for one segment and a trailing "*" for the rest.
+// Routes not allowed here reject API tokens.
+func (m *AuthMiddleware) AllowToken(method, path, scope string) {
+	m.tokenRoutes = append(m.tokenRoutes, tokenRoute{
+		method:   method,
+		segments: strings.Split(strings.Trim(path, "/"), "/"),
+		scope:    scope,
+	})
+}
+
+// tokenAllowed reports whether the request may proceed with these claims.
+// Browser sessions may call every route; API tokens need the route's scope.
+func (m *AuthMiddleware) tokenAllowed(c *fiber.Ctx, claims *auth.Claims) bool {
+	if claims.Scopes == nil {
+		return true
+	}
+	scope := m.tokenScope(c)
+	return scope != "" && hasScope(claims.Scopes, scope)
+}
+
+// tokenScope returns the scope an API token needs for the request, or ""
+// when API tokens may not call the route at all
+func (m *AuthMiddleware) tokenScope(c *fiber.Ctx) string {
+	segments := strings.Split(strings.Trim(c.Path(), "/"), "/")
+	for _, r := range m.tokenRoutes {
+		if r.method == c.Method() && matchSegments(r.segments, segments) {
+			return r.scope
+		}
+	}
+	return ""
+}
+
+func matchSegments(pattern, path []string) bool {
+	for i, p := range pattern {
+		if p == "*" {
+			return true
+		}
+		if i >= len(path) || (path[i] != p && !strings.HasPrefix(p, ":")) {
+			return false
+		}
+	}
+	return len(pattern) == len(path)
 }
 
 func (m *AuthMiddleware) Required() fiber.Handler {
@@ -27,6 +88,27 @@ func (m *AuthMiddleware) Required() fiber.Handler {
 			})
 		}
 
+		// Personal API tokens only reach the routes their scopes allow
+		if !m.tokenAllowed(c, claims) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   true,
+				"message": "This API token is not allowed to call this endpoint",
+			})
+		}
+
+		// Users removed from the allowed orgs/teams lose access
+		if err := m.access.Recheck(c.Context(), claims); errors.Is(err, auth.ErrAccessDenied) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   true,
+				"message": "Access revoked: your GitHub account is no longer allowed on this instance",
+			})
+		} else if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Failed to check access",
+			})
+		}
+
 		// Store claims in context
 		c.Locals("user", claims)
 		c.Locals("userID", claims.UserID)
@@ -36,10 +118,28 @@ func (m *AuthMiddleware) Required() fiber.Handler {
 	}
 }
 
-func (m *AuthMiddleware) Optional() fiber.Handler {
+// RequiredWS authenticates a WebSocket upgrade like Required. Browsers cannot
+// read the HTTP status of a failed upgrade, so refusals are recorded as the
+// close code the connection is closed with once upgraded ("wsCloseCode" and
+// "wsCloseReason") and later handlers see no user.
+func (m *AuthMiddleware) RequiredWS() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		claims, err := m.extractClaims(c)
-		if err == nil {
+		if err != nil {
+			rejectWS(c, ws.CloseUnauthorized, "Unauthorized")
+			return c.Next()
+		}
+		if !m.tokenAllowed(c, claims) {
+			rejectWS(c, ws.CloseForbidden, "This API token is not allowed to call this endpoint")
+			return c.Next()
+		}
+
+		switch err := m.access.Recheck(c.Context(), claims); {
+		case errors.Is(err, auth.ErrAccessDenied):
+			rejectWS(c, ws.CloseForbidden, "Access revoked: your GitHub account is no longer allowed on this instance")
+		case err != nil:
+			rejectWS(c, ws.CloseInternalError, "Failed to check access")
+		default:
 			c.Locals("user", claims)
 			c.Locals("userID", claims.UserID)
 			c.Locals("username", claims.Username)
@@ -48,14 +148,137 @@ func (m *AuthMiddleware) Optional() fiber.Handler {
 	}
 }
 
+func rejectWS(c *fiber.Ctx, code int, reason string) {
+	c.Locals("wsCloseCode", code)
+	c.Locals("wsCloseReason", reason)
+}
+
+// WSRejected reports whether RequiredWS refused the upgrade
+func WSRejected(c *fiber.Ctx) bool {
+	code, _ := c.Locals("wsCloseCode").(int)
+	return code != 0
+}
+
+// RequireRole rejects users whose role is below the given one. It must run
+// after Required so the user is known.
+func (m *AuthMiddleware) RequireRole(role auth.Role) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		current, err := m.roles.Get(GetUserID(c), GetUsername(c))
+		if err != nil {
+			log.Printf("[AUTH] Failed to get role of user %s: %v", GetUsername(c), err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Failed to check permissions",
+			})
+		}
+		if !current.AtLeast(role) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   true,
+				"message": "This action requires the " + string(role) + " role",
+			})
+		}
+
+		c.Locals("role", current)
+		return c.Next()
+	}
+}
+
+// RequireCredentials rejects requests without GitHub credentials for
+// Copilot. Browser sessions always have them; personal API tokens borrow
+// them from the owner's browser sign-in, which may have ended.
+func (m *AuthMiddleware) RequireCredentials() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if claims := GetClaims(c); claims == nil || claims.AccessToken == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":   true,
+				"message": "No active credentials: sign in to Council in a browser so this API token can use your GitHub Copilot access",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// Revalidate re-resolves the claims of a long-lived connection such as a
+// WebSocket: the vault session must still be active and the access policy
+// must still allow the user. It returns the refreshed claims and the user's
+// current role, or auth.ErrSessionInvalid or auth.ErrAccessDenied.
+func (m *AuthMiddleware) Revalidate(ctx context.Context, claims *auth.Claims) (*auth.Claims, auth.Role, error) {
+	// Sockets only accept browser sessions; API tokens cannot be re-read
+	// without the token itself
+	if claims.Scopes != nil {
+		return nil, "", auth.ErrSessionInvalid
+	}
+
+	accessToken, err := m.vault.AccessToken(ctx, claims.SessionID, claims.UserID)
+	if err != nil {
+		return nil, "", err
+	}
+	current := *claims
+	current.AccessToken = accessToken
+
+	if err := m.access.Recheck(ctx, &current); err != nil {
+		return nil, "", err
+	}
+	role, err := m.roles.Get(current.UserID, current.Username)
+	if err != nil {
+		return nil, "", err
+	}
+	return &current, role, nil
+}
+
+// extractClaims validates the JWT and loads the GitHub token of its
+// session from the vault. Revoked or expired sessions are rejected.
 func (m *AuthMiddleware) extractClaims(c *fiber.Ctx) (*auth.Claims, error) {
-	// Try Authorization header first
-	authHeader := c.Get("Authorization")
-	if authHeader != "" {
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
-			return m.parseToken(parts[1])
+	if bearer := bearerToken(c); strings.HasPrefix(bearer, auth.APITokenPrefix) {
+		return m.apiTokenClaims(c, bearer)
+	}
+
+	claims, err := m.readToken(c)
+	if err != nil {
+		return nil, err
+	}
+
+	claims.AccessToken, err = m.vault.AccessToken(c.Context(), claims.SessionID, claims.UserID)
+	if err != nil {
+		if !errors.Is(err, auth.ErrSessionInvalid) {
+			log.Printf("[AUTH] Failed to load session for user %s: %v", claims.Username, err)
 		}
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "Session expired")
+	}
+	return claims, nil
+}
+
+// apiTokenClaims authenticates a personal API token. Its Copilot access
+// comes from the owner's most recently used sign-in. Signing out revokes
+// that GitHub token, so without an active sign-in the token can still read
+// but RequireCredentials refuses the routes that call Copilot.
+func (m *AuthMiddleware) apiTokenClaims(c *fiber.Ctx, plaintext string) (*auth.Claims, error) {
+	token, err := m.tokens.Authenticate(plaintext)
+	if err != nil {
+		if !errors.Is(err, auth.ErrTokenInvalid) {
+			log.Printf("[AUTH] Failed to check API token: %v", err)
+		}
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid API token")
+	}
+
+	accessToken, err := m.vault.UserAccessToken(c.Context(), token.UserID)
+	if err != nil && !errors.Is(err, auth.ErrSessionInvalid) {
+		log.Printf("[AUTH] Failed to load Copilot credentials for API token of user %s: %v", token.Username, err)
+	}
+
+	return &auth.Claims{
+		UserID:      token.UserID,
+		Username:    token.Username,
+		AvatarURL:   token.AvatarURL,
+		AccessToken: accessToken,
+		Scopes:      token.Scopes,
+	}, nil
+}
+
+func (m *AuthMiddleware) readToken(c *fiber.Ctx) (*auth.Claims, error) {
+	// Try Authorization header first
+	if bearer := bearerToken(c); bearer != "" {
+		return m.parseToken(bearer)
 	}
 
 	// Try cookie
@@ -86,6 +309,23 @@ func (m *AuthMiddleware) parseToken(tokenString string) (*auth.Claims, error) {
 	return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid token")
 }
 
+func bearerToken(c *fiber.Ctx) string {
+	parts := strings.SplitN(c.Get("Authorization"), " ", 2)
+	if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+		return parts[1]
+	}
+	return ""
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 // GetUserID returns the user ID from context
 func GetUserID(c *fiber.Ctx) string {
 	if userID, ok := c.Locals("userID").(string); ok {
@@ -102,6 +342,14 @@ func GetUsername(c *fiber.Ctx) string {
 	return ""
 }
 
+// GetRole returns the role recorded by RequireRole or LoadRole
+func GetRole(c *fiber.Ctx) auth.Role {
+	if role, ok := c.Locals("role").(auth.Role); ok {
+		return role
+	}
+	return ""
+}
+
 // GetClaims returns the full claims from context
 func GetClaims(c *fiber.Ctx) *auth.Claims {
 	if claims, ok := c.Locals("user").(*auth.Claims); ok {
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/oauth2"
+
+	"github.com/sainaif/council/internal/config"
+	"github.com/sainaif/council/internal/database"
+	"github.com/sainaif/council/internal/services/auth"
+)
+
+type testEnv struct {
+	db     *database.DB
+	vault  *auth.Vault
+	tokens *auth.APITokens
+	app    *fiber.App
+}
+
+// newTestEnv serves a few routes behind Required, with API tokens allowed on
+// some of them like in the route setup
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO user_preferences (user_id, github_username) VALUES ('42', 'bob')`); err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+
+	cfg := &config.Config{SessionSecret: "test-secret", DefaultRole: "member"}
+	gh := auth.NewGitHubAuth(cfg)
+	vault, err := auth.NewVault(db, gh, cfg)
+	if err != nil {
+		t.Fatalf("NewVault: %v", err)
+	}
+	tokens := auth.NewAPITokens(db)
+	roles := auth.NewRoleService(db, gh, cfg)
+	mw := NewAuthMiddleware(cfg.SessionSecret, vault, tokens, roles, auth.NewAccessPolicy(db, gh, roles, cfg))
+
+	mw.AllowToken(fiber.MethodPost, "/api/council/start", auth.ScopeCouncilStart)
+	mw.AllowToken(fiber.MethodGet, "/api/council/:id", auth.ScopeCouncilRead)
+	mw.AllowToken(fiber.MethodGet, "/api/rankings/*", auth.ScopeAnalyticsRead)
+
+	ok := func(c *fiber.Ctx) error { return c.JSON(fiber.Map{"user": GetUserID(c)}) }
+	app := fiber.New()
+	api := app.Group("/api", mw.Required())
+	api.Post("/council/start", mw.RequireCredentials(), ok)
+	api.Get("/council/:id", ok)
+	api.Post("/council/:id/vote", ok)
+	api.Get("/rankings/*", ok)
+	api.Get("/settings", ok)
+
+	return &testEnv{db: db, vault: vault, tokens: tokens, app: app}
+}
+
+func (e *testEnv) token(t *testing.T, ttl time.Duration, scopes ...string) string {
+	t.Helper()
+	plaintext, _, err := e.tokens.Create("42", "test", scopes, ttl)
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	return plaintext
+}
+
+// do sends a request with a bearer token and returns the status and error
+// message
+func (e *testEnv) do(t *testing.T, method, path, bearer string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := e.app.Test(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var body struct {
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body.Message
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	env := newTestEnv(t)
+	if _, err := env.vault.Create("42", &oauth2.Token{AccessToken: "gho_test"}, auth.SessionMeta{}); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	all := env.token(t, time.Hour, auth.Scopes...)
+	read := env.token(t, time.Hour, auth.ScopeCouncilRead)
+	expired := env.token(t, -time.Minute, auth.Scopes...)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"start with council:start", fiber.MethodPost, "/api/council/start", all, fiber.StatusOK},
+		{"start without council:start", fiber.MethodPost, "/api/council/start", read, fiber.StatusForbidden},
+		{"read a council", fiber.MethodGet, "/api/council/abc", read, fiber.StatusOK},
+		{"param matches one segment", fiber.MethodGet, "/api/council/abc/extra", all, fiber.StatusForbidden},
+		{"wrong method", fiber.MethodPost, "/api/council/abc/vote", all, fiber.StatusForbidden},
+		{"wildcard", fiber.MethodGet, "/api/rankings/coding/history", all, fiber.StatusOK},
+		{"wildcard without its scope", fiber.MethodGet, "/api/rankings/coding", read, fiber.StatusForbidden},
+		{"route not in the table", fiber.MethodGet, "/api/settings", all, fiber.StatusForbidden},
+		{"expired token", fiber.MethodGet, "/api/council/abc", expired, fiber.StatusUnauthorized},
+		{"unknown token", fiber.MethodGet, "/api/council/abc", auth.APITokenPrefix + "nope", fiber.StatusUnauthorized},
+		{"no token", fiber.MethodGet, "/api/council/abc", "", fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, msg := env.do(t, tt.method, tt.path, tt.token); got != tt.want {
+				t.Errorf("%s %s = %d (%s), want %d", tt.method, tt.path, got, msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPITokenCredentials(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.token(t, time.Hour, auth.Scopes...)
+
+	assertNoCredentials := func(when string) {
+		t.Helper()
+		status, msg := env.do(t, fiber.MethodPost, "/api/council/start", token)
+		if status != fiber.StatusUnauthorized || !strings.HasPrefix(msg, "No active credentials") {
+			t.Errorf("start %s = %d %q, want 401 No active credentials", when, status, msg)
+		}
+		// Reading does not need Copilot
+		if status, _ := env.do(t, fiber.MethodGet, "/api/council/abc", token); status != fiber.StatusOK {
+			t.Errorf("read %s = %d, want 200", when, status)
+		}
+	}
+
+	assertNoCredentials("without a browser session")
+
+	sessionID, err := env.vault.Create("42", &oauth2.Token{AccessToken: "gho_test"}, auth.SessionMeta{})
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if status, msg := env.do(t, fiber.MethodPost, "/api/council/start", token); status != fiber.StatusOK {
+		t.Errorf("start with a browser session = %d (%s), want 200", status, msg)
+	}
+
+	// Signing out wipes the session's GitHub token
+	if _, err := env.db.Exec(`UPDATE auth_sessions SET access_token_enc = NULL, revoked_at = CURRENT_TIMESTAMP WHERE id = ?`, sessionID); err != nil {
+		t.Fatalf("revoke session: %v", err)
+	}
+	assertNoCredentials("after signing out")
+}
@@ -6,7 +6,7 @@ import (
 
 	"github.com/sainaif/council/internal/handlers"
 	"github.com/sainaif/council/internal/middleware"
-	ws "github.com/sainaif/council/internal/websocket"
+	authsvc "github.com/sainaif/council/internal/services/auth"
 )
 
 type Handlers struct {
@@ -16,9 +16,13 @@ type Handlers struct {
 	Ranking   *handlers.RankingHandler
 	Analytics *handlers.AnalyticsHandler
 	Settings  *handlers.SettingsHandler
+	Category  *handlers.CategoryHandler
+	User      *handlers.UserHandler
+	Token     *handlers.TokenHandler
+	Hub       *handlers.HubHandler
 }
 
-func Setup(app *fiber.App, h Handlers, authMw *middleware.AuthMiddleware, wsHub *ws.Hub) {
+func Setup(app *fiber.App, h Handlers, authMw *middleware.AuthMiddleware) {
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{"status": "ok"})
@@ -30,6 +34,25 @@ func Setup(app *fiber.App, h Handlers, authMw *middleware.AuthMiddleware, wsHub
 	auth.Get("/callback", h.Auth.Callback)
 	auth.Get("/logout", h.Auth.Logout)
 	auth.Get("/me", authMw.Required(), h.Auth.Me)
+	auth.Get("/sessions", authMw.Required(), h.Auth.Sessions)
+	auth.Delete("/sessions", authMw.Required(), h.Auth.RevokeOtherSessions)
+	auth.Delete("/sessions/:id", authMw.Required(), h.Auth.RevokeSession)
+
+	// Public category list (registered before the authenticated /api group)
+	app.Get("/api/categories", h.Category.List)
+
+	// Routes personal API tokens may call, and the scope each needs
+	authMw.AllowToken(fiber.MethodPost, "/api/council/start", authsvc.ScopeCouncilStart)
+	authMw.AllowToken(fiber.MethodPost, "/api/council/:id/cancel", authsvc.ScopeCouncilStart)
+	authMw.AllowToken(fiber.MethodGet, "/api/models", authsvc.ScopeCouncilStart)
+	authMw.AllowToken(fiber.MethodGet, "/api/models/:id", authsvc.ScopeCouncilStart)
+	authMw.AllowToken(fiber.MethodGet, "/api/council/history", authsvc.ScopeCouncilRead)
+	authMw.AllowToken(fiber.MethodGet, "/api/council/search", authsvc.ScopeCouncilRead)
+	authMw.AllowToken(fiber.MethodGet, "/api/council/:id", authsvc.ScopeCouncilRead)
+	authMw.AllowToken(fiber.MethodGet, "/api/council/:id/events", authsvc.ScopeCouncilRead)
+	authMw.AllowToken(fiber.MethodGet, "/api/rankings/*", authsvc.ScopeAnalyticsRead)
+	authMw.AllowToken(fiber.MethodGet, "/api/matchups/:modelA/:modelB", authsvc.ScopeAnalyticsRead)
+	authMw.AllowToken(fiber.MethodGet, "/api/analytics/*", authsvc.ScopeAnalyticsRead)
 
 	// API routes
 	api := app.Group("/api", authMw.Required())
@@ -37,21 +60,36 @@ func Setup(app *fiber.App, h Handlers, authMw *middleware.AuthMiddleware, wsHub
 	// Council routes
 	council := api.Group("/council")
 	council.Get("/history", h.Council.History) // Must be before /:id to avoid conflict
-	council.Post("/start", h.Council.Start)
+	council.Get("/search", h.Council.Search)
 	council.Get("/:id", h.Council.Get)
-	council.Post("/:id/vote", h.Council.Vote)
-	council.Post("/:id/appeal", h.Council.Appeal)
-	council.Post("/:id/cancel", h.Council.Cancel)
+
+	// Running and voting on councils requires at least a member
+	member := authMw.RequireRole(authsvc.RoleMember)
+	copilot := authMw.RequireCredentials()
+	council.Post("/start", member, copilot, h.Council.Start)
+	council.Post("/:id/vote", member, h.Council.Vote)
+	council.Post("/:id/appeal", member, h.Council.Appeal)
+	council.Post("/:id/cancel", member, h.Council.Cancel)
+	council.Put("/:id/category", member, h.Council.SetCategory)
+	council.Get("/:id/shares", h.Council.Shares)
+
+	// Server-Sent Events alternative to /ws/council/:id
+	council.Get("/:id/events", h.Council.AuthorizeEvents, h.Hub.Events)
+	council.Post("/:id/shares", member, h.Council.Share)
+	council.Delete("/:id/shares/:userId", member, h.Council.Unshare)
 
 	// Model routes
 	models := api.Group("/models")
-	models.Get("/", h.Model.List)
-	models.Get("/:id", h.Model.Get)
+	models.Get("/", copilot, h.Model.List)
+	models.Get("/:id", copilot, h.Model.Get)
 	models.Get("/:id/history", h.Model.History)
 
 	// Ranking routes
 	rankings := api.Group("/rankings")
 	rankings.Get("/", h.Ranking.Global)
+	rankings.Get("/judges", h.Ranking.Judges) // Must be before /:category to avoid conflict
+	rankings.Get("/history", h.Ranking.History)
+	rankings.Get("/as-of", h.Ranking.AsOf)
 	rankings.Get("/:category", h.Ranking.ByCategory)
 
 	// Matchup routes
@@ -62,12 +100,31 @@ func Setup(app *fiber.App, h Handlers, authMw *middleware.AuthMiddleware, wsHub
 	analytics.Get("/overview", h.Analytics.Overview)
 	analytics.Get("/user-bias", h.Analytics.UserBias)
 	analytics.Get("/costs", h.Analytics.Costs)
+	analytics.Get("/agreement", h.Analytics.Agreement)
 
 	// Settings routes
 	settings := api.Group("/settings")
 	settings.Get("/", h.Settings.Get)
 	settings.Put("/", h.Settings.Update)
 
+	// Personal API token routes (browser sessions only)
+	tokens := api.Group("/tokens")
+	tokens.Get("/", h.Token.List)
+	tokens.Post("/", h.Token.Create)
+	tokens.Delete("/:id", h.Token.Revoke)
+
+	// Admin routes
+	admin := api.Group("/admin", authMw.RequireRole(authsvc.RoleAdmin))
+	adminCategories := admin.Group("/categories")
+	adminCategories.Post("/", h.Category.Create)
+	adminCategories.Put("/:id", h.Category.Update)
+	adminCategories.Delete("/:id", h.Category.Delete)
+	adminCategories.Post("/:id/merge", h.Category.Merge)
+	admin.Get("/users", h.User.List)
+	admin.Put("/users/:id/role", h.User.SetRole)
+	admin.Get("/hub/metrics", h.Hub.Metrics)
+	admin.Get("/hub/stats", h.Hub.Stats)
+
 	// WebSocket route for real-time updates
 	app.Use("/ws", func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) {
@@ -77,8 +134,9 @@ func Setup(app *fiber.App, h Handlers, authMw *middleware.AuthMiddleware, wsHub
 		return fiber.ErrUpgradeRequired
 	})
 
-	app.Get("/ws/council/:id", authMw.Optional(), websocket.New(func(c *websocket.Conn) {
-		sessionID := c.Params("id")
-		wsHub.HandleConnection(c, sessionID)
-	}))
+	// Only the owner and users it was shared with may watch a session
+	app.Get("/ws/council/:id", authMw.RequiredWS(), h.Council.AuthorizeStream, websocket.New(h.Hub.SessionSocket))
+
+	// All of the user's sessions on one socket
+	app.Get("/ws/user", authMw.RequiredWS(), h.Council.AuthorizeUserStream, websocket.New(h.Hub.UserSocket))
 }
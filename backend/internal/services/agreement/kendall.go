@@ -0,0 +1,113 @@
+package agreement
+
+import "sort"
+
+// Pair holds the rank correlation between two voters
+type Pair struct {
+	VoterA string  `json:"voter_a"`
+	VoterB string  `json:"voter_b"`
+	Tau    float64 `json:"tau"`
+}
+
+// KendallTau returns the rank correlation of two rankings over their common items.
+// The second return value is false when fewer than two items are shared.
+func KendallTau(a, b []string) (float64, bool) {
+	posB := make(map[string]int, len(b))
+	for i, item := range b {
+		posB[item] = i
+	}
+
+	var common []int // positions in b, in the order of a
+	for _, item := range a {
+		if pos, ok := posB[item]; ok {
+			common = append(common, pos)
+		}
+	}
+	if len(common) < 2 {
+		return 0, false
+	}
+
+	concordant, discordant := 0, 0
+	for i := 0; i < len(common); i++ {
+		for j := i + 1; j < len(common); j++ {
+			if common[i] < common[j] {
+				concordant++
+			} else {
+				discordant++
+			}
+		}
+	}
+
+	return float64(concordant-discordant) / float64(concordant+discordant), true
+}
+
+// KendallW returns Kendall's coefficient of concordance for a set of rankings.
+// Items missing from a ranking share the average of the positions left over.
+// The second return value is false with fewer than two rankings or items.
+func KendallW(rankings [][]string) (float64, bool) {
+	items := make(map[string]bool)
+	for _, r := range rankings {
+		for _, item := range r {
+			items[item] = true
+		}
+	}
+
+	m := float64(len(rankings))
+	n := float64(len(items))
+	if m < 2 || n < 2 {
+		return 0, false
+	}
+
+	rankSums := make(map[string]float64, len(items))
+	for _, r := range rankings {
+		seen := make(map[string]bool, len(r))
+		for i, item := range r {
+			if seen[item] {
+				continue
+			}
+			seen[item] = true
+			rankSums[item] += float64(i + 1)
+		}
+
+		// Unranked items tie for the remaining positions
+		missing := len(items) - len(seen)
+		if missing > 0 {
+			tied := float64(len(seen)+1+len(items)) / 2
+			for item := range items {
+				if !seen[item] {
+					rankSums[item] += tied
+				}
+			}
+		}
+	}
+
+	mean := m * (n + 1) / 2
+	var s float64
+	for _, sum := range rankSums {
+		s += (sum - mean) * (sum - mean)
+	}
+
+	return 12 * s / (m * m * (n*n*n - n)), true
+}
+
+// Pairwise returns Kendall tau for every pair of voters, ordered by voter IDs
+func Pairwise(ballots map[string][]string) []Pair {
+	voters := make([]string, 0, len(ballots))
+	for voter := range ballots {
+		voters = append(voters, voter)
+	}
+	sort.Strings(voters)
+
+	pairs := make([]Pair, 0)
+	for i := 0; i < len(voters); i++ {
+		for j := i + 1; j < len(voters); j++ {
+			tau, ok := KendallTau(ballots[voters[i]], ballots[voters[j]])
+			if !ok {
+				continue
+			}
+			pairs = append(pairs, Pair{VoterA: voters[i], VoterB: voters[j], Tau: tau})
+		}
+	}
+
+	return pairs
+}
@@ -0,0 +1,70 @@
+package agreement
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestKendallTau(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   []string
+		want   float64
+		wantOK bool
+	}{
+		{"identical", []string{"A", "B", "C"}, []string{"A", "B", "C"}, 1, true},
+		{"reversed", []string{"A", "B", "C"}, []string{"C", "B", "A"}, -1, true},
+		{"one swap", []string{"A", "B", "C"}, []string{"B", "A", "C"}, 1.0 / 3, true},
+		{"common items only", []string{"A", "X", "B"}, []string{"B", "Y", "A"}, -1, true},
+		{"one common item", []string{"A", "B"}, []string{"A", "C"}, 0, false},
+		{"empty", nil, []string{"A", "B"}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := KendallTau(tt.a, tt.b)
+		if ok != tt.wantOK || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: KendallTau(%v, %v) = %v, %v, want %v, %v", tt.name, tt.a, tt.b, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestKendallW(t *testing.T) {
+	tests := []struct {
+		name     string
+		rankings [][]string
+		want     float64
+		wantOK   bool
+	}{
+		{"unanimous", [][]string{{"A", "B", "C"}, {"A", "B", "C"}, {"A", "B", "C"}}, 1, true},
+		{"opposed", [][]string{{"A", "B"}, {"B", "A"}}, 0, true},
+		// Ranks sum to A=2, B=5, C=5 around a mean of 4, so S = 6 and W = 12*6/(4*24)
+		{"partial agreement", [][]string{{"A", "B", "C"}, {"A", "C", "B"}}, 0.75, true},
+		// B and C tie at 2.5 in the second ranking: A=2, B=4.5, C=5.5, so S = 6.5
+		{"missing items tie", [][]string{{"A", "B", "C"}, {"A"}}, 12 * 6.5 / (4 * 24), true},
+		{"single ranking", [][]string{{"A", "B"}}, 0, false},
+		{"single item", [][]string{{"A"}, {"A"}}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := KendallW(tt.rankings)
+		if ok != tt.wantOK || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: KendallW(%v) = %v, %v, want %v, %v", tt.name, tt.rankings, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestPairwise(t *testing.T) {
+	ballots := map[string][]string{
+		"judge-c": {"A", "B", "C"},
+		"judge-a": {"A", "B", "C"},
+		"judge-b": {"C", "B", "A"},
+		"judge-d": {"A"},
+	}
+	want := []Pair{
+		{VoterA: "judge-a", VoterB: "judge-b", Tau: -1},
+		{VoterA: "judge-a", VoterB: "judge-c", Tau: 1},
+		{VoterA: "judge-b", VoterB: "judge-c", Tau: -1},
+	}
+	if got := Pairwise(ballots); !reflect.DeepEqual(got, want) {
+		t.Errorf("Pairwise = %+v, want %+v", got, want)
+	}
+}
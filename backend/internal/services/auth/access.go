@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/sainaif/council/internal/config"
+	"github.com/sainaif/council/internal/database"
+)
+
+// ErrAccessDenied is returned for users outside the sign-in allowlist
+var ErrAccessDenied = errors.New("access denied: not a member of an allowed organization or team")
+
+// AccessPolicy restricts sign-in to allowed usernames and org/team members
+type AccessPolicy struct {
+	db           *database.DB
+	auth         *GitHubAuth
+	roles        *RoleService
+	allowedUsers map[string]bool
+	allowedOrgs  []string
+	recheck      time.Duration
+}
+
+// NewAccessPolicy creates the sign-in policy from config. Configured admins
+// are always allowed. Roles granted through ADMIN_TEAMS are re-synced on
+// each recheck.
+func NewAccessPolicy(db *database.DB, auth *GitHubAuth, roles *RoleService, cfg *config.Config) *AccessPolicy {
+	allowedUsers := make(map[string]bool)
+	for _, name := range cfg.AllowedUsers {
+		allowedUsers[strings.ToLower(name)] = true
+	}
+	for _, name := range cfg.AdminUsers {
+		allowedUsers[strings.ToLower(name)] = true
+	}
+
+	var allowedOrgs []string
+	for _, org := range append(append([]string{}, cfg.AllowedOrgs...), cfg.AdminTeams...) {
+		allowedOrgs = append(allowedOrgs, strings.ToLower(org))
+	}
+
+	return &AccessPolicy{
+		db:           db,
+		auth:         auth,
+		roles:        roles,
+		allowedUsers: allowedUsers,
+		allowedOrgs:  allowedOrgs,
+		recheck:      time.Duration(cfg.AccessRecheckHours) * time.Hour,
+	}
+}
+
+// Restricted reports whether sign-in is limited at all
+func (p *AccessPolicy) Restricted() bool {
+	return len(p.allowedUsers) > 0 || len(p.allowedOrgs) > 0
+}
+
+// Check decides whether a user may sign in. It returns ErrAccessDenied for
+// users outside the allowlist, or the GitHub error if membership could not
+// be verified.
+func (p *AccessPolicy) Check(ctx context.Context, username string, token *oauth2.Token) error {
+	if !p.Restricted() {
+		return nil
+	}
+
+	allowed, err := p.allowed(ctx, username, token)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// Recheck re-verifies an existing session once the last check is older than
+// the recheck interval. GitHub errors keep the previous decision; when the
+// previous decision cannot be read the error is returned and the session
+// must be refused.
+func (p *AccessPolicy) Recheck(ctx context.Context, claims *Claims) error {
+	if !p.Restricted() {
+		return nil
+	}
+
+	var checkedAt, deniedAt sql.NullTime
+	err := p.db.QueryRow(`
+		SELECT access_checked_at, access_denied_at FROM user_preferences WHERE user_id = ?
+	`, claims.UserID).Scan(&checkedAt, &deniedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("[AUTH] Failed to read access check of user %s, refusing the session: %v", claims.Username, err)
+		return err
+	}
+
+	if checkedAt.Valid && (p.recheck <= 0 || time.Since(checkedAt.Time) < p.recheck) {
+		if deniedAt.Valid {
+			return ErrAccessDenied
+		}
+		return nil
+	}
+
+	allowed := p.allowedUsers[strings.ToLower(claims.Username)]
+	memberships, err := p.memberships(ctx, &oauth2.Token{AccessToken: claims.AccessToken})
+	if err != nil {
+		log.Printf("[AUTH] Access recheck failed for user %s, keeping previous decision: %v", claims.Username, err)
+		allowed = allowed || !deniedAt.Valid
+	} else {
+		allowed = allowed || p.member(memberships)
+		if !allowed {
+			log.Printf("[AUTH] User %s is no longer in an allowed organization or team", claims.Username)
+		}
+		if err := p.roles.SyncTeams(claims.UserID, memberships); err != nil {
+			log.Printf("[AUTH] Failed to update admin role of user %s: %v", claims.Username, err)
+		}
+	}
+	p.Record(claims.UserID, allowed)
+
+	if !allowed {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+func (p *AccessPolicy) allowed(ctx context.Context, username string, token *oauth2.Token) (bool, error) {
+	if p.allowedUsers[strings.ToLower(username)] {
+		return true, nil
+	}
+
+	memberships, err := p.memberships(ctx, token)
+	if err != nil {
+		return false, err
+	}
+	return p.member(memberships), nil
+}
+
+// memberships asks GitHub for the user's orgs and teams, unless no org or
+// team is configured
+func (p *AccessPolicy) memberships(ctx context.Context, token *oauth2.Token) (map[string]bool, error) {
+	if len(p.allowedOrgs) == 0 {
+		return nil, nil
+	}
+	return p.auth.GetMemberships(ctx, token)
+}
+
+// member reports whether the memberships include an allowed org or team
+func (p *AccessPolicy) member(memberships map[string]bool) bool {
+	for _, org := range p.allowedOrgs {
+		if memberships[org] {
+			return true
+		}
+	}
+	return false
+}
+
+// Record stores the outcome of a check for an existing user, so Recheck
+// only asks GitHub again after the interval
+func (p *AccessPolicy) Record(userID string, allowed bool) {
+	if !p.Restricted() {
+		return
+	}
+
+	var err error
+	if allowed {
+		_, err = p.db.Exec(`
+			UPDATE user_preferences SET access_checked_at = CURRENT_TIMESTAMP, access_denied_at = NULL WHERE user_id = ?
+		`, userID)
+	} else {
+		_, err = p.db.Exec(`
+			UPDATE user_preferences SET access_checked_at = CURRENT_TIMESTAMP,
+				access_denied_at = COALESCE(access_denied_at, CURRENT_TIMESTAMP)
+			WHERE user_id = ?
+		`, userID)
+	}
+	if err != nil {
+		log.Printf("[AUTH] Failed to record access check for user %s: %v", userID, err)
+	}
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/sainaif/council/internal/config"
+	"github.com/sainaif/council/internal/database"
+)
+
+func newAccessPolicy(db *database.DB, gh *GitHubAuth, cfg *config.Config) *AccessPolicy {
+	return NewAccessPolicy(db, gh, NewRoleService(db, gh, cfg), cfg)
+}
+
+func TestAccessPolicyCheck(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{
+		AllowedUsers: []string{"Alice"},
+		AllowedOrgs:  []string{"Acme"},
+		AdminUsers:   []string{"root"},
+		AdminTeams:   []string{"Globex/Admins"},
+	}
+
+	tests := []struct {
+		name        string
+		username    string
+		memberships []string
+		want        error
+	}{
+		{"username allowlist", "alice", nil, nil},
+		{"configured admin", "ROOT", nil, nil},
+		{"org member", "bob", []string{"acme"}, nil},
+		{"admin team member", "carol", []string{"globex/admins"}, nil},
+		{"other team of the admin org", "dave", []string{"globex/sales"}, ErrAccessDenied},
+		{"outsider", "eve", []string{"initech"}, ErrAccessDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, gh := newFakeGitHub(t, tt.memberships...)
+			policy := newAccessPolicy(openDB(t), gh, cfg)
+			if err := policy.Check(ctx, tt.username, &oauth2.Token{AccessToken: "gho_test"}); !errors.Is(err, tt.want) {
+				t.Errorf("Check(%s) = %v, want %v", tt.username, err, tt.want)
+			}
+		})
+	}
+
+	t.Run("unrestricted", func(t *testing.T) {
+		fake, gh := newFakeGitHub(t)
+		policy := newAccessPolicy(openDB(t), gh, &config.Config{})
+		if policy.Restricted() {
+			t.Errorf("Restricted() = true without an allowlist")
+		}
+		if err := policy.Check(ctx, "anyone", &oauth2.Token{AccessToken: "gho_test"}); err != nil {
+			t.Errorf("Check = %v, want nil", err)
+		}
+		if fake.callCount() != 0 {
+			t.Errorf("asked GitHub %d times, want 0", fake.callCount())
+		}
+	})
+
+	t.Run("GitHub failure", func(t *testing.T) {
+		fake, gh := newFakeGitHub(t, "acme")
+		fake.setFailing(true)
+		policy := newAccessPolicy(openDB(t), gh, cfg)
+		if err := policy.Check(ctx, "bob", &oauth2.Token{AccessToken: "gho_test"}); err == nil || errors.Is(err, ErrAccessDenied) {
+			t.Errorf("Check = %v, want the GitHub error", err)
+		}
+	})
+}
+
+func TestAccessPolicyRecheck(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{AllowedOrgs: []string{"acme"}, AccessRecheckHours: 24}
+	claims := &Claims{UserID: "42", Username: "bob", AccessToken: "gho_test"}
+
+	t.Run("revokes", func(t *testing.T) {
+		db := openDB(t)
+		seedUser(t, db, "42", "bob")
+		fake, gh := newFakeGitHub(t, "acme")
+		policy := newAccessPolicy(db, gh, cfg)
+
+		if err := policy.Recheck(ctx, claims); err != nil {
+			t.Fatalf("first Recheck = %v, want nil", err)
+		}
+		// Left the org since, but the last check is still fresh
+		fake.set()
+		if err := policy.Recheck(ctx, claims); err != nil {
+			t.Fatalf("Recheck within the interval = %v, want nil", err)
+		}
+		if fake.callCount() != 2 {
+			t.Fatalf("asked GitHub %d times, want 2 (orgs and teams once)", fake.callCount())
+		}
+
+		mustExec(t, db, `UPDATE user_preferences SET access_checked_at = '2000-01-01 00:00:00' WHERE user_id = '42'`)
+		if err := policy.Recheck(ctx, claims); !errors.Is(err, ErrAccessDenied) {
+			t.Fatalf("Recheck after the interval = %v, want ErrAccessDenied", err)
+		}
+
+		// The denial is stored and holds while GitHub is unreachable
+		fake.setFailing(true)
+		if err := policy.Recheck(ctx, claims); !errors.Is(err, ErrAccessDenied) {
+			t.Errorf("Recheck of a denied user = %v, want ErrAccessDenied", err)
+		}
+		mustExec(t, db, `UPDATE user_preferences SET access_checked_at = '2000-01-01 00:00:00' WHERE user_id = '42'`)
+		if err := policy.Recheck(ctx, claims); !errors.Is(err, ErrAccessDenied) {
+			t.Errorf("Recheck of a denied user while GitHub fails = %v, want ErrAccessDenied", err)
+		}
+	})
+
+	t.Run("database failure", func(t *testing.T) {
+		db := openDB(t)
+		seedUser(t, db, "42", "bob")
+		_, gh := newFakeGitHub(t, "acme")
+		policy := newAccessPolicy(db, gh, cfg)
+		policy.Record("42", true)
+
+		_ = db.Close()
+		if err := policy.Recheck(ctx, claims); err == nil {
+			t.Errorf("Recheck with a closed database = nil, want an error")
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+// openDB returns a migrated in-memory database
+func openDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func mustExec(t *testing.T, db *database.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func seedUser(t *testing.T, db *database.DB, userID, username string) {
+	t.Helper()
+	mustExec(t, db, `INSERT INTO user_preferences (user_id, github_username) VALUES (?, ?)`, userID, username)
+}
+
+// fakeGitHub serves the membership and token revocation endpoints of the
+// GitHub API. Entries are
+// orgs ("org") or teams ("org/team").
+type fakeGitHub struct {
+	mu          sync.Mutex
+	memberships []string
+	revoked     []string
+	calls       int
+	fail        bool
+}
+
+// newFakeGitHub starts a fake GitHub API and returns a GitHubAuth that talks
+// to it
+func newFakeGitHub(t *testing.T, memberships ...string) (*fakeGitHub, *GitHubAuth) {
+	t.Helper()
+	gh := &fakeGitHub{memberships: memberships}
+	srv := httptest.NewServer(gh)
+	t.Cleanup(srv.Close)
+	return gh, &GitHubAuth{config: &oauth2.Config{}, apiURL: srv.URL, sessionKey: "test-secret"}
+}
+
+func (gh *fakeGitHub) set(memberships ...string) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	gh.memberships = memberships
+}
+
+func (gh *fakeGitHub) setFailing(fail bool) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	gh.fail = fail
+}
+
+func (gh *fakeGitHub) revokedTokens() []string {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	return append([]string(nil), gh.revoked...)
+}
+
+func (gh *fakeGitHub) callCount() int {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	return gh.calls
+}
+
+func (gh *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	gh.calls++
+	if gh.fail {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	if r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/applications/") {
+		var body struct {
+			AccessToken string `json:"access_token"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		gh.revoked = append(gh.revoked, body.AccessToken)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	type org struct {
+		Login string `json:"login"`
+	}
+	orgs := []org{}
+	teams := []struct {
+		Slug         string `json:"slug"`
+		Organization org    `json:"organization"`
+	}{}
+	for _, m := range gh.memberships {
+		if o, team, ok := strings.Cut(m, "/"); ok {
+			teams = append(teams, struct {
+				Slug         string `json:"slug"`
+				Organization org    `json:"organization"`
+			}{team, org{o}})
+		} else {
+			orgs = append(orgs, org{m})
+		}
+	}
+
+	switch r.URL.Path {
+	case "/user/orgs":
+		_ = json.NewEncoder(w).Encode(orgs)
+	case "/user/teams":
+		_ = json.NewEncoder(w).Encode(teams)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -1,10 +1,12 @@
 package auth
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -22,29 +24,41 @@ type GitHubUser struct {
 	AvatarURL string `json:"avatar_url"`
 }
 
+// githubAPI is the base URL of the GitHub REST API
+const githubAPI = "https://api.github.com"
+
 type GitHubAuth struct {
 	config      *oauth2.Config
+	apiURL      string
 	sessionKey  string
 	tokenExpiry time.Duration
 }
 
 type Claims struct {
-	UserID      string `json:"user_id"`
-	Username    string `json:"username"`
-	AvatarURL   string `json:"avatar_url"`
-	AccessToken string `json:"access_token"` // GitHub OAuth token for Copilot SDK
+	UserID      string   `json:"user_id"`
+	Username    string   `json:"username"`
+	AvatarURL   string   `json:"avatar_url"`
+	SessionID   string   `json:"sid"` // Vault session holding the GitHub tokens
+	AccessToken string   `json:"-"`   // Resolved from the vault per request, never put in the JWT
+	Scopes      []string `json:"-"`   // Set for personal API tokens; nil for browser sessions
 	jwt.RegisteredClaims
 }
 
 func NewGitHubAuth(cfg *config.Config) *GitHubAuth {
+	scopes := []string{"read:user", "user:email", "copilot"} // copilot scope for Copilot SDK
+	if len(cfg.AdminTeams) > 0 || len(cfg.AllowedOrgs) > 0 {
+		scopes = append(scopes, "read:org") // Org/team membership checks
+	}
+
 	return &GitHubAuth{
 		config: &oauth2.Config{
 			ClientID:     cfg.GitHubClientID,
 			ClientSecret: cfg.GitHubClientSecret,
-			Scopes:       []string{"read:user", "user:email", "copilot"}, // copilot scope for Copilot SDK
+			Scopes:       scopes,
 			Endpoint:     github.Endpoint,
 			RedirectURL:  cfg.OAuthCallbackURL(),
 		},
+		apiURL:      githubAPI,
 		sessionKey:  cfg.SessionSecret,
 		tokenExpiry: 24 * time.Hour * 7, // 7 days
 	}
@@ -61,7 +75,7 @@ func (g *GitHubAuth) Exchange(ctx context.Context, code string) (*oauth2.Token,
 func (g *GitHubAuth) GetUser(ctx context.Context, token *oauth2.Token) (*GitHubUser, error) {
 	client := g.config.Client(ctx, token)
 
-	resp, err := client.Get("https://api.github.com/user")
+	resp, err := client.Get(g.apiURL + "/user")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user info: %w", err)
 	}
@@ -79,12 +93,95 @@ func (g *GitHubAuth) GetUser(ctx context.Context, token *oauth2.Token) (*GitHubU
 	return &user, nil
 }
 
-func (g *GitHubAuth) CreateToken(user *GitHubUser, accessToken string) (string, error) {
+// GetMemberships returns the user's orgs ("org") and teams ("org/team"),
+// lowercased. Requires the read:org scope.
+func (g *GitHubAuth) GetMemberships(ctx context.Context, token *oauth2.Token) (map[string]bool, error) {
+	client := g.config.Client(ctx, token)
+	memberships := make(map[string]bool)
+
+	var orgs []struct {
+		Login string `json:"login"`
+	}
+	if err := getJSON(client, g.apiURL+"/user/orgs?per_page=100", &orgs); err != nil {
+		return nil, err
+	}
+	for _, o := range orgs {
+		memberships[strings.ToLower(o.Login)] = true
+	}
+
+	var teams []struct {
+		Slug         string `json:"slug"`
+		Organization struct {
+			Login string `json:"login"`
+		} `json:"organization"`
+	}
+	if err := getJSON(client, g.apiURL+"/user/teams?per_page=100", &teams); err != nil {
+		return nil, err
+	}
+	for _, t := range teams {
+		memberships[strings.ToLower(t.Organization.Login+"/"+t.Slug)] = true
+		memberships[strings.ToLower(t.Organization.Login)] = true
+	}
+
+	return memberships, nil
+}
+
+func getJSON(client *http.Client, url string, v interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %w", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("github API returned status %d for %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Refresh exchanges a refresh token for a new token. Only tokens that
+// expire (GitHub App user tokens) come with a refresh token.
+func (g *GitHubAuth) Refresh(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	expired := &oauth2.Token{RefreshToken: refreshToken, Expiry: time.Unix(1, 0)}
+	return g.config.TokenSource(ctx, expired).Token()
+}
+
+// RevokeToken invalidates an access token at GitHub so it stops working
+// outside this app too
+func (g *GitHubAuth) RevokeToken(ctx context.Context, accessToken string) error {
+	body, err := json.Marshal(map[string]string{"access_token": accessToken})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/applications/%s/token", g.apiURL, g.config.ClientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(g.config.ClientID, g.config.ClientSecret)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	// 404 means the token was already revoked or expired
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("github API returned status %d revoking token", resp.StatusCode)
+	}
+	return nil
+}
+
+func (g *GitHubAuth) CreateToken(user *GitHubUser, sessionID string) (string, error) {
 	claims := &Claims{
-		UserID:      fmt.Sprintf("%d", user.ID),
-		Username:    user.Login,
-		AvatarURL:   user.AvatarURL,
-		AccessToken: accessToken, // Store OAuth token for Copilot SDK
+		UserID:    fmt.Sprintf("%d", user.ID),
+		Username:  user.Login,
+		AvatarURL: user.AvatarURL,
+		SessionID: sessionID, // GitHub tokens stay server-side in the vault
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(g.tokenExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -117,6 +214,22 @@ func (g *GitHubAuth) ValidateToken(tokenString string) (*Claims, error) {
 	return nil, fmt.Errorf("invalid token")
 }
 
+// ParseToken checks a token's signature but not its expiry, so sessions
+// behind expired tokens can still be revoked
+func (g *GitHubAuth) ParseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(g.sessionKey), nil
+	}, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
 func (g *GitHubAuth) GenerateState() string {
 	return fmt.Sprintf("%d", time.Now().UnixNano())
 }
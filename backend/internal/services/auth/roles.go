@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+
+	"golang.org/x/oauth2"
+
+	"github.com/sainaif/council/internal/config"
+	"github.com/sainaif/council/internal/database"
+)
+
+// Role is an instance-wide permission level
+type Role string
+
+const (
+	RoleViewer Role = "viewer" // Read-only access
+	RoleMember Role = "member" // Can run councils and vote
+	RoleAdmin  Role = "admin"  // Can manage categories and users
+)
+
+var roleRank = map[Role]int{
+	RoleViewer: 1,
+	RoleMember: 2,
+	RoleAdmin:  3,
+}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := roleRank[r]
+	return ok
+}
+
+// AtLeast reports whether r grants everything min does
+func (r Role) AtLeast(min Role) bool {
+	return roleRank[r] >= roleRank[min]
+}
+
+// RoleService stores user roles and applies the bootstrap admins from config
+type RoleService struct {
+	db          *database.DB
+	auth        *GitHubAuth
+	adminUsers  map[string]bool
+	adminTeams  []string
+	defaultRole Role
+}
+
+// NewRoleService creates a new role service
+func NewRoleService(db *database.DB, auth *GitHubAuth, cfg *config.Config) *RoleService {
+	adminUsers := make(map[string]bool, len(cfg.AdminUsers))
+	for _, name := range cfg.AdminUsers {
+		adminUsers[strings.ToLower(name)] = true
+	}
+	adminTeams := make([]string, len(cfg.AdminTeams))
+	for i, team := range cfg.AdminTeams {
+		adminTeams[i] = strings.ToLower(team)
+	}
+
+	return &RoleService{
+		db:          db,
+		auth:        auth,
+		adminUsers:  adminUsers,
+		adminTeams:  adminTeams,
+		defaultRole: Role(cfg.DefaultRole),
+	}
+}
+
+// Get returns the effective role of a user. Configured admin usernames are
+// always admins; everyone else gets their stored role or the default. When
+// the stored role cannot be read an error is returned rather than the
+// default, which could be higher than the role the user was given.
+func (s *RoleService) Get(userID, username string) (Role, error) {
+	if s.IsConfiguredAdmin(username) {
+		return RoleAdmin, nil
+	}
+
+	var role sql.NullString
+	err := s.db.QueryRow(`SELECT role FROM user_preferences WHERE user_id = ?`, userID).Scan(&role)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	if role.Valid && Role(role.String).Valid() {
+		return Role(role.String), nil
+	}
+	return s.defaultRole, nil
+}
+
+// IsConfiguredAdmin reports whether a username is listed in ADMIN_USERS
+func (s *RoleService) IsConfiguredAdmin(username string) bool {
+	return s.adminUsers[strings.ToLower(username)]
+}
+
+// Set stores a role an admin gave a user. It is kept whatever teams the user
+// is in.
+func (s *RoleService) Set(userID string, role Role) error {
+	_, err := s.db.Exec(`
+		UPDATE user_preferences SET role = ?, role_source = 'manual', updated_at = CURRENT_TIMESTAMP WHERE user_id = ?
+	`, string(role), userID)
+	return err
+}
+
+// BootstrapAtLogin applies the configured admin orgs and teams to a user who
+// signs in
+func (s *RoleService) BootstrapAtLogin(ctx context.Context, userID string, token *oauth2.Token) {
+	if len(s.adminTeams) == 0 {
+		return
+	}
+
+	memberships, err := s.auth.GetMemberships(ctx, token)
+	if err != nil {
+		log.Printf("[AUTH] Failed to check admin team membership for user %s: %v", userID, err)
+		return
+	}
+	if err := s.SyncTeams(userID, memberships); err != nil {
+		log.Printf("[AUTH] Failed to update admin role of user %s: %v", userID, err)
+	}
+}
+
+// SyncTeams promotes a member of one of the configured admin orgs or teams to
+// admin, and demotes an admin who got the role that way once they are no
+// longer in any of them. Roles set by an admin are never changed here.
+func (s *RoleService) SyncTeams(userID string, memberships map[string]bool) error {
+	if len(s.adminTeams) == 0 {
+		return nil
+	}
+
+	for _, team := range s.adminTeams {
+		if !memberships[team] {
+			continue
+		}
+		res, err := s.db.Exec(`
+			UPDATE user_preferences SET role = 'admin', role_source = 'team', updated_at = CURRENT_TIMESTAMP
+			WHERE user_id = ? AND (role IS NULL OR role <> 'admin')
+		`, userID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			log.Printf("[AUTH] Granted admin role to user %s via %s", userID, team)
+		}
+		return nil
+	}
+
+	// Back to the default role
+	res, err := s.db.Exec(`
+		UPDATE user_preferences SET role = NULL, role_source = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND role = 'admin' AND role_source = 'team'
+	`, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("[AUTH] Revoked admin role of user %s: no longer in an admin team", userID)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/sainaif/council/internal/config"
+)
+
+func TestRoleServiceTeamAdmins(t *testing.T) {
+	ctx := context.Background()
+	token := &oauth2.Token{AccessToken: "gho_test"}
+	cfg := &config.Config{AdminTeams: []string{"Acme/Admins"}, DefaultRole: "member", AccessRecheckHours: 24}
+
+	db := openDB(t)
+	seedUser(t, db, "42", "bob")
+	seedUser(t, db, "43", "carol")
+	fake, gh := newFakeGitHub(t, "acme/admins")
+	roles := NewRoleService(db, gh, cfg)
+
+	assertRole := func(userID, username string, want Role) {
+		t.Helper()
+		got, err := roles.Get(userID, username)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", username, err)
+		}
+		if got != want {
+			t.Errorf("role of %s = %s, want %s", username, got, want)
+		}
+	}
+
+	// Promoted at sign-in through the team
+	roles.BootstrapAtLogin(ctx, "42", token)
+	assertRole("42", "bob", RoleAdmin)
+
+	// Manual admin grants stay whatever the teams say
+	if err := roles.Set("43", RoleAdmin); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// GitHub errors leave roles as they are
+	fake.setFailing(true)
+	roles.BootstrapAtLogin(ctx, "42", token)
+	assertRole("42", "bob", RoleAdmin)
+	fake.setFailing(false)
+
+	// Demoted at the next sign-in after leaving the team
+	fake.set("acme")
+	roles.BootstrapAtLogin(ctx, "42", token)
+	roles.BootstrapAtLogin(ctx, "43", token)
+	assertRole("42", "bob", RoleMember)
+	assertRole("43", "carol", RoleAdmin)
+
+	// Promoted again on rejoining, then demoted by the periodic recheck
+	fake.set("acme/admins")
+	roles.BootstrapAtLogin(ctx, "42", token)
+	assertRole("42", "bob", RoleAdmin)
+
+	policy := NewAccessPolicy(db, gh, roles, cfg)
+	claims := &Claims{UserID: "42", Username: "bob", AccessToken: "gho_test"}
+	mustExec(t, db, `UPDATE user_preferences SET access_checked_at = '2000-01-01 00:00:00' WHERE user_id = '42'`)
+	fake.set("acme/admins", "globex")
+	if err := policy.Recheck(ctx, claims); err != nil {
+		t.Fatalf("Recheck of a team member = %v, want nil", err)
+	}
+	assertRole("42", "bob", RoleAdmin)
+
+	mustExec(t, db, `UPDATE user_preferences SET access_checked_at = '2000-01-01 00:00:00' WHERE user_id = '42'`)
+	fake.set("globex")
+	if err := policy.Recheck(ctx, claims); err == nil {
+		t.Errorf("Recheck after leaving the only allowed team = nil, want ErrAccessDenied")
+	}
+	assertRole("42", "bob", RoleMember)
+
+	// An admin's later manual grant is not taken back either
+	fake.set("acme/admins")
+	roles.BootstrapAtLogin(ctx, "42", token)
+	if err := roles.Set("42", RoleAdmin); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	fake.set()
+	roles.BootstrapAtLogin(ctx, "42", token)
+	assertRole("42", "bob", RoleAdmin)
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// timestampFormat matches SQLite's CURRENT_TIMESTAMP so values compare as text
+const timestampFormat = "2006-01-02 15:04:05"
+
+// maxUserAgentLength caps the stored User-Agent header
+const maxUserAgentLength = 512
+
+// SessionMeta describes the device a session was signed in from
+type SessionMeta struct {
+	UserAgent string
+	IPAddress string
+}
+
+// Session is an active sign-in as shown to its user
+type Session struct {
+	ID         string     `json:"id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Current    bool       `json:"current"`
+}
+
+// ListSessions returns a user's sessions that are neither revoked nor
+// expired, most recently used first
+func (v *Vault) ListSessions(userID string) ([]Session, error) {
+	rows, err := v.db.Query(`
+		SELECT id, COALESCE(user_agent, ''), COALESCE(ip_address, ''), created_at, last_used_at, expires_at
+		FROM auth_sessions
+		WHERE user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY last_used_at DESC
+	`, userID, time.Now().UTC().Format(timestampFormat))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&s.ID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastUsedAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			s.ExpiresAt = &expiresAt.Time
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeOtherSessions signs a user out everywhere except the given session
+// and returns how many sessions were revoked
+func (v *Vault) RevokeOtherSessions(ctx context.Context, userID, keepID string) (int, error) {
+	sessions, err := v.ListSessions(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for _, s := range sessions {
+		if s.ID == keepID {
+			continue
+		}
+		if err := v.Revoke(ctx, s.ID, userID); err != nil {
+			if errors.Is(err, ErrSessionInvalid) {
+				continue
+			}
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// UserAccessToken returns the GitHub token of the user's most recently used
+// session, for requests made without one such as personal API tokens. It
+// returns ErrSessionInvalid when the user has no active session.
+func (v *Vault) UserAccessToken(ctx context.Context, userID string) (string, error) {
+	sessions, err := v.ListSessions(userID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, s := range sessions {
+		token, err := v.AccessToken(ctx, s.ID, userID)
+		if errors.Is(err, ErrSessionInvalid) {
+			continue
+		}
+		return token, err
+	}
+	return "", ErrSessionInvalid
+}
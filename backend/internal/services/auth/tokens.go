@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+// APITokenPrefix marks personal API tokens so they are not mistaken for JWTs
+const APITokenPrefix = "council_pat_"
+
+// Scopes a personal API token can be granted
+const (
+	ScopeCouncilStart  = "council:start"  // Start and cancel councils, list models
+	ScopeCouncilRead   = "council:read"   // Read council history and results
+	ScopeAnalyticsRead = "analytics:read" // Read rankings, matchups and analytics
+)
+
+// Scopes lists every scope in display order
+var Scopes = []string{ScopeCouncilStart, ScopeCouncilRead, ScopeAnalyticsRead}
+
+// ErrTokenInvalid is returned for unknown, revoked or expired API tokens
+var ErrTokenInvalid = errors.New("invalid or expired API token")
+
+// APIToken is a personal API token as shown to its owner. The token itself
+// is only returned once, when it is created.
+type APIToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	Username   string     `json:"-"` // Set by Authenticate
+	AvatarURL  string     `json:"-"` // Set by Authenticate
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// ValidScope reports whether a scope exists
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APITokens issues and verifies personal API tokens
+type APITokens struct {
+	db *database.DB
+}
+
+// NewAPITokens creates the personal API token store
+func NewAPITokens(db *database.DB) *APITokens {
+	return &APITokens{db: db}
+}
+
+// Create mints a token for a user and returns it in plain text together
+// with its stored record
+func (s *APITokens) Create(userID, name string, scopes []string, ttl time.Duration) (string, *APIToken, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(raw)
+	plaintext := APITokenPrefix + secret
+
+	idBytes := make([]byte, 9)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now().UTC()
+	token := &APIToken{
+		ID:        base64.RawURLEncoding.EncodeToString(idBytes),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    APITokenPrefix + secret[:6],
+		Scopes:    scopes,
+		ExpiresAt: now.Add(ttl).Truncate(time.Second),
+		CreatedAt: now.Truncate(time.Second),
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO api_tokens (id, user_id, name, token_hash, token_prefix, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, token.ID, userID, name, hashAPIToken(plaintext), token.Prefix, strings.Join(scopes, ","),
+		token.ExpiresAt.Format(timestampFormat), token.CreatedAt.Format(timestampFormat))
+	if err != nil {
+		return "", nil, err
+	}
+	return plaintext, token, nil
+}
+
+// List returns a user's tokens that are neither revoked nor expired
+func (s *APITokens) List(userID string) ([]APIToken, error) {
+	rows, err := s.db.Query(`
+		SELECT id, user_id, name, token_prefix, scopes, expires_at, created_at, last_used_at
+		FROM api_tokens
+		WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+		ORDER BY created_at DESC
+	`, userID, time.Now().UTC().Format(timestampFormat))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
+// Revoke disables one of a user's tokens. It reports whether the token
+// existed and was still active.
+func (s *APITokens) Revoke(userID, id string) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE api_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// Authenticate looks up the token presented by a client
+func (s *APITokens) Authenticate(plaintext string) (*APIToken, error) {
+	row := s.db.QueryRow(`
+		SELECT id, user_id, name, token_prefix, scopes, expires_at, created_at, last_used_at
+		FROM api_tokens
+		WHERE token_hash = ? AND revoked_at IS NULL AND expires_at > ?
+	`, hashAPIToken(plaintext), time.Now().UTC().Format(timestampFormat))
+
+	token, err := scanAPIToken(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.QueryRow(`
+		SELECT github_username, COALESCE(github_avatar_url, '') FROM user_preferences WHERE user_id = ?
+	`, token.UserID).Scan(&token.Username, &token.AvatarURL); err != nil {
+		return nil, err
+	}
+
+	// Only touch last_used_at once a minute to spare the single connection
+	_, _ = s.db.Exec(`
+		UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)
+	`, token.ID, time.Now().UTC().Add(-time.Minute).Format(timestampFormat))
+
+	return token, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIToken(row rowScanner) (*APIToken, error) {
+	var t APIToken
+	var scopes string
+	var lastUsedAt sql.NullTime
+	if err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Prefix, &scopes, &t.ExpiresAt, &t.CreatedAt, &lastUsedAt); err != nil {
+		return nil, err
+	}
+	t.Scopes = strings.Split(scopes, ",")
+	if lastUsedAt.Valid {
+		t.LastUsedAt = &lastUsedAt.Time
+	}
+	return &t, nil
+}
+
+func hashAPIToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashAPIToken(t *testing.T) {
+	a := hashAPIToken(APITokenPrefix + "secret")
+	if a != hashAPIToken(APITokenPrefix+"secret") {
+		t.Errorf("hash is not deterministic")
+	}
+	if a == hashAPIToken(APITokenPrefix+"secreT") {
+		t.Errorf("different tokens share a hash")
+	}
+	if len(a) != 64 || strings.Contains(a, "secret") {
+		t.Errorf("hash = %q, want 64 hex characters", a)
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	db := openDB(t)
+	seedUser(t, db, "42", "bob")
+	tokens := NewAPITokens(db)
+
+	plaintext, created, err := tokens.Create("42", "ci", []string{ScopeCouncilStart, ScopeCouncilRead}, time.Hour)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !strings.HasPrefix(plaintext, created.Prefix) || !strings.HasPrefix(created.Prefix, APITokenPrefix) {
+		t.Errorf("token %q does not start with its prefix %q", plaintext, created.Prefix)
+	}
+
+	// Only the hash is stored
+	var stored string
+	if err := db.QueryRow(`SELECT token_hash FROM api_tokens WHERE id = ?`, created.ID).Scan(&stored); err != nil {
+		t.Fatalf("read hash: %v", err)
+	}
+	if stored != hashAPIToken(plaintext) {
+		t.Errorf("stored %q, want the token's hash", stored)
+	}
+
+	got, err := tokens.Authenticate(plaintext)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if got.ID != created.ID || got.UserID != "42" || got.Username != "bob" || strings.Join(got.Scopes, ",") != "council:start,council:read" {
+		t.Errorf("Authenticate = %+v", got)
+	}
+
+	tests := []struct {
+		name      string
+		plaintext string
+	}{
+		{"unknown", APITokenPrefix + "unknown"},
+		{"truncated", plaintext[:len(plaintext)-1]},
+		{"prefix only", created.Prefix},
+	}
+	for _, tt := range tests {
+		if _, err := tokens.Authenticate(tt.plaintext); !errors.Is(err, ErrTokenInvalid) {
+			t.Errorf("Authenticate(%s) = %v, want ErrTokenInvalid", tt.name, err)
+		}
+	}
+
+	t.Run("expiry", func(t *testing.T) {
+		expired, _, err := tokens.Create("42", "old", []string{ScopeCouncilRead}, -time.Minute)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if _, err := tokens.Authenticate(expired); !errors.Is(err, ErrTokenInvalid) {
+			t.Errorf("Authenticate(expired) = %v, want ErrTokenInvalid", err)
+		}
+		list, err := tokens.List("42")
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(list) != 1 || list[0].ID != created.ID {
+			t.Errorf("List = %+v, want only the active token", list)
+		}
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		if ok, err := tokens.Revoke("43", created.ID); err != nil || ok {
+			t.Errorf("Revoke by another user = %v, %v, want false", ok, err)
+		}
+		if ok, err := tokens.Revoke("42", created.ID); err != nil || !ok {
+			t.Fatalf("Revoke = %v, %v, want true", ok, err)
+		}
+		if ok, err := tokens.Revoke("42", created.ID); err != nil || ok {
+			t.Errorf("second Revoke = %v, %v, want false", ok, err)
+		}
+		if _, err := tokens.Authenticate(plaintext); !errors.Is(err, ErrTokenInvalid) {
+			t.Errorf("Authenticate(revoked) = %v, want ErrTokenInvalid", err)
+		}
+	})
+}
+
+func TestValidScope(t *testing.T) {
+	for _, scope := range Scopes {
+		if !ValidScope(scope) {
+			t.Errorf("ValidScope(%s) = false", scope)
+		}
+	}
+	for _, scope := range []string{"", "admin", "council:*", "COUNCIL:START"} {
+		if ValidScope(scope) {
+			t.Errorf("ValidScope(%q) = true", scope)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/sainaif/council/internal/config"
+	"github.com/sainaif/council/internal/database"
+)
+
+// ErrSessionInvalid is returned for sessions that are unknown, revoked or
+// whose GitHub token expired and could not be refreshed
+var ErrSessionInvalid = errors.New("session is no longer valid")
+
+// refreshMargin is how long before expiry a token is refreshed
+const refreshMargin = time.Minute
+
+// Vault stores GitHub tokens server-side, encrypted with AES-GCM, keyed by
+// an opaque session ID that is the only thing the JWT carries
+type Vault struct {
+	db        *database.DB
+	auth      *GitHubAuth
+	aead      cipher.AEAD
+	refreshMu sync.Mutex // GitHub refresh tokens are single-use
+}
+
+// NewVault derives the encryption key from TOKEN_ENCRYPTION_KEY, or from
+// the session secret when it is unset. Changing either invalidates all
+// stored sessions.
+func NewVault(db *database.DB, auth *GitHubAuth, cfg *config.Config) (*Vault, error) {
+	secret := cfg.TokenEncryptionKey
+	if secret == "" {
+		secret = cfg.SessionSecret
+	}
+
+	key, err := hkdf.Key(sha256.New, []byte(secret), nil, "council-arena token vault", 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive token key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Vault{db: db, auth: auth, aead: aead}, nil
+}
+
+// Create stores a user's tokens and returns the new session ID. The
+// session expires together with the JWT that references it.
+func (v *Vault) Create(userID string, token *oauth2.Token, meta SessionMeta) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	sessionID := base64.RawURLEncoding.EncodeToString(raw)
+
+	accessEnc, refreshEnc, tokenExpiresAt, err := v.sealToken(sessionID, token)
+	if err != nil {
+		return "", err
+	}
+
+	userAgent := meta.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	_, err = v.db.Exec(`
+		INSERT INTO auth_sessions (id, user_id, access_token_enc, refresh_token_enc, token_expires_at, user_agent, ip_address, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, sessionID, userID, accessEnc, refreshEnc, tokenExpiresAt, userAgent, meta.IPAddress,
+		time.Now().UTC().Add(v.auth.tokenExpiry).Format(timestampFormat))
+	if err != nil {
+		return "", err
+	}
+	return sessionID, nil
+}
+
+// AccessToken returns the GitHub access token of a user's session,
+// refreshing it first when it is about to expire
+func (v *Vault) AccessToken(ctx context.Context, sessionID, userID string) (string, error) {
+	stored, err := v.load(sessionID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if expiring(stored) {
+		stored, err = v.refresh(ctx, sessionID, userID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Only touch last_used_at once a minute to spare the single connection
+	_, _ = v.db.Exec(`
+		UPDATE auth_sessions SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND last_used_at < ?
+	`, sessionID, time.Now().UTC().Add(-time.Minute).Format(timestampFormat))
+
+	return stored.AccessToken, nil
+}
+
+// Revoke ends a session: its tokens are wiped from the vault and the
+// access token is revoked at GitHub. It returns ErrSessionInvalid for
+// sessions that do not exist or have already ended.
+func (v *Vault) Revoke(ctx context.Context, sessionID, userID string) error {
+	stored, err := v.load(sessionID, userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := v.db.Exec(`
+		UPDATE auth_sessions SET
+			access_token_enc = NULL,
+			refresh_token_enc = NULL,
+			revoked_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, sessionID); err != nil {
+		return err
+	}
+
+	if err := v.auth.RevokeToken(ctx, stored.AccessToken); err != nil {
+		log.Printf("[AUTH] Failed to revoke GitHub token for user %s: %v", userID, err)
+	}
+	return nil
+}
+
+// refresh swaps an expiring token for a new one. Requests racing on the
+// same session wait for the first refresh and reuse its result.
+func (v *Vault) refresh(ctx context.Context, sessionID, userID string) (*oauth2.Token, error) {
+	v.refreshMu.Lock()
+	defer v.refreshMu.Unlock()
+
+	stored, err := v.load(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !expiring(stored) {
+		return stored, nil
+	}
+
+	if stored.RefreshToken != "" {
+		token, err := v.auth.Refresh(ctx, stored.RefreshToken)
+		if err == nil {
+			if err := v.store(sessionID, token); err != nil {
+				return nil, err
+			}
+			log.Printf("[AUTH] Refreshed GitHub token for user %s", userID)
+			return token, nil
+		}
+		log.Printf("[AUTH] Failed to refresh GitHub token for user %s: %v", userID, err)
+	}
+
+	// Keep using the current token until it actually expires
+	if time.Now().Before(stored.Expiry) {
+		return stored, nil
+	}
+	return nil, ErrSessionInvalid
+}
+
+func (v *Vault) load(sessionID, userID string) (*oauth2.Token, error) {
+	var accessEnc, refreshEnc []byte
+	var expiresAt, revokedAt, sessionExpiresAt sql.NullTime
+	err := v.db.QueryRow(`
+		SELECT access_token_enc, refresh_token_enc, token_expires_at, revoked_at, expires_at
+		FROM auth_sessions WHERE id = ? AND user_id = ?
+	`, sessionID, userID).Scan(&accessEnc, &refreshEnc, &expiresAt, &revokedAt, &sessionExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrSessionInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid || accessEnc == nil {
+		return nil, ErrSessionInvalid
+	}
+	if sessionExpiresAt.Valid && time.Now().After(sessionExpiresAt.Time) {
+		return nil, ErrSessionInvalid
+	}
+
+	token := &oauth2.Token{}
+	if token.AccessToken, err = v.open(sessionID, accessEnc); err != nil {
+		// Encrypted under a different key, e.g. after the secret changed
+		return nil, ErrSessionInvalid
+	}
+	if refreshEnc != nil {
+		if token.RefreshToken, err = v.open(sessionID, refreshEnc); err != nil {
+			return nil, ErrSessionInvalid
+		}
+	}
+	if expiresAt.Valid {
+		token.Expiry = expiresAt.Time
+	}
+	return token, nil
+}
+
+func (v *Vault) store(sessionID string, token *oauth2.Token) error {
+	accessEnc, refreshEnc, expiresAt, err := v.sealToken(sessionID, token)
+	if err != nil {
+		return err
+	}
+	_, err = v.db.Exec(`
+		UPDATE auth_sessions SET access_token_enc = ?, refresh_token_enc = ?, token_expires_at = ?
+		WHERE id = ? AND revoked_at IS NULL
+	`, accessEnc, refreshEnc, expiresAt, sessionID)
+	return err
+}
+
+func (v *Vault) sealToken(sessionID string, token *oauth2.Token) (accessEnc, refreshEnc []byte, expiresAt *time.Time, err error) {
+	if accessEnc, err = v.seal(sessionID, token.AccessToken); err != nil {
+		return nil, nil, nil, err
+	}
+	if token.RefreshToken != "" {
+		if refreshEnc, err = v.seal(sessionID, token.RefreshToken); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if !token.Expiry.IsZero() {
+		expiry := token.Expiry.UTC()
+		expiresAt = &expiry
+	}
+	return accessEnc, refreshEnc, expiresAt, nil
+}
+
+// seal encrypts a token, binding it to its session so ciphertexts cannot
+// be swapped between rows
+func (v *Vault) seal(sessionID, plaintext string) ([]byte, error) {
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return v.aead.Seal(nonce, nonce, []byte(plaintext), []byte(sessionID)), nil
+}
+
+func (v *Vault) open(sessionID string, ciphertext []byte) (string, error) {
+	size := v.aead.NonceSize()
+	if len(ciphertext) < size {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+	plaintext, err := v.aead.Open(nil, ciphertext[:size], ciphertext[size:], []byte(sessionID))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// expiring reports whether a token expires within the refresh margin.
+// Classic OAuth App tokens have no expiry and never refresh.
+func expiring(token *oauth2.Token) bool {
+	return !token.Expiry.IsZero() && time.Until(token.Expiry) < refreshMargin
+}
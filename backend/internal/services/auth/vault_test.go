@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/sainaif/council/internal/config"
+)
+
+func TestVault(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	seedUser(t, db, "42", "bob")
+	fake, gh := newFakeGitHub(t)
+	gh.tokenExpiry = time.Hour
+	vault, err := NewVault(db, gh, &config.Config{SessionSecret: "test-secret"})
+	if err != nil {
+		t.Fatalf("NewVault: %v", err)
+	}
+
+	older, err := vault.Create("42", &oauth2.Token{AccessToken: "gho_older"}, SessionMeta{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	mustExec(t, db, `UPDATE auth_sessions SET last_used_at = '2000-01-01 00:00:00' WHERE id = ?`, older)
+	latest, err := vault.Create("42", &oauth2.Token{AccessToken: "gho_latest"}, SessionMeta{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Tokens are stored encrypted
+	var enc []byte
+	if err := db.QueryRow(`SELECT access_token_enc FROM auth_sessions WHERE id = ?`, latest).Scan(&enc); err != nil {
+		t.Fatalf("read session: %v", err)
+	}
+	if string(enc) == "gho_latest" {
+		t.Errorf("access token stored in plain text")
+	}
+
+	if token, err := vault.AccessToken(ctx, latest, "42"); err != nil || token != "gho_latest" {
+		t.Errorf("AccessToken = %q, %v, want gho_latest", token, err)
+	}
+	if _, err := vault.AccessToken(ctx, latest, "43"); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("AccessToken of another user = %v, want ErrSessionInvalid", err)
+	}
+	if token, err := vault.UserAccessToken(ctx, "42"); err != nil || token != "gho_latest" {
+		t.Errorf("UserAccessToken = %q, %v, want the most recent session's token", token, err)
+	}
+
+	// Ciphertexts are bound to their session
+	mustExec(t, db, `UPDATE auth_sessions SET access_token_enc = ? WHERE id = ?`, enc, older)
+	if _, err := vault.AccessToken(ctx, older, "42"); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("AccessToken with a swapped ciphertext = %v, want ErrSessionInvalid", err)
+	}
+
+	// Signing out revokes the GitHub token, and API tokens lose it too
+	if err := vault.Revoke(ctx, latest, "42"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if revoked := fake.revokedTokens(); len(revoked) != 1 || revoked[0] != "gho_latest" {
+		t.Errorf("revoked at GitHub = %v, want [gho_latest]", revoked)
+	}
+	if _, err := vault.AccessToken(ctx, latest, "42"); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("AccessToken after Revoke = %v, want ErrSessionInvalid", err)
+	}
+	if err := vault.Revoke(ctx, latest, "42"); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("second Revoke = %v, want ErrSessionInvalid", err)
+	}
+	if _, err := vault.UserAccessToken(ctx, "42"); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("UserAccessToken without a session = %v, want ErrSessionInvalid", err)
+	}
+
+	// Sessions end with the JWT that references them
+	expiring, err := vault.Create("42", &oauth2.Token{AccessToken: "gho_expiring"}, SessionMeta{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	mustExec(t, db, `UPDATE auth_sessions SET expires_at = '2000-01-01 00:00:00' WHERE id = ?`, expiring)
+	if _, err := vault.AccessToken(ctx, expiring, "42"); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("AccessToken of an expired session = %v, want ErrSessionInvalid", err)
+	}
+}
@@ -0,0 +1,272 @@
+package classifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/sainaif/council/internal/config"
+	"github.com/sainaif/council/internal/database"
+	"github.com/sainaif/council/internal/services/copilot"
+)
+
+// Classifier modes
+const (
+	ModeOff     = "off"
+	ModeKeyword = "keyword"
+	ModeModel   = "model"
+)
+
+// Sources recorded in sessions.category_source
+const (
+	SourceUser     = "user"     // Chosen when the session was started
+	SourceKeyword  = "keyword"  // Assigned by the keyword classifier
+	SourceModel    = "model"    // Assigned by the model classifier
+	SourceOverride = "override" // Changed by the user afterwards
+)
+
+// Result is the category assigned to a question
+type Result struct {
+	CategoryID int64   `json:"category_id"`
+	Category   string  `json:"category"`
+	Confidence float64 `json:"confidence"`
+	Source     string  `json:"source"`
+}
+
+type category struct {
+	id          int64
+	name        string
+	description string
+}
+
+// keywords holds hand-picked terms for the default categories. Custom
+// categories are matched on their name and description only.
+var keywords = map[string][]string{
+	"coding": {
+		"code", "function", "bug", "debug", "error", "exception", "compile", "compiler",
+		"api", "class", "method", "variable", "python", "javascript", "typescript", "golang",
+		"java", "rust", "sql", "query", "database", "regex", "git", "docker", "kubernetes",
+		"refactor", "algorithm", "library", "framework", "frontend", "backend", "deploy",
+		"script", "program", "programming", "stack", "html", "css", "json", "http",
+	},
+	"creative": {
+		"story", "poem", "poetry", "write", "lyrics", "song", "slogan", "brainstorm",
+		"character", "plot", "novel", "fiction", "haiku", "creative", "imagine", "tagline",
+		"screenplay", "dialogue", "metaphor", "fantasy", "name", "names",
+	},
+	"reasoning": {
+		"logic", "logical", "puzzle", "riddle", "deduce", "infer", "argument", "argue",
+		"paradox", "why", "tradeoff", "compare", "evaluate", "decide", "strategy",
+		"implication", "assumption", "fallacy", "hypothesis", "cause",
+	},
+	"math": {
+		"math", "equation", "integral", "derivative", "probability", "calculate", "prime",
+		"matrix", "sum", "algebra", "geometry", "theorem", "proof", "prove", "statistics",
+		"variance", "mean", "median", "percent", "fraction", "polynomial", "solve", "limit",
+	},
+}
+
+// Service assigns categories to questions started without one
+type Service struct {
+	db      *database.DB
+	copilot *copilot.Service
+	cfg     config.ClassifierConfig
+}
+
+// NewService creates a new category classifier
+func NewService(db *database.DB, copilot *copilot.Service, cfg config.ClassifierConfig) *Service {
+	return &Service{db: db, copilot: copilot, cfg: cfg}
+}
+
+// Enabled reports whether questions should be classified at all
+func (s *Service) Enabled() bool {
+	return s.cfg.Mode == ModeKeyword || s.cfg.Mode == ModeModel
+}
+
+// Classify picks a category for the question. It returns nil when no
+// category reaches the configured confidence.
+func (s *Service) Classify(ctx context.Context, userID, accessToken, question string) (*Result, error) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+
+	categories, err := s.loadCategories()
+	if err != nil {
+		return nil, err
+	}
+	if len(categories) == 0 {
+		return nil, nil
+	}
+
+	var result *Result
+	if s.cfg.Mode == ModeModel {
+		result, err = s.classifyWithModel(ctx, userID, accessToken, question, categories)
+		if err != nil {
+			log.Printf("[CLASSIFIER] Model classification failed, falling back to keywords: %v", err)
+		}
+	}
+	if result == nil {
+		result = classifyByKeywords(question, categories)
+	}
+
+	if result == nil || result.Confidence < s.cfg.MinConfidence {
+		return nil, nil
+	}
+	return result, nil
+}
+
+func (s *Service) loadCategories() ([]category, error) {
+	rows, err := s.db.Query(`SELECT id, name, COALESCE(description, '') FROM categories WHERE archived_at IS NULL ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var categories []category
+	for rows.Next() {
+		var c category
+		if err := rows.Scan(&c.id, &c.name, &c.description); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// classifyWithModel asks the configured model to pick a category and rate
+// its own confidence
+func (s *Service) classifyWithModel(ctx context.Context, userID, accessToken, question string, categories []category) (*Result, error) {
+	prompt := `Classify the question below into exactly one of these categories:
+
+`
+	for _, c := range categories {
+		prompt += fmt.Sprintf("- %s: %s\n", c.name, c.description)
+	}
+	prompt += fmt.Sprintf(`
+Question: %s
+
+Reply with ONLY a JSON object in this format, with confidence between 0 and 1:
+{"category": "<name>", "confidence": 0.8}`, question)
+
+	resp, err := s.copilot.SendPrompt(ctx, userID, accessToken, s.cfg.Model, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	content := resp.Content
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON object in classifier reply")
+	}
+
+	var reply struct {
+		Category   string  `json:"category"`
+		Confidence float64 `json:"confidence"`
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &reply); err != nil {
+		return nil, fmt.Errorf("invalid classifier reply: %w", err)
+	}
+
+	for _, c := range categories {
+		if strings.EqualFold(c.name, strings.TrimSpace(reply.Category)) {
+			return &Result{
+				CategoryID: c.id,
+				Category:   c.name,
+				Confidence: clamp(reply.Confidence),
+				Source:     SourceModel,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown category in classifier reply: %q", reply.Category)
+}
+
+// classifyByKeywords scores each category by the question terms it matches.
+// Confidence is the winner's share of all matches, discounted when only a
+// few terms matched at all.
+func classifyByKeywords(question string, categories []category) *Result {
+	terms := make(map[string]bool)
+	for _, t := range tokenize(question) {
+		terms[t] = true
+	}
+
+	scores := make([]float64, len(categories))
+	total := 0.0
+	for i, c := range categories {
+		weighted := map[string]float64{}
+		for _, t := range tokenize(c.description) {
+			weighted[t] = 1
+		}
+		for _, k := range keywords[c.name] {
+			weighted[stem(k)] = 2
+		}
+		weighted[stem(strings.ToLower(c.name))] = 3
+
+		for term, w := range weighted {
+			if terms[term] {
+				scores[i] += w
+			}
+		}
+		total += scores[i]
+	}
+
+	if total == 0 {
+		return nil
+	}
+
+	best := 0
+	for i := range scores {
+		if scores[i] > scores[best] {
+			best = i
+		}
+	}
+
+	top := scores[best]
+	return &Result{
+		CategoryID: categories[best].id,
+		Category:   categories[best].name,
+		Confidence: clamp(top / total * top / (top + 2)),
+		Source:     SourceKeyword,
+	}
+}
+
+// stopWords are skipped when matching category descriptions
+var stopWords = map[string]bool{
+	"and": true, "or": true, "the": true, "a": true, "an": true, "of": true,
+	"to": true, "in": true, "for": true, "on": true, "with": true, "is": true,
+}
+
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if len(f) < 2 || stopWords[f] {
+			continue
+		}
+		tokens = append(tokens, stem(f))
+	}
+	return tokens
+}
+
+// stem strips a plural "s" so "bugs" matches "bug"
+func stem(word string) string {
+	if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+func clamp(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,109 @@
+package classifier
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sainaif/council/internal/config"
+	"github.com/sainaif/council/internal/database"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Fix the bugs in my class", []string{"fix", "bug", "my", "class"}},
+		{"Go's regex, or Rust?", []string{"go", "regex", "rust"}},
+		{"a I x", []string{}},
+	}
+	for _, tt := range tests {
+		if got := tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyByKeywords(t *testing.T) {
+	categories := []category{
+		{1, "coding", "Programming, software development, and technical problems"},
+		{2, "reasoning", "Logic, analysis, and problem-solving"},
+		{3, "physics", "Forces, motion and energy"},
+	}
+
+	tests := []struct {
+		question string
+		want     string
+	}{
+		{"Why does my Python function throw an error?", "coding"},
+		{"How does energy relate to motion in physics?", "physics"},
+		{"Is this argument a fallacy?", "reasoning"},
+		{"Hello there", ""},
+	}
+	for _, tt := range tests {
+		got := classifyByKeywords(tt.question, categories)
+		if tt.want == "" {
+			if got != nil {
+				t.Errorf("classifyByKeywords(%q) = %+v, want none", tt.question, got)
+			}
+			continue
+		}
+		if got == nil || got.Category != tt.want || got.Source != SourceKeyword {
+			t.Errorf("classifyByKeywords(%q) = %+v, want %s", tt.question, got, tt.want)
+			continue
+		}
+		if got.Confidence <= 0 || got.Confidence > 1 {
+			t.Errorf("classifyByKeywords(%q) confidence = %v, want within (0, 1]", tt.question, got.Confidence)
+		}
+	}
+
+	// Matches spread over several categories lower the confidence
+	focused := classifyByKeywords("Python function error", categories)
+	mixed := classifyByKeywords("Why does this Python function error?", categories)
+	if focused.Confidence <= mixed.Confidence {
+		t.Errorf("confidence of a focused question %v <= a mixed one %v", focused.Confidence, mixed.Confidence)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	question := "Why does my Python function throw an error?"
+	tests := []struct {
+		name    string
+		cfg     config.ClassifierConfig
+		archive string
+		want    string
+	}{
+		{"off", config.ClassifierConfig{Mode: ModeOff}, "", ""},
+		{"keyword", config.ClassifierConfig{Mode: ModeKeyword, MinConfidence: 0.3}, "", "coding"},
+		{"below minimum confidence", config.ClassifierConfig{Mode: ModeKeyword, MinConfidence: 0.9}, "", ""},
+		{"archived categories skipped", config.ClassifierConfig{Mode: ModeKeyword, MinConfidence: 0.3}, "coding", "reasoning"},
+	}
+	for _, tt := range tests {
+		if tt.archive != "" {
+			if _, err := db.Exec(`UPDATE categories SET archived_at = CURRENT_TIMESTAMP WHERE name = ?`, tt.archive); err != nil {
+				t.Fatalf("archive %s: %v", tt.archive, err)
+			}
+		}
+
+		got, err := NewService(db, nil, tt.cfg).Classify(context.Background(), "u1", "", question)
+		if err != nil {
+			t.Fatalf("%s: Classify: %v", tt.name, err)
+		}
+		switch {
+		case tt.want == "" && got != nil:
+			t.Errorf("%s: Classify = %+v, want none", tt.name, got)
+		case tt.want != "" && (got == nil || got.Category != tt.want):
+			t.Errorf("%s: Classify = %+v, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package copilot
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"sync"
@@ -36,6 +38,7 @@ type StreamChunk struct {
 
 // userClient holds a Copilot client for a specific user
 type userClient struct {
+	userID    string
 	client    *copilot.Client
 	createdAt time.Time
 	lastUsed  time.Time
@@ -43,7 +46,7 @@ type userClient struct {
 
 // Service manages Copilot SDK interactions with per-user authentication
 type Service struct {
-	clients     map[string]*userClient // key: userID
+	clients     map[string]*userClient // key: userID and token fingerprint
 	clientsMu   sync.RWMutex
 	modelsCache map[string][]Model // key: userID
 	modelsMu    sync.RWMutex
@@ -90,22 +93,32 @@ func (s *Service) cleanupIdleClients() {
 	defer s.clientsMu.Unlock()
 
 	threshold := time.Now().Add(-30 * time.Minute)
-	for userID, uc := range s.clients {
+	for key, uc := range s.clients {
 		if uc.lastUsed.Before(threshold) {
-			log.Printf("[COPILOT] Cleaning up idle client for user: %s", userID)
+			log.Printf("[COPILOT] Cleaning up idle client for user: %s", uc.userID)
 			uc.client.Stop()
-			delete(s.clients, userID)
+			delete(s.clients, key)
 		}
 	}
 }
 
+// clientKey identifies a client by user and token, so a refreshed token or
+// another sign-in of the same user gets its own client instead of reusing
+// one bound to a token that may have been revoked
+func clientKey(userID, accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return userID + ":" + hex.EncodeToString(sum[:6])
+}
+
 // getOrCreateClient gets or creates a Copilot client for a user
 func (s *Service) getOrCreateClient(userID, accessToken string) (*copilot.Client, error) {
 	s.clientsMu.Lock()
 	defer s.clientsMu.Unlock()
 
+	key := clientKey(userID, accessToken)
+
 	// Check if client exists and is still valid
-	if uc, exists := s.clients[userID]; exists {
+	if uc, exists := s.clients[key]; exists {
 		uc.lastUsed = time.Now()
 		return uc.client, nil
 	}
@@ -140,7 +153,8 @@ func (s *Service) getOrCreateClient(userID, accessToken string) (*copilot.Client
 		return nil, fmt.Errorf("timeout starting Copilot client")
 	}
 
-	s.clients[userID] = &userClient{
+	s.clients[key] = &userClient{
+		userID:    userID,
 		client:    client,
 		createdAt: time.Now(),
 		lastUsed:  time.Now(),
@@ -276,10 +290,29 @@ func (s *Service) SendPrompt(ctx context.Context, userID, accessToken, modelID,
 		}
 	}()
 
-	// Send the message and wait for response
-	resp, err := session.SendAndWait(copilot.MessageOptions{
-		Prompt: prompt,
-	}, time.Duration(120)*time.Second) // 2 minute timeout
+	// Send the message and wait for response, aborting when ctx is cancelled
+	type result struct {
+		event *copilot.SessionEvent
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		event, err := session.SendAndWait(copilot.MessageOptions{
+			Prompt: prompt,
+		}, time.Duration(120)*time.Second) // 2 minute timeout
+		done <- result{event, err}
+	}()
+
+	var resp *copilot.SessionEvent
+	select {
+	case r := <-done:
+		resp, err = r.event, r.err
+	case <-ctx.Done():
+		if err := session.Abort(); err != nil {
+			log.Printf("[COPILOT] WARN: Failed to abort session on context cancel: %v", err)
+		}
+		return nil, ctx.Err()
+	}
 
 	if err != nil {
 		log.Printf("[COPILOT] ERROR: Failed to send prompt: %v", err)
@@ -548,8 +581,8 @@ func (s *Service) Shutdown() {
 
 	// Stop all clients
 	s.clientsMu.Lock()
-	for userID, uc := range s.clients {
-		log.Printf("[COPILOT] Stopping client for user: %s", userID)
+	for _, uc := range s.clients {
+		log.Printf("[COPILOT] Stopping client for user: %s", uc.userID)
 		uc.client.Stop()
 	}
 	s.clients = make(map[string]*userClient)
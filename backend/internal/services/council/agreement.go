@@ -0,0 +1,42 @@
+package council
+
+import "github.com/sainaif/council/internal/services/agreement"
+
+// SessionAgreement describes how much the voters of a session agreed
+type SessionAgreement struct {
+	KendallW float64          `json:"kendall_w"`
+	Pairs    []agreement.Pair `json:"pairs"`
+}
+
+// voterKey identifies a voter in agreement data; users are prefixed so they
+// cannot collide with model IDs
+func voterKey(voterType, voterID string) string {
+	if voterType == "user" {
+		return "user:" + voterID
+	}
+	return voterID
+}
+
+// recordAgreement recomputes inter-rater agreement from all votes of a session
+func (o *Orchestrator) recordAgreement(sessionID string) error {
+	votes, err := o.sessions.Votes(sessionID)
+	if err != nil {
+		return err
+	}
+
+	ballots := make(map[string][]string)
+	for _, v := range votes {
+		ballots[voterKey(v.VoterType, v.VoterID)] = v.RankedResponses
+	}
+
+	rankings := make([][]string, 0, len(ballots))
+	for _, r := range ballots {
+		rankings = append(rankings, r)
+	}
+	w, ok := agreement.KendallW(rankings)
+	if !ok {
+		return nil
+	}
+
+	return o.sessions.SetAgreement(sessionID, w, agreement.Pairwise(ballots))
+}
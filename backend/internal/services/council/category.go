@@ -0,0 +1,155 @@
+package council
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/sainaif/council/internal/services/classifier"
+	"github.com/sainaif/council/internal/websocket"
+)
+
+// classifySession assigns a category to a session started without one
+func (o *Orchestrator) classifySession(ctx context.Context, session *Session) {
+	if !o.classifier.Enabled() {
+		return
+	}
+
+	result, err := o.classifier.Classify(ctx, session.UserID, session.AccessToken, session.Question)
+	if err != nil {
+		log.Printf("[ORCHESTRATOR] Failed to classify session %s: %v", session.ID, err)
+		return
+	}
+	if result == nil {
+		log.Printf("[ORCHESTRATOR] Session %s left uncategorized (low classifier confidence)", session.ID)
+		return
+	}
+
+	o.categoryMu.Lock()
+	// Only fill in the category if the user has not picked one meanwhile
+	filed, err := o.sessions.Classify(session.ID, result.CategoryID, result.Source, result.Confidence)
+	o.categoryMu.Unlock()
+	if err != nil {
+		log.Printf("[ORCHESTRATOR] Failed to store category for session %s: %v", session.ID, err)
+		return
+	}
+	if !filed {
+		return
+	}
+
+	log.Printf("[ORCHESTRATOR] Session %s classified as %s (%s, confidence %.2f)",
+		session.ID, result.Category, result.Source, result.Confidence)
+	session.CategoryID = &result.CategoryID
+	session.CategorySource = result.Source
+	session.CategoryConfidence = &result.Confidence
+	o.hub.Broadcast(session.ID, websocket.EventCategoryAssigned, result)
+}
+
+// rateSession updates global and personal ratings from the model votes,
+// under the category the session has at the time of rating
+func (o *Orchestrator) rateSession(session *Session, responses []Response, votes []Vote) {
+	o.categoryMu.Lock()
+	defer o.categoryMu.Unlock()
+
+	categoryID, err := o.sessions.Category(session.ID)
+	if err != nil {
+		log.Printf("[ORCHESTRATOR] Failed to read category - session: %s, error: %v", session.ID, err)
+		return
+	}
+
+	rankings, weights := ratingInputs(responses, votes)
+	if _, err := o.elo.UpdateRatings(session.ID, categoryID, rankings, weights); err != nil {
+		log.Printf("[ORCHESTRATOR] Failed to update ratings - session: %s, error: %v", session.ID, err)
+	}
+	if _, err := o.elo.UpdateUserRatings(session.UserID, session.ID, categoryID, rankings, weights); err != nil {
+		log.Printf("[ORCHESTRATOR] Failed to update personal ratings - session: %s, error: %v", session.ID, err)
+	}
+}
+
+// OverrideCategory sets the category of a session chosen by its user. If
+// the session was already rated, its rating changes are moved from the old
+// category to the new one. It reports whether ratings were re-attributed.
+func (o *Orchestrator) OverrideCategory(ctx context.Context, sessionID string, categoryID *int64) (bool, error) {
+	o.categoryMu.Lock()
+	defer o.categoryMu.Unlock()
+
+	session, err := o.GetSession(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	if err := o.sessions.SetCategory(sessionID, categoryID, classifier.SourceOverride); err != nil {
+		return false, fmt.Errorf("failed to update category: %w", err)
+	}
+
+	if sameCategory(session.CategoryID, categoryID) {
+		return false, nil
+	}
+
+	reverted, err := o.elo.RevertSession(sessionID, session.UserID, session.CategoryID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revert ratings: %w", err)
+	}
+	if !reverted {
+		return false, nil
+	}
+
+	// Ratings use the model votes on the final round, as when the session ran
+	finalRound := 0
+	for _, r := range session.Responses {
+		if r.Round > finalRound {
+			finalRound = r.Round
+		}
+	}
+	var modelVotes []Vote
+	for _, v := range session.Votes {
+		if v.VoterType == "model" {
+			modelVotes = append(modelVotes, v)
+		}
+	}
+
+	rankings, weights := ratingInputs(filterByRound(session.Responses, finalRound), modelVotes)
+	if _, err := o.elo.UpdateRatings(sessionID, categoryID, rankings, weights); err != nil {
+		return true, fmt.Errorf("failed to re-rate session: %w", err)
+	}
+	if _, err := o.elo.UpdateUserRatings(session.UserID, sessionID, categoryID, rankings, weights); err != nil {
+		return true, fmt.Errorf("failed to re-rate session: %w", err)
+	}
+
+	target := "uncategorized"
+	if categoryID != nil {
+		target = fmt.Sprintf("%d", *categoryID)
+	}
+	log.Printf("[ORCHESTRATOR] Re-attributed ratings for session %s to category %s", sessionID, target)
+	return true, nil
+}
+
+// ratingInputs converts votes on anonymous labels into the per-voter model
+// rankings and weights the rating calculator expects
+func ratingInputs(responses []Response, votes []Vote) (map[string][]string, map[string]float64) {
+	labelToModel := make(map[string]string)
+	for _, r := range responses {
+		labelToModel[r.AnonymousLabel] = r.ModelID
+	}
+
+	rankings := make(map[string][]string)
+	weights := make(map[string]float64)
+	for _, vote := range votes {
+		ranking := make([]string, 0, len(vote.RankedResponses))
+		for _, label := range vote.RankedResponses {
+			if modelID, ok := labelToModel[label]; ok {
+				ranking = append(ranking, modelID)
+			}
+		}
+		rankings[vote.VoterID] = ranking
+		weights[vote.VoterID] = vote.Weight
+	}
+	return rankings, weights
+}
+
+func sameCategory(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
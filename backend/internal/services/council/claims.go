@@ -0,0 +1,97 @@
+package council
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// claimTTL is how long a session stays claimed without renewal. The
+	// instance running it renews the claim every claimRenewInterval.
+	claimTTL           = 2 * time.Minute
+	claimRenewInterval = 30 * time.Second
+
+	// reapInterval is how often orphaned sessions are looked for
+	reapInterval = time.Minute
+	reapLease    = "session-reaper"
+)
+
+// Run fails sessions whose instance stopped renewing its claim, until
+// Shutdown. Only the instance holding the reaper lease looks for them.
+func (o *Orchestrator) Run() {
+	defer close(o.done)
+
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if held, err := o.leases.Acquire(reapLease, reapInterval+claimTTL); err != nil {
+				log.Printf("[ORCHESTRATOR] Failed to acquire reaper lease: %v", err)
+			} else if held {
+				o.reapOrphanedSessions()
+			}
+		case <-o.shutdown:
+			if err := o.leases.Release(reapLease); err != nil {
+				log.Printf("[ORCHESTRATOR] Failed to release reaper lease: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// Shutdown stops looking for orphaned sessions
+func (o *Orchestrator) Shutdown() {
+	close(o.shutdown)
+	<-o.done
+}
+
+// claimSession marks a session as run by this instance. It reports false
+// when another instance holds a live claim on it.
+func (o *Orchestrator) claimSession(sessionID string) (bool, error) {
+	now := time.Now()
+	return o.sessions.Claim(sessionID, o.leases.Holder(), now, now.Add(claimTTL))
+}
+
+// holdClaim renews the claim on a session until stop is closed, then
+// releases it
+func (o *Orchestrator) holdClaim(sessionID string, stop <-chan struct{}) {
+	ticker := time.NewTicker(claimRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := o.claimSession(sessionID); err != nil {
+				log.Printf("[ORCHESTRATOR] Failed to renew claim - session: %s, error: %v", sessionID, err)
+			}
+		case <-stop:
+			if err := o.sessions.ReleaseClaim(sessionID, o.leases.Holder()); err != nil {
+				log.Printf("[ORCHESTRATOR] Failed to release claim - session: %s, error: %v", sessionID, err)
+			}
+			return
+		}
+	}
+}
+
+// reapOrphanedSessions fails unfinished sessions whose claim expired
+func (o *Orchestrator) reapOrphanedSessions() {
+	orphaned, err := o.sessions.Orphaned(time.Now(),
+		string(StatusPending), string(StatusResponding), string(StatusVoting), string(StatusSynthesizing))
+	if err != nil {
+		log.Printf("[ORCHESTRATOR] Failed to look for orphaned sessions: %v", err)
+		return
+	}
+
+	for _, id := range orphaned {
+		// Claim it first so two reapers never fail the same session
+		if claimed, err := o.claimSession(id); err != nil || !claimed {
+			continue
+		}
+		o.failSession(id, "The server running this council stopped")
+		if err := o.sessions.ReleaseClaim(id, o.leases.Holder()); err != nil {
+			log.Printf("[ORCHESTRATOR] Failed to release claim - session: %s, error: %v", id, err)
+		}
+	}
+}
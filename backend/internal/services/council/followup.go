@@ -0,0 +1,63 @@
+package council
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxFollowUpContext caps how much of the earlier conclusion is carried
+// into a follow-up question
+const maxFollowUpContext = 2000
+
+// FollowUpRequest builds the request for a council that follows up on an
+// earlier one: the same models, mode, category and settings, with the
+// earlier question and conclusion given as context for the new question.
+func (o *Orchestrator) FollowUpRequest(ctx context.Context, parentID, question string) (StartRequest, error) {
+	parent, err := o.GetSession(ctx, parentID)
+	if err != nil {
+		return StartRequest{}, err
+	}
+
+	var models []string
+	seen := make(map[string]bool)
+	for _, r := range parent.Responses {
+		if !seen[r.ModelID] {
+			seen[r.ModelID] = true
+			models = append(models, r.ModelID)
+		}
+	}
+	if len(models) < 2 {
+		return StartRequest{}, fmt.Errorf("the earlier council has no responses to follow up on")
+	}
+
+	conclusion := parent.Synthesis
+	if len(conclusion) > maxFollowUpContext {
+		// Cut on a rune boundary so the prompt stays valid UTF-8
+		cut := maxFollowUpContext
+		for cut > 0 && !utf8.RuneStart(conclusion[cut]) {
+			cut--
+		}
+		conclusion = conclusion[:cut] + "..."
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "This follows up on an earlier council.\n\nEarlier question: %s\n\n", parent.Question)
+	if conclusion != "" {
+		fmt.Fprintf(&b, "Its conclusion: %s\n\n", conclusion)
+	}
+	fmt.Fprintf(&b, "Follow-up question: %s", question)
+
+	return StartRequest{
+		Question:        b.String(),
+		Models:          models,
+		Mode:            parent.Mode,
+		CategoryID:      parent.CategoryID,
+		ChairpersonID:   parent.ChairpersonID,
+		DebateRounds:    parent.Config.DebateRounds,
+		EnableDevil:     parent.Config.EnableDevil,
+		EnableMystery:   parent.Config.EnableMystery,
+		ResponseTimeout: parent.Config.ResponseTimeout,
+	}, nil
+}
@@ -2,8 +2,8 @@ package council
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -13,8 +13,11 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/sainaif/council/internal/database"
+	"github.com/sainaif/council/internal/services/classifier"
 	"github.com/sainaif/council/internal/services/copilot"
 	"github.com/sainaif/council/internal/services/elo"
+	"github.com/sainaif/council/internal/services/judge"
+	"github.com/sainaif/council/internal/store"
 	"github.com/sainaif/council/internal/websocket"
 )
 
@@ -51,23 +54,26 @@ type StartRequest struct {
 }
 
 type Session struct {
-	ID              string        `json:"id"`
-	UserID          string        `json:"user_id"`
-	AccessToken     string        `json:"-"` // Not serialized, used for Copilot SDK
-	Question        string        `json:"question"`
-	Mode            Mode          `json:"mode"`
-	Status          SessionStatus `json:"status"`
-	CategoryID      *int64        `json:"category_id,omitempty"`
-	ChairpersonID   *string       `json:"chairperson_id,omitempty"`
-	DevilAdvocateID *string       `json:"devil_advocate_id,omitempty"`
-	MysteryJudgeID  *string       `json:"mystery_judge_id,omitempty"`
-	Synthesis       string        `json:"synthesis,omitempty"`
-	MinorityReport  string        `json:"minority_report,omitempty"`
-	Responses       []Response    `json:"responses,omitempty"`
-	Votes           []Vote        `json:"votes,omitempty"`
-	Config          SessionConfig `json:"config"`
-	CreatedAt       time.Time     `json:"created_at"`
-	CompletedAt     *time.Time    `json:"completed_at,omitempty"`
+	ID                 string            `json:"id"`
+	UserID             string            `json:"user_id"`
+	AccessToken        string            `json:"-"` // Not serialized, used for Copilot SDK
+	Question           string            `json:"question"`
+	Mode               Mode              `json:"mode"`
+	Status             SessionStatus     `json:"status"`
+	CategoryID         *int64            `json:"category_id,omitempty"`
+	CategorySource     string            `json:"category_source,omitempty"`     // user, keyword, model or override
+	CategoryConfidence *float64          `json:"category_confidence,omitempty"` // Set for classified categories
+	ChairpersonID      *string           `json:"chairperson_id,omitempty"`
+	DevilAdvocateID    *string           `json:"devil_advocate_id,omitempty"`
+	MysteryJudgeID     *string           `json:"mystery_judge_id,omitempty"`
+	Synthesis          string            `json:"synthesis,omitempty"`
+	MinorityReport     string            `json:"minority_report,omitempty"`
+	Agreement          *SessionAgreement `json:"agreement,omitempty"`
+	Responses          []Response        `json:"responses,omitempty"`
+	Votes              []Vote            `json:"votes,omitempty"`
+	Config             SessionConfig     `json:"config"`
+	CreatedAt          time.Time         `json:"created_at"`
+	CompletedAt        *time.Time        `json:"completed_at,omitempty"`
 }
 
 type SessionConfig struct {
@@ -100,19 +106,47 @@ type Vote struct {
 }
 
 type Orchestrator struct {
-	db      *database.DB
-	copilot *copilot.Service
-	elo     *elo.Calculator
-	hub     *websocket.Hub
+	sessions   store.SessionStore
+	models     store.ModelStore
+	categories store.CategoryStore
+	users      store.UserStore
+	copilot    *copilot.Service
+	elo        *elo.Calculator
+	judges     *judge.Tracker
+	classifier *classifier.Service
+	hub        *websocket.Hub
+	leases     *database.Leases // Names this instance in session claims
+
+	// Serializes rating updates with category overrides so a session is
+	// always rated under the category it ends up with
+	categoryMu sync.Mutex
+
+	// Stops the councils running here, by session ID
+	runningMu sync.Mutex
+	running   map[string]context.CancelFunc
+
+	shutdown chan struct{}
+	done     chan struct{}
 }
 
-func NewOrchestrator(db *database.DB, copilot *copilot.Service, elo *elo.Calculator, hub *websocket.Hub) *Orchestrator {
-	return &Orchestrator{
-		db:      db,
-		copilot: copilot,
-		elo:     elo,
-		hub:     hub,
-	}
+func NewOrchestrator(leases *database.Leases, sessions store.SessionStore, models store.ModelStore, categories store.CategoryStore, users store.UserStore, copilot *copilot.Service, elo *elo.Calculator, judges *judge.Tracker, classifier *classifier.Service, hub *websocket.Hub) *Orchestrator {
+	o := &Orchestrator{
+		sessions:   sessions,
+		models:     models,
+		categories: categories,
+		users:      users,
+		copilot:    copilot,
+		elo:        elo,
+		judges:     judges,
+		classifier: classifier,
+		hub:        hub,
+		leases:     leases,
+		running:    make(map[string]context.CancelFunc),
+		shutdown:   make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	hub.HandleRequests(o.handleRequest)
+	return o
 }
 
 func (o *Orchestrator) StartSession(ctx context.Context, userID string, accessToken string, req StartRequest) (*Session, error) {
@@ -120,6 +154,18 @@ func (o *Orchestrator) StartSession(ctx context.Context, userID string, accessTo
 	if err := o.validateRequest(req); err != nil {
 		return nil, err
 	}
+	if req.CategoryID != nil {
+		archived, err := o.categories.Archived(*req.CategoryID)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, fmt.Errorf("unknown category: %d", *req.CategoryID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up category: %w", err)
+		}
+		if archived {
+			return nil, fmt.Errorf("category is archived")
+		}
+	}
 
 	// Create session
 	sessionID := uuid.New().String()
@@ -160,7 +206,10 @@ func (o *Orchestrator) StartSession(ctx context.Context, userID string, accessTo
 		chairpersonID = &participatingModels[0]
 	}
 
-	configJSON, _ := json.Marshal(config)
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode session config: %w", err)
+	}
 
 	// Register models BEFORE inserting session (foreign key constraint)
 	for _, modelID := range req.Models {
@@ -168,17 +217,30 @@ func (o *Orchestrator) StartSession(ctx context.Context, userID string, accessTo
 		if err != nil {
 			continue
 		}
-		_, _ = o.db.Exec(`
-			INSERT OR IGNORE INTO models (id, display_name, provider)
-			VALUES (?, ?, ?)
-		`, model.ID, model.DisplayName, model.Provider)
+		if err := o.models.Register(model.ID, model.DisplayName, model.Provider); err != nil {
+			return nil, fmt.Errorf("failed to register model %s: %w", model.ID, err)
+		}
+	}
+
+	var categorySource string
+	if req.CategoryID != nil {
+		categorySource = classifier.SourceUser
 	}
 
 	// Insert session
-	_, err := o.db.Exec(`
-		INSERT INTO sessions (id, user_id, question, category_id, mode, status, config, chairperson_id, devil_advocate_id, mystery_judge_id)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, sessionID, userID, req.Question, req.CategoryID, req.Mode, StatusPending, string(configJSON), chairpersonID, devilID, mysteryID)
+	err = o.sessions.Create(&store.Session{
+		ID:              sessionID,
+		UserID:          userID,
+		Question:        req.Question,
+		Mode:            string(req.Mode),
+		Status:          string(StatusPending),
+		CategoryID:      req.CategoryID,
+		CategorySource:  categorySource,
+		ChairpersonID:   chairpersonID,
+		DevilAdvocateID: devilID,
+		MysteryJudgeID:  mysteryID,
+		Config:          string(configJSON),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
@@ -194,12 +256,20 @@ func (o *Orchestrator) StartSession(ctx context.Context, userID string, accessTo
 		ChairpersonID:   chairpersonID,
 		DevilAdvocateID: devilID,
 		MysteryJudgeID:  mysteryID,
+		CategorySource:  categorySource,
 		Config:          config,
 		CreatedAt:       time.Now(),
 	}
 
-	// Start council execution in background
-	go o.executeCouncil(context.Background(), session, participatingModels)
+	// Start council execution in background, until it ends or is cancelled
+	runCtx, cancel := context.WithCancel(context.Background())
+	o.runningMu.Lock()
+	o.running[sessionID] = cancel
+	o.runningMu.Unlock()
+	go func() {
+		defer o.stopCouncil(sessionID)
+		o.executeCouncil(runCtx, session, participatingModels)
+	}()
 
 	return session, nil
 }
@@ -223,8 +293,28 @@ func (o *Orchestrator) validateRequest(req StartRequest) error {
 func (o *Orchestrator) executeCouncil(ctx context.Context, session *Session, models []string) {
 	log.Printf("[ORCHESTRATOR] Starting council execution - session: %s, mode: %s, models: %v", session.ID, session.Mode, models)
 
+	// Claim the session so no other instance runs or reaps it meanwhile
+	claimed, err := o.claimSession(session.ID)
+	if err != nil {
+		o.failSession(session.ID, "Failed to claim session: "+err.Error())
+		return
+	}
+	if !claimed {
+		log.Printf("[ORCHESTRATOR] Session %s is claimed by another instance", session.ID)
+		return
+	}
+	release := make(chan struct{})
+	defer close(release)
+	go o.holdClaim(session.ID, release)
+
+	if session.CategoryID == nil {
+		o.classifySession(ctx, session)
+	}
+
 	// Update status to responding
-	o.updateSessionStatus(session.ID, StatusResponding)
+	if !o.updateSessionStatus(session.ID, StatusResponding) {
+		return
+	}
 	o.hub.Broadcast(session.ID, websocket.EventCouncilStarted, map[string]interface{}{
 		"session_id": session.ID,
 		"mode":       session.Mode,
@@ -251,7 +341,9 @@ func (o *Orchestrator) executeStandardMode(ctx context.Context, session *Session
 	}
 
 	// Stage 2: Voting
-	o.updateSessionStatus(session.ID, StatusVoting)
+	if !o.updateSessionStatus(session.ID, StatusVoting) {
+		return
+	}
 	o.hub.Broadcast(session.ID, websocket.EventVotingStarted, nil)
 
 	votes, err := o.collectVotes(ctx, session, responses, models)
@@ -259,9 +351,12 @@ func (o *Orchestrator) executeStandardMode(ctx context.Context, session *Session
 		o.failSession(session.ID, err.Error())
 		return
 	}
+	o.updateAgreement(session.ID)
 
 	// Stage 3: Synthesis
-	o.updateSessionStatus(session.ID, StatusSynthesizing)
+	if !o.updateSessionStatus(session.ID, StatusSynthesizing) {
+		return
+	}
 	o.hub.Broadcast(session.ID, websocket.EventSynthesisStarted, nil)
 
 	if err := o.synthesize(ctx, session, responses, votes); err != nil {
@@ -269,21 +364,19 @@ func (o *Orchestrator) executeStandardMode(ctx context.Context, session *Session
 		return
 	}
 
-	// Update ELO ratings
-	rankings := make(map[string][]string)
-	for _, vote := range votes {
-		rankings[vote.VoterID] = vote.RankedResponses
-	}
-	_, _ = o.elo.UpdateRatings(session.ID, session.CategoryID, rankings)
-
-	// Complete session
-	o.completeSession(session.ID)
+	// Complete session, then update ELO ratings and head-to-head records
+	o.completeSession(session.ID, func() {
+		o.rateSession(session, responses, votes)
+	})
 }
 
 func (o *Orchestrator) executeDebateMode(ctx context.Context, session *Session, models []string) {
 	var allResponses []Response
 
 	for round := 1; round <= session.Config.DebateRounds; round++ {
+		if ctx.Err() != nil {
+			return
+		}
 		responses, err := o.collectResponses(ctx, session, models, round)
 		if err != nil {
 			o.failSession(session.ID, err.Error())
@@ -293,7 +386,9 @@ func (o *Orchestrator) executeDebateMode(ctx context.Context, session *Session,
 	}
 
 	// Voting on final round responses only
-	o.updateSessionStatus(session.ID, StatusVoting)
+	if !o.updateSessionStatus(session.ID, StatusVoting) {
+		return
+	}
 	finalResponses := filterByRound(allResponses, session.Config.DebateRounds)
 
 	votes, err := o.collectVotes(ctx, session, finalResponses, models)
@@ -301,15 +396,18 @@ func (o *Orchestrator) executeDebateMode(ctx context.Context, session *Session,
 		o.failSession(session.ID, err.Error())
 		return
 	}
+	o.updateAgreement(session.ID)
 
 	// Synthesis
-	o.updateSessionStatus(session.ID, StatusSynthesizing)
+	if !o.updateSessionStatus(session.ID, StatusSynthesizing) {
+		return
+	}
 	if err := o.synthesize(ctx, session, finalResponses, votes); err != nil {
 		o.failSession(session.ID, err.Error())
 		return
 	}
 
-	o.completeSession(session.ID)
+	o.completeSession(session.ID, nil)
 }
 
 func (o *Orchestrator) executeTournamentMode(ctx context.Context, session *Session, models []string) {
@@ -319,6 +417,9 @@ func (o *Orchestrator) executeTournamentMode(ctx context.Context, session *Sessi
 	for len(remaining) > 1 {
 		var winners []string
 		for i := 0; i < len(remaining); i += 2 {
+			if ctx.Err() != nil {
+				return
+			}
 			if i+1 >= len(remaining) {
 				// Odd one out advances automatically
 				winners = append(winners, remaining[i])
@@ -350,7 +451,7 @@ func (o *Orchestrator) executeTournamentMode(ctx context.Context, session *Sessi
 		})
 	}
 
-	o.completeSession(session.ID)
+	o.completeSession(session.ID, nil)
 }
 
 func (o *Orchestrator) collectResponses(ctx context.Context, session *Session, models []string, round int) ([]Response, error) {
@@ -359,7 +460,7 @@ func (o *Orchestrator) collectResponses(ctx context.Context, session *Session, m
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var responses []Response
-	var errors []error
+	var errs []error
 
 	labels := generateLabels(len(models))
 
@@ -386,7 +487,7 @@ func (o *Orchestrator) collectResponses(ctx context.Context, session *Session, m
 			chunks, err := o.copilot.StreamPrompt(ctx, session.UserID, session.AccessToken, mID, prompt)
 			if err != nil {
 				mu.Lock()
-				errors = append(errors, err)
+				errs = append(errs, err)
 				mu.Unlock()
 				return
 			}
@@ -396,7 +497,7 @@ func (o *Orchestrator) collectResponses(ctx context.Context, session *Session, m
 			for chunk := range chunks {
 				if chunk.Error != nil {
 					mu.Lock()
-					errors = append(errors, chunk.Error)
+					errs = append(errs, chunk.Error)
 					mu.Unlock()
 					return
 				}
@@ -415,21 +516,7 @@ func (o *Orchestrator) collectResponses(ctx context.Context, session *Session, m
 			responseTime := time.Since(start).Milliseconds()
 
 			// Save response
-			result, err := o.db.Exec(`
-				INSERT INTO responses (session_id, model_id, round, content, anonymous_label, response_time_ms, token_count)
-				VALUES (?, ?, ?, ?, ?, ?, ?)
-			`, session.ID, mID, round, content, label, responseTime, tokenCount)
-			if err != nil {
-				mu.Lock()
-				errors = append(errors, err)
-				mu.Unlock()
-				return
-			}
-
-			id, _ := result.LastInsertId()
-			mu.Lock()
-			responses = append(responses, Response{
-				ID:             id,
+			response := store.Response{
 				SessionID:      session.ID,
 				ModelID:        mID,
 				Round:          round,
@@ -438,7 +525,16 @@ func (o *Orchestrator) collectResponses(ctx context.Context, session *Session, m
 				ResponseTimeMs: responseTime,
 				TokenCount:     tokenCount,
 				CreatedAt:      time.Now(),
-			})
+			}
+			if err := o.sessions.AddResponse(&response); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				return
+			}
+
+			mu.Lock()
+			responses = append(responses, Response(response))
 			mu.Unlock()
 
 			o.hub.Broadcast(session.ID, websocket.EventModelComplete, map[string]interface{}{
@@ -451,8 +547,8 @@ func (o *Orchestrator) collectResponses(ctx context.Context, session *Session, m
 
 	wg.Wait()
 
-	if len(errors) > 0 {
-		return responses, errors[0]
+	if len(errs) > 0 {
+		return responses, errs[0]
 	}
 
 	return responses, nil
@@ -486,34 +582,28 @@ func (o *Orchestrator) collectVotes(ctx context.Context, session *Session, respo
 				return
 			}
 
-			// Determine weight (mystery judge gets higher weight)
-			weight := 1.0
+			// Determine weight from the judge's track record (mystery judge gets a bonus)
+			weight := o.judges.Weight(mID)
 			if session.MysteryJudgeID != nil && *session.MysteryJudgeID == mID {
-				weight = 1.5
+				weight *= 1.5
 			}
 
-			rankingJSON, _ := json.Marshal(ranking)
-
 			// Save vote
-			result, err := o.db.Exec(`
-				INSERT INTO votes (session_id, voter_type, voter_id, ranked_responses, weight)
-				VALUES (?, 'model', ?, ?, ?)
-			`, session.ID, mID, string(rankingJSON), weight)
-			if err != nil {
-				return
-			}
-
-			id, _ := result.LastInsertId()
-			mu.Lock()
-			votes = append(votes, Vote{
-				ID:              id,
+			vote := store.Vote{
 				SessionID:       session.ID,
 				VoterType:       "model",
 				VoterID:         mID,
 				RankedResponses: ranking,
 				Weight:          weight,
 				CreatedAt:       time.Now(),
-			})
+			}
+			if err := o.sessions.AddVote(&vote); err != nil {
+				log.Printf("[ORCHESTRATOR] Failed to save vote - session: %s, model: %s, error: %v", session.ID, mID, err)
+				return
+			}
+
+			mu.Lock()
+			votes = append(votes, Vote(vote))
 			mu.Unlock()
 
 			o.hub.Broadcast(session.ID, websocket.EventVoteReceived, map[string]interface{}{
@@ -523,6 +613,16 @@ func (o *Orchestrator) collectVotes(ctx context.Context, session *Session, respo
 	}
 
 	wg.Wait()
+
+	// Score each judge against the consensus of the others
+	ballots := make([]judge.Ballot, 0, len(votes))
+	for _, v := range votes {
+		ballots = append(ballots, judge.Ballot{JudgeID: v.VoterID, Ranking: v.RankedResponses, Weight: v.Weight})
+	}
+	if err := o.judges.RecordConsensus(session.ID, ballots); err != nil {
+		log.Printf("[ORCHESTRATOR] Failed to record judge agreement - session: %s, error: %v", session.ID, err)
+	}
+
 	return votes, nil
 }
 
@@ -552,9 +652,7 @@ func (o *Orchestrator) synthesize(ctx context.Context, session *Session, respons
 	minorityReport := detectMinorityReport(votes)
 
 	// Update session
-	_, err = o.db.Exec(`
-		UPDATE sessions SET synthesis = ?, minority_report = ? WHERE id = ?
-	`, synthesis.Content, minorityReport, session.ID)
+	err = o.sessions.SetSynthesis(session.ID, synthesis.Content, minorityReport)
 
 	o.hub.Broadcast(session.ID, websocket.EventSynthesisComplete, map[string]interface{}{
 		"synthesis":       synthesis.Content,
@@ -564,117 +662,203 @@ func (o *Orchestrator) synthesize(ctx context.Context, session *Session, respons
 	return err
 }
 
-func (o *Orchestrator) updateSessionStatus(sessionID string, status SessionStatus) {
-	_, _ = o.db.Exec(`UPDATE sessions SET status = ? WHERE id = ?`, status, sessionID)
+// updateAgreement refreshes the session's inter-rater agreement. Callers skip
+// tournament sessions since each match reuses the same response labels.
+func (o *Orchestrator) updateAgreement(sessionID string) {
+	if err := o.recordAgreement(sessionID); err != nil {
+		log.Printf("[ORCHESTRATOR] Failed to record vote agreement - session: %s, error: %v", sessionID, err)
+	}
+}
+
+// updateSessionStatus moves a session on to its next stage. It reports
+// false when the session was cancelled or failed meanwhile and the council
+// should stop.
+func (o *Orchestrator) updateSessionStatus(sessionID string, status SessionStatus) bool {
+	advanced, err := o.sessions.Advance(sessionID, string(status))
+	if err != nil {
+		log.Printf("[ORCHESTRATOR] Failed to update session status - id: %s, status: %s, error: %v", sessionID, status, err)
+		return true
+	}
+	if !advanced {
+		log.Printf("[ORCHESTRATOR] Session ended before %s - id: %s", status, sessionID)
+	}
+	return advanced
 }
 
 func (o *Orchestrator) failSession(sessionID, reason string) {
+	failed, err := o.sessions.Complete(sessionID, string(StatusFailed))
+	if err != nil {
+		log.Printf("[ORCHESTRATOR] Failed to update session status - id: %s, status: %s, error: %v", sessionID, StatusFailed, err)
+	} else if !failed {
+		// Cancelled meanwhile, which is what stopped it
+		return
+	}
 	log.Printf("[ORCHESTRATOR] Session failed - id: %s, reason: %s", sessionID, reason)
-	_, _ = o.db.Exec(`UPDATE sessions SET status = ? WHERE id = ?`, StatusFailed, sessionID)
 	o.hub.Broadcast(sessionID, websocket.EventCouncilFailed, map[string]string{
 		"reason": reason,
 	})
 }
 
-func (o *Orchestrator) completeSession(sessionID string) {
+// completeSession marks a session completed, runs rate (if any) and
+// announces it. A session cancelled meanwhile is left alone and not rated.
+func (o *Orchestrator) completeSession(sessionID string, rate func()) {
+	completed, err := o.sessions.Complete(sessionID, string(StatusCompleted))
+	if err != nil {
+		log.Printf("[ORCHESTRATOR] Failed to complete session - id: %s, error: %v", sessionID, err)
+		return
+	}
+	if !completed {
+		log.Printf("[ORCHESTRATOR] Session ended before completing, not rating it - id: %s", sessionID)
+		return
+	}
+
 	log.Printf("[ORCHESTRATOR] Session completed - id: %s", sessionID)
-	_, _ = o.db.Exec(`UPDATE sessions SET status = ?, completed_at = CURRENT_TIMESTAMP WHERE id = ?`, StatusCompleted, sessionID)
+	if rate != nil {
+		rate()
+	}
 	o.hub.Broadcast(sessionID, websocket.EventCouncilCompleted, nil)
 }
 
 func (o *Orchestrator) GetSession(ctx context.Context, sessionID string) (*Session, error) {
-	var session Session
-	var configJSON, synthesis, minorityReport sql.NullString
-	var chairpersonID, devilID, mysteryID sql.NullString
-	var categoryID sql.NullInt64
-	var completedAt sql.NullTime
-
-	err := o.db.QueryRow(`
-		SELECT id, user_id, question, category_id, mode, status, config, chairperson_id,
-			   devil_advocate_id, mystery_judge_id, synthesis, minority_report, created_at, completed_at
-		FROM sessions WHERE id = ?
-	`, sessionID).Scan(
-		&session.ID, &session.UserID, &session.Question, &categoryID,
-		&session.Mode, &session.Status, &configJSON, &chairpersonID,
-		&devilID, &mysteryID, &synthesis, &minorityReport,
-		&session.CreatedAt, &completedAt,
-	)
+	stored, err := o.sessions.Get(sessionID)
 	if err != nil {
 		return nil, err
 	}
 
-	if categoryID.Valid {
-		session.CategoryID = &categoryID.Int64
+	session := &Session{
+		ID:                 stored.ID,
+		UserID:             stored.UserID,
+		Question:           stored.Question,
+		Mode:               Mode(stored.Mode),
+		Status:             SessionStatus(stored.Status),
+		CategoryID:         stored.CategoryID,
+		CategorySource:     stored.CategorySource,
+		CategoryConfidence: stored.CategoryConfidence,
+		ChairpersonID:      stored.ChairpersonID,
+		DevilAdvocateID:    stored.DevilAdvocateID,
+		MysteryJudgeID:     stored.MysteryJudgeID,
+		Synthesis:          stored.Synthesis,
+		MinorityReport:     stored.MinorityReport,
+		CreatedAt:          stored.CreatedAt,
+		CompletedAt:        stored.CompletedAt,
+	}
+	if stored.Config != "" {
+		if err := json.Unmarshal([]byte(stored.Config), &session.Config); err != nil {
+			return nil, fmt.Errorf("invalid session config: %w", err)
+		}
 	}
-	if chairpersonID.Valid {
-		session.ChairpersonID = &chairpersonID.String
+	if stored.AgreementW != nil {
+		pairs, err := o.sessions.Agreement(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		session.Agreement = &SessionAgreement{KendallW: *stored.AgreementW, Pairs: pairs}
 	}
-	if devilID.Valid {
-		session.DevilAdvocateID = &devilID.String
+
+	responses, err := o.sessions.Responses(sessionID)
+	if err != nil {
+		return nil, err
 	}
-	if mysteryID.Valid {
-		session.MysteryJudgeID = &mysteryID.String
+	for _, r := range responses {
+		session.Responses = append(session.Responses, Response(r))
 	}
-	if synthesis.Valid {
-		session.Synthesis = synthesis.String
+
+	votes, err := o.sessions.Votes(sessionID)
+	if err != nil {
+		return nil, err
 	}
-	if minorityReport.Valid {
-		session.MinorityReport = minorityReport.String
+	for _, v := range votes {
+		session.Votes = append(session.Votes, Vote(v))
 	}
-	if completedAt.Valid {
-		session.CompletedAt = &completedAt.Time
+
+	return session, nil
+}
+
+func (o *Orchestrator) SubmitUserVote(ctx context.Context, sessionID, userID string, ranking []string) error {
+	vote := store.Vote{
+		SessionID:       sessionID,
+		VoterType:       "user",
+		VoterID:         userID,
+		RankedResponses: ranking,
+		Weight:          0.5,
+	}
+	if err := o.sessions.AddVote(&vote); err != nil {
+		return err
 	}
-	if configJSON.Valid {
-		_ = json.Unmarshal([]byte(configJSON.String), &session.Config)
+
+	// Score the model judges against the user's ranking
+	if err := o.judges.RecordUserVote(sessionID, ranking); err != nil {
+		log.Printf("[ORCHESTRATOR] Failed to record judge agreement with user - session: %s, error: %v", sessionID, err)
 	}
 
-	// Load responses
-	rows, err := o.db.Query(`
-		SELECT id, session_id, model_id, round, content, anonymous_label, response_time_ms, token_count, created_at
-		FROM responses WHERE session_id = ? ORDER BY round, id
-	`, sessionID)
-	if err == nil {
-		defer func() { _ = rows.Close() }()
-		for rows.Next() {
-			var r Response
-			_ = rows.Scan(&r.ID, &r.SessionID, &r.ModelID, &r.Round, &r.Content,
-				&r.AnonymousLabel, &r.ResponseTimeMs, &r.TokenCount, &r.CreatedAt)
-			session.Responses = append(session.Responses, r)
-		}
+	if session, err := o.sessions.Get(sessionID); err == nil && Mode(session.Mode) != ModeTournament {
+		o.updateAgreement(sessionID)
 	}
+	return nil
+}
 
-	// Load votes
-	voteRows, err := o.db.Query(`
-		SELECT id, session_id, voter_type, voter_id, ranked_responses, weight, created_at
-		FROM votes WHERE session_id = ?
-	`, sessionID)
-	if err == nil {
-		defer func() { _ = voteRows.Close() }()
-		for voteRows.Next() {
-			var v Vote
-			var rankedJSON string
-			_ = voteRows.Scan(&v.ID, &v.SessionID, &v.VoterType, &v.VoterID, &rankedJSON, &v.Weight, &v.CreatedAt)
-			_ = json.Unmarshal([]byte(rankedJSON), &v.RankedResponses)
-			session.Votes = append(session.Votes, v)
-		}
+// ErrSessionFinished is returned when cancelling a session that already
+// completed, failed or was cancelled
+var ErrSessionFinished = errors.New("session already finished")
+
+// CancelSession ends a session and stops its council, on whichever instance
+// runs it
+func (o *Orchestrator) CancelSession(ctx context.Context, sessionID string) error {
+	// The instance running the session announces it, so the session's
+	// events stay numbered in one place
+	holder, err := o.sessions.ClaimHolder(sessionID, time.Now())
+	if err != nil {
+		return err
+	}
+	if holder != "" && holder != o.leases.Holder() {
+		return o.hub.Request(ctx, &websocket.Request{
+			Instance:  holder,
+			SessionID: sessionID,
+			Action:    websocket.RequestCancel,
+		})
 	}
+	return o.cancelSession(sessionID)
+}
 
-	return &session, nil
+// cancelSession ends an unfinished session and stops its council if it runs
+// on this instance
+func (o *Orchestrator) cancelSession(sessionID string) error {
+	cancelled, err := o.sessions.Complete(sessionID, string(StatusCancelled))
+	if err != nil {
+		return err
+	}
+	o.stopCouncil(sessionID)
+	if !cancelled {
+		return ErrSessionFinished
+	}
+	log.Printf("[ORCHESTRATOR] Session cancelled - id: %s", sessionID)
+	o.hub.Broadcast(sessionID, websocket.EventCouncilCancelled, nil)
+	return nil
 }
 
-func (o *Orchestrator) SubmitUserVote(ctx context.Context, sessionID, userID string, ranking []string) error {
-	rankingJSON, _ := json.Marshal(ranking)
-	_, err := o.db.Exec(`
-		INSERT INTO votes (session_id, voter_type, voter_id, ranked_responses, weight)
-		VALUES (?, 'user', ?, ?, 0.5)
-	`, sessionID, userID, string(rankingJSON))
-	return err
+// stopCouncil cancels the context of a council running here, stopping its
+// model calls
+func (o *Orchestrator) stopCouncil(sessionID string) {
+	o.runningMu.Lock()
+	cancel, ok := o.running[sessionID]
+	delete(o.running, sessionID)
+	o.runningMu.Unlock()
+	if ok {
+		cancel()
+	}
 }
 
-func (o *Orchestrator) CancelSession(ctx context.Context, sessionID string) error {
-	_, err := o.db.Exec(`UPDATE sessions SET status = ? WHERE id = ?`, StatusCancelled, sessionID)
-	o.hub.Broadcast(sessionID, "council.cancelled", nil)
-	return err
+// handleRequest acts on a request another instance sent for a session
+// running here
+func (o *Orchestrator) handleRequest(req *websocket.Request) {
+	switch req.Action {
+	case websocket.RequestCancel:
+		if err := o.cancelSession(req.SessionID); err != nil && !errors.Is(err, ErrSessionFinished) {
+			log.Printf("[ORCHESTRATOR] Failed to cancel session - id: %s, error: %v", req.SessionID, err)
+		}
+	default:
+		log.Printf("[ORCHESTRATOR] Ignoring unknown request %q for session %s", req.Action, req.SessionID)
+	}
 }
 
 // Helper functions
@@ -697,15 +881,15 @@ func filterByRound(responses []Response, round int) []Response {
 }
 
 func determineWinner(votes []Vote) string {
-	scores := make(map[string]int)
+	scores := make(map[string]float64)
 	for _, v := range votes {
 		for i, label := range v.RankedResponses {
-			scores[label] += len(v.RankedResponses) - i
+			scores[label] += v.Weight * float64(len(v.RankedResponses)-i)
 		}
 	}
 
 	var winner string
-	maxScore := 0
+	maxScore := 0.0
 	for label, score := range scores {
 		if score > maxScore {
 			maxScore = score
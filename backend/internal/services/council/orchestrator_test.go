@@ -0,0 +1,192 @@
+package council
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sainaif/council/internal/config"
+	"github.com/sainaif/council/internal/database"
+	"github.com/sainaif/council/internal/services/classifier"
+	"github.com/sainaif/council/internal/services/copilot"
+	"github.com/sainaif/council/internal/services/elo"
+	"github.com/sainaif/council/internal/services/judge"
+	"github.com/sainaif/council/internal/store"
+	"github.com/sainaif/council/internal/websocket"
+)
+
+func openDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// newTestOrchestrator creates the orchestrator of one instance, named holder
+func newTestOrchestrator(t *testing.T, db *database.DB, holder string, broker websocket.Broker) *Orchestrator {
+	t.Helper()
+	cfg := &config.Config{}
+	sessions := store.NewSessionStore(db)
+	hub := websocket.NewHub(websocket.NewEventLog(db, 1000, time.Hour), sessions, websocket.HubConfig{
+		PingInterval: time.Minute,
+		PongTimeout:  time.Minute,
+		Broker:       broker,
+	})
+	copilotService := copilot.NewService()
+	o := NewOrchestrator(database.NewLeases(db, holder), sessions, store.NewModelStore(db, 1500), store.NewCategoryStore(db),
+		store.NewUserStore(db), copilotService, elo.NewCalculator(db, cfg.Elo), judge.NewTracker(db),
+		classifier.NewService(db, copilotService, cfg.Classifier), hub)
+	go hub.Run()
+	t.Cleanup(hub.Shutdown)
+	return o
+}
+
+// runFake registers a council as running here, like StartSession does, and
+// returns its context
+func runFake(t *testing.T, o *Orchestrator, sessionID string) context.Context {
+	t.Helper()
+	if err := o.sessions.Create(&store.Session{ID: sessionID, UserID: "42", Question: "Why?", Mode: "standard", Status: "pending", Config: "{}"}); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if claimed, err := o.claimSession(sessionID); err != nil || !claimed {
+		t.Fatalf("claim session: %v, %v", claimed, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	o.runningMu.Lock()
+	o.running[sessionID] = cancel
+	o.runningMu.Unlock()
+	return ctx
+}
+
+func assertStopped(t *testing.T, ctx context.Context) {
+	t.Helper()
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("council context was not cancelled")
+	}
+}
+
+func assertStatus(t *testing.T, o *Orchestrator, sessionID string, want SessionStatus) {
+	t.Helper()
+	session, err := o.sessions.Get(sessionID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if SessionStatus(session.Status) != want {
+		t.Errorf("status = %s, want %s", session.Status, want)
+	}
+}
+
+func TestCancelSession(t *testing.T) {
+	o := newTestOrchestrator(t, openDB(t), "a", nil)
+	ctx := runFake(t, o, "s1")
+	if !o.updateSessionStatus("s1", StatusResponding) {
+		t.Fatalf("running session did not advance")
+	}
+
+	if err := o.CancelSession(context.Background(), "s1"); err != nil {
+		t.Fatalf("CancelSession: %v", err)
+	}
+	assertStopped(t, ctx)
+	assertStatus(t, o, "s1", StatusCancelled)
+
+	// The council winds down without overwriting the cancellation
+	if o.updateSessionStatus("s1", StatusVoting) {
+		t.Errorf("cancelled session advanced to voting")
+	}
+	rated := false
+	o.completeSession("s1", func() { rated = true })
+	o.failSession("s1", "context canceled")
+	if rated {
+		t.Errorf("cancelled session was rated")
+	}
+	assertStatus(t, o, "s1", StatusCancelled)
+
+	if err := o.CancelSession(context.Background(), "s1"); !errors.Is(err, ErrSessionFinished) {
+		t.Errorf("second CancelSession = %v, want ErrSessionFinished", err)
+	}
+}
+
+func TestCompleteSessionRatesOnce(t *testing.T) {
+	o := newTestOrchestrator(t, openDB(t), "a", nil)
+	runFake(t, o, "s1")
+
+	rated := 0
+	o.completeSession("s1", func() { rated++ })
+	o.completeSession("s1", func() { rated++ })
+	if rated != 1 {
+		t.Errorf("rated %d times, want 1", rated)
+	}
+	assertStatus(t, o, "s1", StatusCompleted)
+
+	if err := o.CancelSession(context.Background(), "s1"); !errors.Is(err, ErrSessionFinished) {
+		t.Errorf("CancelSession of a completed session = %v, want ErrSessionFinished", err)
+	}
+	assertStatus(t, o, "s1", StatusCompleted)
+}
+
+// linkedBroker hands requests to the instance it is linked with
+type linkedBroker struct {
+	websocket.MemoryBroker
+	mu     sync.Mutex
+	peer   *linkedBroker
+	handle func(*websocket.Request)
+}
+
+func (b *linkedBroker) Request(ctx context.Context, req *websocket.Request) error {
+	b.peer.mu.Lock()
+	handle := b.peer.handle
+	b.peer.mu.Unlock()
+	if handle == nil {
+		return websocket.ErrNoInstances
+	}
+	go handle(req)
+	return nil
+}
+
+func (b *linkedBroker) Subscribe(ctx context.Context, deliver func(*websocket.Message), handle func(*websocket.Request)) error {
+	b.mu.Lock()
+	b.handle = handle
+	b.mu.Unlock()
+	<-ctx.Done()
+	return nil
+}
+
+func TestCancelSessionOnAnotherInstance(t *testing.T) {
+	db := openDB(t)
+	brokerA, brokerB := &linkedBroker{}, &linkedBroker{}
+	brokerA.peer, brokerB.peer = brokerB, brokerA
+	a := newTestOrchestrator(t, db, "a", brokerA)
+	b := newTestOrchestrator(t, db, "b", brokerB)
+	ctx := runFake(t, a, "s1")
+
+	// Wait for instance a to listen for requests
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		brokerA.mu.Lock()
+		ready := brokerA.handle != nil
+		brokerA.mu.Unlock()
+		if ready {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("instance a never subscribed to its broker")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := b.CancelSession(context.Background(), "s1"); err != nil {
+		t.Fatalf("CancelSession: %v", err)
+	}
+	assertStopped(t, ctx)
+	assertStatus(t, a, "s1", StatusCancelled)
+}
@@ -0,0 +1,64 @@
+package council
+
+import (
+	"errors"
+
+	"github.com/sainaif/council/internal/store"
+)
+
+// Errors returned by ShareSession
+var (
+	ErrUserNotFound   = errors.New("user not found")                       // Never signed in
+	ErrShareWithOwner = errors.New("session already belongs to this user") // Sharing with the owner
+)
+
+// CanView reports whether a user owns the session or was granted access to
+// it. It returns store.ErrNotFound when the session does not exist.
+func (o *Orchestrator) CanView(sessionID, userID string) (bool, error) {
+	ownerID, err := o.sessions.Owner(sessionID)
+	if err != nil {
+		return false, err
+	}
+	if userID == "" {
+		return false, nil
+	}
+	if ownerID == userID {
+		return true, nil
+	}
+	return o.sessions.SharedWith(sessionID, userID)
+}
+
+// ShareSession grants a user, by GitHub username, access to a session
+func (o *Orchestrator) ShareSession(sessionID, grantedBy, username string) (*store.Share, error) {
+	user, err := o.users.ByUsername(username)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ownerID, err := o.sessions.Owner(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if ownerID == user.ID {
+		return nil, ErrShareWithOwner
+	}
+
+	createdAt, err := o.sessions.Share(sessionID, user.ID, grantedBy)
+	if err != nil {
+		return nil, err
+	}
+	return &store.Share{UserID: user.ID, Username: user.Username, AvatarURL: user.AvatarURL, CreatedAt: createdAt}, nil
+}
+
+// UnshareSession withdraws a user's access. It reports whether a grant existed.
+func (o *Orchestrator) UnshareSession(sessionID, userID string) (bool, error) {
+	return o.sessions.Unshare(sessionID, userID)
+}
+
+// SessionShares lists who a session is shared with
+func (o *Orchestrator) SessionShares(sessionID string) ([]store.Share, error) {
+	return o.sessions.Shares(sessionID)
+}
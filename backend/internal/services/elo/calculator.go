@@ -2,20 +2,16 @@ package elo
 
 import (
 	"database/sql"
+	"encoding/json"
 	"math"
 
+	"github.com/sainaif/council/internal/config"
 	"github.com/sainaif/council/internal/database"
 )
 
-const (
-	InitialRating = 1500
-	KFactorNew    = 25 // Players with < 30 games
-	KFactorNormal = 15 // Regular players
-	KFactorPro    = 10 // Top performers (rating > 2000)
-)
-
 type Calculator struct {
-	db *database.DB
+	db     *database.DB
+	params config.EloConfig
 }
 
 type RatingChange struct {
@@ -26,8 +22,8 @@ type RatingChange struct {
 	CategoryID *int64 `json:"category_id,omitempty"`
 }
 
-func NewCalculator(db *database.DB) *Calculator {
-	return &Calculator{db: db}
+func NewCalculator(db *database.DB, params config.EloConfig) *Calculator {
+	return &Calculator{db: db, params: params}
 }
 
 // ExpectedScore calculates the expected score using the logistic function
@@ -36,21 +32,66 @@ func ExpectedScore(ratingA, ratingB int) float64 {
 }
 
 // GetKFactor determines the K-factor based on games played and rating
-func GetKFactor(gamesPlayed, rating int) int {
-	if gamesPlayed < 30 {
-		return KFactorNew
+func GetKFactor(params config.EloParams, gamesPlayed, rating int) int {
+	if gamesPlayed < params.ProvisionalGames {
+		return params.KFactorNew
+	}
+	if rating > params.ProThreshold {
+		return params.KFactorPro
+	}
+	return params.KFactorNormal
+}
+
+// ParamsFor returns the rating parameters in effect for a category
+func (c *Calculator) ParamsFor(categoryID *int64) (config.EloParams, error) {
+	if categoryID == nil || len(c.params.Categories) == 0 {
+		return c.params.Default, nil
 	}
-	if rating > 2000 {
-		return KFactorPro
+
+	var name string
+	err := c.db.QueryRow(`SELECT name FROM categories WHERE id = ?`, *categoryID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return c.params.Default, nil
 	}
-	return KFactorNormal
+	if err != nil {
+		return config.EloParams{}, err
+	}
+
+	return c.params.ForCategory(name), nil
+}
+
+// track selects which ratings an update reads and writes: the global
+// leaderboard, or the personal leaderboard of a single user
+type track struct {
+	userID string
+}
+
+func (t track) personal() bool {
+	return t.userID != ""
 }
 
-// UpdateRatings updates ELO ratings based on voting results
-// rankings maps voter to their ordered list of model IDs (best first)
-func (c *Calculator) UpdateRatings(sessionID string, categoryID *int64, rankings map[string][]string) ([]RatingChange, error) {
+// UpdateRatings updates global ELO ratings based on voting results
+// rankings maps voter to their ordered list of model IDs (best first),
+// weights maps voter to their vote weight (missing voters count 1.0)
+func (c *Calculator) UpdateRatings(sessionID string, categoryID *int64, rankings map[string][]string, weights map[string]float64) ([]RatingChange, error) {
+	return c.updateRatings(track{}, sessionID, categoryID, rankings, weights)
+}
+
+// UpdateUserRatings updates a user's personal ELO ratings, which only
+// reflect sessions run by that user
+func (c *Calculator) UpdateUserRatings(userID, sessionID string, categoryID *int64, rankings map[string][]string, weights map[string]float64) ([]RatingChange, error) {
+	return c.updateRatings(track{userID: userID}, sessionID, categoryID, rankings, weights)
+}
+
+func (c *Calculator) updateRatings(tr track, sessionID string, categoryID *int64, rankings map[string][]string, weights map[string]float64) ([]RatingChange, error) {
 	var changes []RatingChange
 
+	params, err := c.ParamsFor(categoryID)
+	if err != nil {
+		return nil, err
+	}
+	paramsJSON, _ := json.Marshal(params)
+
 	// Extract all models from rankings
 	models := make(map[string]bool)
 	for _, ranking := range rankings {
@@ -64,7 +105,7 @@ func (c *Calculator) UpdateRatings(sessionID string, categoryID *int64, rankings
 	gamesPlayed := make(map[string]int)
 
 	for modelID := range models {
-		rating, games, err := c.getModelRating(modelID, categoryID)
+		rating, games, err := c.getModelRating(tr, modelID, categoryID, params.InitialRating)
 		if err != nil {
 			return nil, err
 		}
@@ -79,14 +120,21 @@ func (c *Calculator) UpdateRatings(sessionID string, categoryID *int64, rankings
 	}
 
 	// Process each ranking to create pairwise comparisons
-	for _, ranking := range rankings {
+	totalWeight := 0.0
+	for voterID, ranking := range rankings {
+		weight, ok := weights[voterID]
+		if !ok {
+			weight = 1.0
+		}
+		totalWeight += weight
+
 		for i := 0; i < len(ranking); i++ {
 			for j := i + 1; j < len(ranking); j++ {
 				winner := ranking[i]
 				loser := ranking[j]
 
-				// Winner gets a point against loser
-				pairResults[winner][loser] += 1.0
+				// Winner gets the voter's weight against loser
+				pairResults[winner][loser] += weight
 				pairResults[loser][winner] += 0.0
 			}
 		}
@@ -94,12 +142,14 @@ func (c *Calculator) UpdateRatings(sessionID string, categoryID *int64, rankings
 
 	// Calculate new ratings
 	newRatings := make(map[string]float64)
+	kFactors := make(map[string]int)
 	for modelID := range models {
 		newRatings[modelID] = float64(currentRatings[modelID])
+		kFactors[modelID] = GetKFactor(params, gamesPlayed[modelID], currentRatings[modelID])
 	}
 
 	// Apply ELO adjustments for each pairwise matchup
-	numVoters := float64(len(rankings))
+	numVoters := totalWeight
 	for modelA := range models {
 		for modelB, score := range pairResults[modelA] {
 			if modelA >= modelB {
@@ -115,8 +165,8 @@ func (c *Calculator) UpdateRatings(sessionID string, categoryID *int64, rankings
 			expectedA := ExpectedScore(ratingA, ratingB)
 			expectedB := 1 - expectedA
 
-			kA := float64(GetKFactor(gamesPlayed[modelA], ratingA))
-			kB := float64(GetKFactor(gamesPlayed[modelB], ratingB))
+			kA := float64(kFactors[modelA])
+			kB := float64(kFactors[modelB])
 
 			newRatings[modelA] += kA * (scoreA - expectedA)
 			newRatings[modelB] += kB * (scoreB - expectedB)
@@ -124,7 +174,7 @@ func (c *Calculator) UpdateRatings(sessionID string, categoryID *int64, rankings
 	}
 
 	// Update database and collect changes
-	err := c.db.WithTx(func(tx *sql.Tx) error {
+	err = c.db.WithTx(func(tx *sql.Tx) error {
 		for modelID := range models {
 			oldRating := currentRatings[modelID]
 			newRating := int(math.Round(newRatings[modelID]))
@@ -147,12 +197,12 @@ func (c *Calculator) UpdateRatings(sessionID string, categoryID *int64, rankings
 			}
 
 			// Update model_ratings
-			if err := c.updateModelRating(tx, modelID, categoryID, newRating, wins, losses, draws); err != nil {
+			if err := c.updateModelRating(tx, tr, modelID, categoryID, newRating, wins, losses, draws); err != nil {
 				return err
 			}
 
 			// Record history
-			if err := c.recordHistory(tx, modelID, categoryID, sessionID, oldRating, newRating, change); err != nil {
+			if err := c.recordHistory(tx, tr, modelID, categoryID, sessionID, oldRating, newRating, change, kFactors[modelID], string(paramsJSON)); err != nil {
 				return err
 			}
 
@@ -164,6 +214,22 @@ func (c *Calculator) UpdateRatings(sessionID string, categoryID *int64, rankings
 				CategoryID: categoryID,
 			})
 		}
+
+		// Record head-to-head outcomes for every pair
+		if tr.personal() {
+			return nil
+		}
+		for modelA := range models {
+			for modelB := range models {
+				if modelA >= modelB {
+					continue
+				}
+				winnerID := pairWinner(modelA, modelB, pairResults[modelA][modelB], pairResults[modelB][modelA])
+				if err := c.UpdateMatchup(tx, sessionID, modelA, modelB, categoryID, winnerID); err != nil {
+					return err
+				}
+			}
+		}
 		return nil
 	})
 
@@ -174,25 +240,29 @@ func (c *Calculator) UpdateRatings(sessionID string, categoryID *int64, rankings
 	return changes, nil
 }
 
-func (c *Calculator) getModelRating(modelID string, categoryID *int64) (int, int, error) {
+func (c *Calculator) getModelRating(tr track, modelID string, categoryID *int64, initialRating int) (int, int, error) {
 	var rating, wins, losses, draws int
 
 	var query string
 	var args []interface{}
 
-	if categoryID != nil {
+	if tr.personal() {
+		query = `SELECT rating, wins, losses, draws FROM user_model_ratings
+				 WHERE user_id = ? AND model_id = ? AND COALESCE(category_id, 0) = COALESCE(?, 0)`
+		args = []interface{}{tr.userID, modelID, categoryID}
+	} else if categoryID != nil {
 		query = `SELECT COALESCE(rating, ?), COALESCE(wins, 0), COALESCE(losses, 0), COALESCE(draws, 0)
 				 FROM model_ratings WHERE model_id = ? AND category_id = ?`
-		args = []interface{}{InitialRating, modelID, *categoryID}
+		args = []interface{}{initialRating, modelID, *categoryID}
 	} else {
 		query = `SELECT COALESCE(rating, ?), COALESCE(wins, 0), COALESCE(losses, 0), COALESCE(draws, 0)
 				 FROM model_ratings WHERE model_id = ? AND category_id IS NULL`
-		args = []interface{}{InitialRating, modelID}
+		args = []interface{}{initialRating, modelID}
 	}
 
 	err := c.db.QueryRow(query, args...).Scan(&rating, &wins, &losses, &draws)
 	if err == sql.ErrNoRows {
-		return InitialRating, 0, nil
+		return initialRating, 0, nil
 	}
 	if err != nil {
 		return 0, 0, err
@@ -201,35 +271,36 @@ func (c *Calculator) getModelRating(modelID string, categoryID *int64) (int, int
 	return rating, wins + losses + draws, nil
 }
 
-func (c *Calculator) updateModelRating(tx *sql.Tx, modelID string, categoryID *int64, rating, wins, losses, draws int) error {
-	if categoryID != nil {
+func (c *Calculator) updateModelRating(tx *sql.Tx, tr track, modelID string, categoryID *int64, rating, wins, losses, draws int) error {
+	if tr.personal() {
 		_, err := tx.Exec(`
-			INSERT INTO model_ratings (model_id, category_id, rating, wins, losses, draws, updated_at)
-			VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
-			ON CONFLICT(model_id, category_id) DO UPDATE SET
-				rating = rating + ? - model_ratings.rating,
-				wins = wins + ?,
-				losses = losses + ?,
-				draws = draws + ?,
+			INSERT INTO user_model_ratings (user_id, model_id, category_id, rating, wins, losses, draws, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+			ON CONFLICT(user_id, model_id, COALESCE(category_id, 0)) DO UPDATE SET
+				rating = excluded.rating,
+				wins = user_model_ratings.wins + excluded.wins,
+				losses = user_model_ratings.losses + excluded.losses,
+				draws = user_model_ratings.draws + excluded.draws,
 				updated_at = CURRENT_TIMESTAMP
-		`, modelID, *categoryID, rating, wins, losses, draws, rating, wins, losses, draws)
+		`, tr.userID, modelID, categoryID, rating, wins, losses, draws)
 		return err
 	}
 
 	_, err := tx.Exec(`
-		INSERT INTO model_ratings (model_id, category_id, rating, wins, losses, draws, updated_at)
-		VALUES (?, NULL, ?, ?, ?, ?, CURRENT_TIMESTAMP)
-		ON CONFLICT(model_id, category_id) DO UPDATE SET
-			rating = ?,
-			wins = wins + ?,
-			losses = losses + ?,
-			draws = draws + ?,
-			updated_at = CURRENT_TIMESTAMP
-	`, modelID, rating, wins, losses, draws, rating, wins, losses, draws)
+		INSERT INTO model_ratings (model_id, category_id, rating, wins, losses, draws, updated_at, last_game_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT(model_id, COALESCE(category_id, 0)) DO UPDATE SET
+			rating = excluded.rating,
+			wins = model_ratings.wins + excluded.wins,
+			losses = model_ratings.losses + excluded.losses,
+			draws = model_ratings.draws + excluded.draws,
+			updated_at = CURRENT_TIMESTAMP,
+			last_game_at = CURRENT_TIMESTAMP
+	`, modelID, categoryID, rating, wins, losses, draws)
 	return err
 }
 
-func (c *Calculator) recordHistory(tx *sql.Tx, modelID string, categoryID *int64, sessionID string, oldRating, newRating, change int) error {
+func (c *Calculator) recordHistory(tx *sql.Tx, tr track, modelID string, categoryID *int64, sessionID string, oldRating, newRating, change, kFactor int, params string) error {
 	var reason string
 	switch {
 	case change > 0:
@@ -240,15 +311,42 @@ func (c *Calculator) recordHistory(tx *sql.Tx, modelID string, categoryID *int64
 		reason = "draw"
 	}
 
+	if tr.personal() {
+		_, err := tx.Exec(`
+			INSERT INTO user_elo_history (user_id, model_id, category_id, session_id, old_rating, new_rating, change, reason, k_factor, params)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, tr.userID, modelID, categoryID, sessionID, oldRating, newRating, change, reason, kFactor, params)
+		return err
+	}
+
 	_, err := tx.Exec(`
-		INSERT INTO elo_history (model_id, category_id, session_id, old_rating, new_rating, change, reason)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, modelID, categoryID, sessionID, oldRating, newRating, change, reason)
+		INSERT INTO elo_history (model_id, category_id, session_id, old_rating, new_rating, change, reason, k_factor, params)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, modelID, categoryID, sessionID, oldRating, newRating, change, reason, kFactor, params)
 	return err
 }
 
+// pairWinner decides a pairwise matchup from the aggregated scores of both
+// models, returning an empty string for a draw
+func pairWinner(modelA, modelB string, scoreA, scoreB float64) string {
+	total := scoreA + scoreB
+	if total == 0 {
+		return ""
+	}
+	share := scoreA / total
+	switch {
+	case share > 0.6:
+		return modelA
+	case share < 0.4:
+		return modelB
+	default:
+		return ""
+	}
+}
+
 // UpdateMatchup updates the head-to-head record between two models
-func (c *Calculator) UpdateMatchup(tx *sql.Tx, modelA, modelB string, categoryID *int64, winnerID string) error {
+// winnerID is empty for a draw
+func (c *Calculator) UpdateMatchup(tx *sql.Tx, sessionID, modelA, modelB string, categoryID *int64, winnerID string) error {
 	// Ensure consistent ordering
 	if modelA > modelB {
 		modelA, modelB = modelB, modelA
@@ -267,28 +365,46 @@ func (c *Calculator) UpdateMatchup(tx *sql.Tx, modelA, modelB string, categoryID
 	_, err := tx.Exec(`
 		INSERT INTO matchups (model_a_id, model_b_id, category_id, model_a_wins, model_b_wins, draws, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
-		ON CONFLICT(model_a_id, model_b_id, category_id) DO UPDATE SET
-			model_a_wins = model_a_wins + ?,
-			model_b_wins = model_b_wins + ?,
-			draws = draws + ?,
+		ON CONFLICT(model_a_id, model_b_id, COALESCE(category_id, 0)) DO UPDATE SET
+			model_a_wins = matchups.model_a_wins + excluded.model_a_wins,
+			model_b_wins = matchups.model_b_wins + excluded.model_b_wins,
+			draws = matchups.draws + excluded.draws,
 			updated_at = CURRENT_TIMESTAMP
-	`, modelA, modelB, categoryID, aWins, bWins, draws, aWins, bWins, draws)
+	`, modelA, modelB, categoryID, aWins, bWins, draws)
+	if err != nil {
+		return err
+	}
+
+	var winner *string
+	if winnerID == modelA || winnerID == modelB {
+		winner = &winnerID
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO matchup_results (session_id, model_a_id, model_b_id, category_id, winner_id)
+		VALUES (?, ?, ?, ?, ?)
+	`, sessionID, modelA, modelB, categoryID, winner)
 	return err
 }
 
 // GetModelStats returns comprehensive stats for a model
 type ModelStats struct {
-	ModelID    string `json:"model_id"`
-	Rating     int    `json:"rating"`
-	Wins       int    `json:"wins"`
-	Losses     int    `json:"losses"`
-	Draws      int    `json:"draws"`
-	WinRate    float64 `json:"win_rate"`
-	GamesPlayed int   `json:"games_played"`
+	ModelID     string  `json:"model_id"`
+	Rating      int     `json:"rating"`
+	Wins        int     `json:"wins"`
+	Losses      int     `json:"losses"`
+	Draws       int     `json:"draws"`
+	WinRate     float64 `json:"win_rate"`
+	GamesPlayed int     `json:"games_played"`
 }
 
 func (c *Calculator) GetModelStats(modelID string, categoryID *int64) (*ModelStats, error) {
-	rating, games, err := c.getModelRating(modelID, categoryID)
+	params, err := c.ParamsFor(categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	rating, games, err := c.getModelRating(track{}, modelID, categoryID, params.InitialRating)
 	if err != nil {
 		return nil, err
 	}
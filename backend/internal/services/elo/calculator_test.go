@@ -0,0 +1,226 @@
+package elo
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/sainaif/council/internal/config"
+	"github.com/sainaif/council/internal/database"
+)
+
+var testParams = config.EloConfig{
+	Default: config.EloParams{
+		InitialRating:    1500,
+		KFactorNew:       32,
+		KFactorNormal:    16,
+		KFactorPro:       8,
+		ProvisionalGames: 10,
+		ProThreshold:     2000,
+	},
+	Categories: map[string]config.EloParams{
+		"math": {
+			InitialRating:    1400,
+			KFactorNew:       40,
+			KFactorNormal:    20,
+			KFactorPro:       10,
+			ProvisionalGames: 5,
+			ProThreshold:     1800,
+		},
+	},
+}
+
+func openTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := db.Exec(`INSERT INTO models (id, display_name, provider) VALUES (?, ?, 'test')`, id, "Model "+id); err != nil {
+			t.Fatalf("insert model %s: %v", id, err)
+		}
+	}
+	return db
+}
+
+func categoryID(t *testing.T, db *database.DB, name string) *int64 {
+	t.Helper()
+	var id int64
+	if err := db.QueryRow(`SELECT id FROM categories WHERE name = ?`, name).Scan(&id); err != nil {
+		t.Fatalf("category %s: %v", name, err)
+	}
+	return &id
+}
+
+func TestExpectedScore(t *testing.T) {
+	tests := []struct {
+		a, b int
+		want float64
+	}{
+		{1500, 1500, 0.5},
+		{1900, 1500, 1 / (1 + math.Pow(10, -1))},
+		{1500, 1900, 1 / (1 + math.Pow(10, 1))},
+	}
+	for _, tt := range tests {
+		if got := ExpectedScore(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("ExpectedScore(%d, %d) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestGetKFactor(t *testing.T) {
+	tests := []struct {
+		name   string
+		games  int
+		rating int
+		want   int
+	}{
+		{"provisional", 9, 2100, 32},
+		{"normal", 10, 2000, 16},
+		{"pro", 10, 2001, 8},
+	}
+	for _, tt := range tests {
+		if got := GetKFactor(testParams.Default, tt.games, tt.rating); got != tt.want {
+			t.Errorf("%s: GetKFactor(%d, %d) = %d, want %d", tt.name, tt.games, tt.rating, got, tt.want)
+		}
+	}
+}
+
+func TestParamsFor(t *testing.T) {
+	db := openTestDB(t)
+	missing := int64(9999)
+
+	tests := []struct {
+		name       string
+		params     config.EloConfig
+		categoryID *int64
+		want       config.EloParams
+	}{
+		{"uncategorized", testParams, nil, testParams.Default},
+		{"overridden category", testParams, categoryID(t, db, "math"), testParams.Categories["math"]},
+		{"category without override", testParams, categoryID(t, db, "coding"), testParams.Default},
+		{"unknown category", testParams, &missing, testParams.Default},
+		{"no overrides", config.EloConfig{Default: testParams.Default}, categoryID(t, db, "math"), testParams.Default},
+	}
+	for _, tt := range tests {
+		got, err := NewCalculator(db, tt.params).ParamsFor(tt.categoryID)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: ParamsFor = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateRatings(t *testing.T) {
+	tests := []struct {
+		name     string
+		category string
+		want     config.EloParams
+	}{
+		{"uncategorized", "", testParams.Default},
+		{"default category", "coding", testParams.Default},
+		{"overridden category", "math", testParams.Categories["math"]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			calc := NewCalculator(db, testParams)
+			var cat *int64
+			if tt.category != "" {
+				cat = categoryID(t, db, tt.category)
+			}
+
+			rankings := map[string][]string{"judge": {"a", "b"}}
+			for _, session := range []string{"s1", "s2"} {
+				if _, err := db.Exec(`
+					INSERT INTO sessions (id, user_id, question, category_id, mode, status) VALUES (?, 'u1', 'Q', ?, 'standard', 'completed')
+				`, session, cat); err != nil {
+					t.Fatalf("insert session %s: %v", session, err)
+				}
+				changes, err := calc.UpdateRatings(session, cat, rankings, nil)
+				if err != nil {
+					t.Fatalf("UpdateRatings(%s): %v", session, err)
+				}
+				if len(changes) != 2 {
+					t.Fatalf("UpdateRatings(%s) = %+v, want a change per model", session, changes)
+				}
+			}
+
+			// The first game starts from the category's initial rating with its
+			// provisional K-factor: a and b are even, so a wins K/2
+			first := tt.want.InitialRating + tt.want.KFactorNew/2
+			rows, err := db.Query(`
+				SELECT old_rating, new_rating, k_factor, params FROM elo_history
+				WHERE model_id = 'a' AND COALESCE(category_id, 0) = COALESCE(?, 0)
+				ORDER BY id
+			`, cat)
+			if err != nil {
+				t.Fatalf("query history: %v", err)
+			}
+			defer func() { _ = rows.Close() }()
+
+			var history []int
+			for rows.Next() {
+				var oldRating, newRating, kFactor int
+				var paramsJSON string
+				if err := rows.Scan(&oldRating, &newRating, &kFactor, &paramsJSON); err != nil {
+					t.Fatalf("scan history: %v", err)
+				}
+				history = append(history, oldRating, newRating)
+				if kFactor != tt.want.KFactorNew {
+					t.Errorf("k_factor = %d, want %d", kFactor, tt.want.KFactorNew)
+				}
+				var recorded config.EloParams
+				if err := json.Unmarshal([]byte(paramsJSON), &recorded); err != nil {
+					t.Fatalf("params %q: %v", paramsJSON, err)
+				}
+				if recorded != tt.want {
+					t.Errorf("recorded params = %+v, want %+v", recorded, tt.want)
+				}
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatalf("history: %v", err)
+			}
+			if len(history) != 4 || history[0] != tt.want.InitialRating || history[1] != first || history[2] != first {
+				t.Errorf("history of a = %v, want %d -> %d, then from %d", history, tt.want.InitialRating, first, first)
+			}
+
+			// Both games land in a single row per model
+			var count, rating, wins int
+			err = db.QueryRow(`
+				SELECT COUNT(*), MAX(rating), MAX(wins) FROM model_ratings
+				WHERE model_id = 'a' AND COALESCE(category_id, 0) = COALESCE(?, 0)
+			`, cat).Scan(&count, &rating, &wins)
+			if err != nil {
+				t.Fatalf("query rating: %v", err)
+			}
+			if count != 1 || rating != history[3] || wins != 2 {
+				t.Errorf("rating rows = %d, rating %d with %d wins, want 1 row at %d with 2 wins", count, rating, wins, history[3])
+			}
+		})
+	}
+}
+
+func TestPairWinner(t *testing.T) {
+	tests := []struct {
+		scoreA, scoreB float64
+		want           string
+	}{
+		{2, 0, "a"},
+		{0, 2, "b"},
+		{1, 1, ""},
+		{0, 0, ""},
+	}
+	for _, tt := range tests {
+		if got := pairWinner("a", "b", tt.scoreA, tt.scoreB); got != tt.want {
+			t.Errorf("pairWinner(%v, %v) = %q, want %q", tt.scoreA, tt.scoreB, got, tt.want)
+		}
+	}
+}
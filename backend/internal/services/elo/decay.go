@@ -0,0 +1,113 @@
+package elo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// timestampFormat matches SQLite's CURRENT_TIMESTAMP so values compare as text
+const timestampFormat = "2006-01-02 15:04:05"
+
+type decayCandidate struct {
+	id           int64
+	modelID      string
+	categoryID   *int64
+	categoryName string
+	rating       int
+	lastGameAt   time.Time
+	decayedAt    sql.NullTime
+}
+
+// ApplyDecay moves the global ratings of models without recent games back
+// towards the initial rating. Each elapsed day past the grace period closes
+// DecayRate of the remaining gap; changes are recorded in elo_history.
+// It returns the number of ratings that changed.
+func (c *Calculator) ApplyDecay(now time.Time) (int, error) {
+	if c.params.DecayAfterDays <= 0 || c.params.DecayRate <= 0 {
+		return 0, nil
+	}
+
+	grace := time.Duration(c.params.DecayAfterDays) * 24 * time.Hour
+	rows, err := c.db.Query(`
+		SELECT mr.id, mr.model_id, mr.category_id, COALESCE(cat.name, ''), mr.rating,
+			   mr.last_game_at, mr.updated_at, mr.decayed_at
+		FROM model_ratings mr
+		LEFT JOIN categories cat ON mr.category_id = cat.id
+		WHERE COALESCE(mr.last_game_at, mr.updated_at) < ?
+	`, now.UTC().Add(-grace).Format(timestampFormat))
+	if err != nil {
+		return 0, err
+	}
+
+	var candidates []decayCandidate
+	for rows.Next() {
+		var d decayCandidate
+		var categoryID sql.NullInt64
+		var lastGameAt sql.NullTime
+		if err := rows.Scan(&d.id, &d.modelID, &categoryID, &d.categoryName, &d.rating, &lastGameAt, &d.lastGameAt, &d.decayedAt); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		if categoryID.Valid {
+			d.categoryID = &categoryID.Int64
+		}
+		if lastGameAt.Valid {
+			d.lastGameAt = lastGameAt.Time
+		}
+		candidates = append(candidates, d)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	err = c.db.WithTx(func(tx *sql.Tx) error {
+		for _, d := range candidates {
+			// Decay starts after the grace period, or where the last run left off
+			start := d.lastGameAt.Add(grace)
+			if d.decayedAt.Valid && d.decayedAt.Time.After(start) {
+				start = d.decayedAt.Time
+			}
+			days := int(now.Sub(start).Hours() / 24)
+			if days < 1 {
+				continue
+			}
+
+			params := c.params.Default
+			if d.categoryName != "" {
+				params = c.params.ForCategory(d.categoryName)
+			}
+			gap := float64(d.rating - params.InitialRating)
+			newRating := params.InitialRating + int(math.Round(gap*math.Pow(1-c.params.DecayRate, float64(days))))
+
+			// Only advance by whole days so partial days carry over to the next run
+			decayedAt := start.Add(time.Duration(days) * 24 * time.Hour).UTC().Format(timestampFormat)
+			if _, err := tx.Exec(`
+				UPDATE model_ratings SET rating = ?, decayed_at = ? WHERE id = ?
+			`, newRating, decayedAt, d.id); err != nil {
+				return err
+			}
+
+			if newRating == d.rating {
+				continue
+			}
+			paramsJSON, _ := json.Marshal(params)
+			if _, err := tx.Exec(`
+				INSERT INTO elo_history (model_id, category_id, session_id, old_rating, new_rating, change, reason, params)
+				VALUES (?, ?, NULL, ?, ?, ?, 'decay', ?)
+			`, d.modelID, d.categoryID, d.rating, newRating, newRating-d.rating, string(paramsJSON)); err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return changed, nil
+}
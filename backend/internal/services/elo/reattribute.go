@@ -0,0 +1,200 @@
+package elo
+
+import (
+	"database/sql"
+)
+
+type outcome struct {
+	wins, losses, draws int
+}
+
+// RevertSession undoes the rating changes a session made under a category,
+// on the global track and on the personal track of the session's user.
+// Each model's rating moves back by the session's net change, its game
+// counts drop by the session's outcomes, and the reversal is recorded in
+// the history with reason 'reattributed'. Later games that built on the
+// reverted ratings are left as they are.
+// It reports whether the session had been rated under the category.
+func (c *Calculator) RevertSession(sessionID, userID string, categoryID *int64) (bool, error) {
+	// Head-to-head results are the record of what the session decided
+	rows, err := c.db.Query(`
+		SELECT model_a_id, model_b_id, winner_id FROM matchup_results
+		WHERE session_id = ? AND COALESCE(category_id, 0) = COALESCE(?, 0)
+	`, sessionID, categoryID)
+	if err != nil {
+		return false, err
+	}
+
+	type result struct {
+		modelA, modelB string
+		winnerID       sql.NullString
+	}
+	var results []result
+	outcomes := make(map[string]*outcome)
+	for rows.Next() {
+		var r result
+		if err := rows.Scan(&r.modelA, &r.modelB, &r.winnerID); err != nil {
+			_ = rows.Close()
+			return false, err
+		}
+		results = append(results, r)
+
+		for _, id := range []string{r.modelA, r.modelB} {
+			if outcomes[id] == nil {
+				outcomes[id] = &outcome{}
+			}
+		}
+		switch r.winnerID.String {
+		case r.modelA:
+			outcomes[r.modelA].wins++
+			outcomes[r.modelB].losses++
+		case r.modelB:
+			outcomes[r.modelB].wins++
+			outcomes[r.modelA].losses++
+		default:
+			outcomes[r.modelA].draws++
+			outcomes[r.modelB].draws++
+		}
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	if len(results) == 0 {
+		return false, nil
+	}
+
+	globalNet, err := c.sessionNetChanges(track{}, sessionID, categoryID)
+	if err != nil {
+		return false, err
+	}
+	personalNet, err := c.sessionNetChanges(track{userID: userID}, sessionID, categoryID)
+	if err != nil {
+		return false, err
+	}
+
+	err = c.db.WithTx(func(tx *sql.Tx) error {
+		for _, tr := range []track{{}, {userID: userID}} {
+			net := globalNet
+			if tr.personal() {
+				net = personalNet
+			}
+			for modelID, o := range outcomes {
+				if err := c.revertModelRating(tx, tr, sessionID, modelID, categoryID, net[modelID], o); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, r := range results {
+			var aWins, bWins, draws int
+			switch r.winnerID.String {
+			case r.modelA:
+				aWins = 1
+			case r.modelB:
+				bWins = 1
+			default:
+				draws = 1
+			}
+			if _, err := tx.Exec(`
+				UPDATE matchups SET
+					model_a_wins = `+c.db.Dialect.Greatest("model_a_wins - ?", "0")+`,
+					model_b_wins = `+c.db.Dialect.Greatest("model_b_wins - ?", "0")+`,
+					draws = `+c.db.Dialect.Greatest("draws - ?", "0")+`,
+					updated_at = CURRENT_TIMESTAMP
+				WHERE model_a_id = ? AND model_b_id = ? AND COALESCE(category_id, 0) = COALESCE(?, 0)
+			`, aWins, bWins, draws, r.modelA, r.modelB, categoryID); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Exec(`
+			DELETE FROM matchup_results
+			WHERE session_id = ? AND COALESCE(category_id, 0) = COALESCE(?, 0)
+		`, sessionID, categoryID)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// sessionNetChanges sums the rating changes a session made per model under a category
+func (c *Calculator) sessionNetChanges(tr track, sessionID string, categoryID *int64) (map[string]int, error) {
+	query := `SELECT model_id, SUM(change) FROM elo_history
+			  WHERE session_id = ? AND COALESCE(category_id, 0) = COALESCE(?, 0)
+			  GROUP BY model_id`
+	args := []interface{}{sessionID, categoryID}
+	if tr.personal() {
+		query = `SELECT model_id, SUM(change) FROM user_elo_history
+				 WHERE user_id = ? AND session_id = ? AND COALESCE(category_id, 0) = COALESCE(?, 0)
+				 GROUP BY model_id`
+		args = []interface{}{tr.userID, sessionID, categoryID}
+	}
+
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	net := make(map[string]int)
+	for rows.Next() {
+		var modelID string
+		var change int
+		if err := rows.Scan(&modelID, &change); err != nil {
+			return nil, err
+		}
+		net[modelID] = change
+	}
+	return net, rows.Err()
+}
+
+func (c *Calculator) revertModelRating(tx *sql.Tx, tr track, sessionID, modelID string, categoryID *int64, change int, o *outcome) error {
+	table, where := "model_ratings", `model_id = ? AND COALESCE(category_id, 0) = COALESCE(?, 0)`
+	args := []interface{}{modelID, categoryID}
+	if tr.personal() {
+		table, where = "user_model_ratings", `user_id = ? AND model_id = ? AND COALESCE(category_id, 0) = COALESCE(?, 0)`
+		args = []interface{}{tr.userID, modelID, categoryID}
+	}
+
+	var oldRating int
+	err := tx.QueryRow(`SELECT rating FROM `+table+` WHERE `+where, args...).Scan(&oldRating)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	newRating := oldRating - change
+	if _, err := tx.Exec(`
+		UPDATE `+table+` SET
+			rating = ?,
+			wins = `+c.db.Dialect.Greatest("wins - ?", "0")+`,
+			losses = `+c.db.Dialect.Greatest("losses - ?", "0")+`,
+			draws = `+c.db.Dialect.Greatest("draws - ?", "0")+`,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE `+where,
+		append([]interface{}{newRating, o.wins, o.losses, o.draws}, args...)...); err != nil {
+		return err
+	}
+
+	if change == 0 {
+		return nil
+	}
+	if tr.personal() {
+		_, err = tx.Exec(`
+			INSERT INTO user_elo_history (user_id, model_id, category_id, session_id, old_rating, new_rating, change, reason)
+			VALUES (?, ?, ?, ?, ?, ?, ?, 'reattributed')
+		`, tr.userID, modelID, categoryID, sessionID, oldRating, newRating, -change)
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO elo_history (model_id, category_id, session_id, old_rating, new_rating, change, reason)
+		VALUES (?, ?, ?, ?, ?, ?, 'reattributed')
+	`, modelID, categoryID, sessionID, oldRating, newRating, -change)
+	return err
+}
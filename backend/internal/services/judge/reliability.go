@@ -0,0 +1,249 @@
+package judge
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+
+	"github.com/sainaif/council/internal/database"
+	"github.com/sainaif/council/internal/services/agreement"
+)
+
+const (
+	ReferenceConsensus = "consensus"
+	ReferenceUser      = "user"
+
+	MinWeight = 0.5 // Weight of a judge that never agrees with anyone
+	MaxWeight = 1.5 // Weight of a judge that always agrees
+
+	userEvidenceFactor = 2.0 // A user comparison counts as much as two consensus comparisons
+	priorStrength      = 5.0 // Pseudo-observations pulling new judges towards neutral
+	neutralAgreement   = 0.5
+)
+
+// Ballot is a single judge's ranking of anonymized responses
+type Ballot struct {
+	JudgeID string
+	Ranking []string
+	Weight  float64
+}
+
+// Stats summarises how well a judge agrees with consensus and users
+type Stats struct {
+	Rank               int     `json:"rank"`
+	JudgeID            string  `json:"judge_id"`
+	DisplayName        string  `json:"display_name"`
+	SessionsJudged     int     `json:"sessions_judged"`
+	ConsensusAgreement float64 `json:"consensus_agreement"`
+	UserComparisons    int     `json:"user_comparisons"`
+	UserAgreement      float64 `json:"user_agreement"`
+	Reliability        float64 `json:"reliability"`
+	Weight             float64 `json:"weight"`
+	consensusSum       float64
+	userSum            float64
+}
+
+type Tracker struct {
+	db *database.DB
+}
+
+func NewTracker(db *database.DB) *Tracker {
+	return &Tracker{db: db}
+}
+
+// Weight returns the vote weight a judge has earned so far
+func (t *Tracker) Weight(judgeID string) float64 {
+	stats, err := t.getStats(judgeID)
+	if err != nil {
+		return WeightFor(neutralAgreement)
+	}
+	return stats.Weight
+}
+
+// RecordConsensus scores every ballot against the consensus of the other ballots
+func (t *Tracker) RecordConsensus(sessionID string, ballots []Ballot) error {
+	if len(ballots) < 2 {
+		return nil
+	}
+
+	return t.db.WithTx(func(tx *sql.Tx) error {
+		for i, b := range ballots {
+			others := make([]Ballot, 0, len(ballots)-1)
+			others = append(others, ballots[:i]...)
+			others = append(others, ballots[i+1:]...)
+
+			tau, ok := agreement.KendallTau(b.Ranking, Consensus(others))
+			if !ok {
+				continue
+			}
+			if err := recordEvaluation(tx, sessionID, b.JudgeID, ReferenceConsensus, tau); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RecordUserVote scores the session's model ballots against a user's ranking
+func (t *Tracker) RecordUserVote(sessionID string, userRanking []string) error {
+	rows, err := t.db.Query(`
+		SELECT voter_id, ranked_responses FROM votes
+		WHERE session_id = ? AND voter_type = 'model'
+	`, sessionID)
+	if err != nil {
+		return err
+	}
+
+	var ballots []Ballot
+	for rows.Next() {
+		var b Ballot
+		var rankedJSON string
+		if err := rows.Scan(&b.JudgeID, &rankedJSON); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		if err := json.Unmarshal([]byte(rankedJSON), &b.Ranking); err != nil {
+			continue
+		}
+		ballots = append(ballots, b)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return t.db.WithTx(func(tx *sql.Tx) error {
+		for _, b := range ballots {
+			tau, ok := agreement.KendallTau(b.Ranking, userRanking)
+			if !ok {
+				continue
+			}
+			if err := recordEvaluation(tx, sessionID, b.JudgeID, ReferenceUser, tau); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func recordEvaluation(tx *sql.Tx, sessionID, judgeID, reference string, tau float64) error {
+	_, err := tx.Exec(`
+		INSERT INTO judge_evaluations (session_id, judge_id, reference, agreement)
+		VALUES (?, ?, ?, ?)
+	`, sessionID, judgeID, reference, (tau+1)/2)
+	return err
+}
+
+// Leaderboard returns judges ordered by reliability
+func (t *Tracker) Leaderboard(limit int) ([]Stats, error) {
+	rows, err := t.db.Query(statsQuery + `GROUP BY je.judge_id, m.display_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	judges := make([]Stats, 0)
+	for rows.Next() {
+		s, err := scanStats(rows)
+		if err != nil {
+			return nil, err
+		}
+		judges = append(judges, *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(judges, func(i, j int) bool {
+		if judges[i].Reliability != judges[j].Reliability {
+			return judges[i].Reliability > judges[j].Reliability
+		}
+		return judges[i].JudgeID < judges[j].JudgeID
+	})
+	if limit > 0 && len(judges) > limit {
+		judges = judges[:limit]
+	}
+	for i := range judges {
+		judges[i].Rank = i + 1
+	}
+
+	return judges, nil
+}
+
+const statsQuery = `
+	SELECT
+		je.judge_id, COALESCE(m.display_name, je.judge_id),
+		COUNT(CASE WHEN je.reference = 'consensus' THEN 1 END),
+		COALESCE(SUM(CASE WHEN je.reference = 'consensus' THEN je.agreement END), 0),
+		COUNT(CASE WHEN je.reference = 'user' THEN 1 END),
+		COALESCE(SUM(CASE WHEN je.reference = 'user' THEN je.agreement END), 0)
+	FROM judge_evaluations je
+	LEFT JOIN models m ON m.id = je.judge_id
+`
+
+func (t *Tracker) getStats(judgeID string) (*Stats, error) {
+	row := t.db.QueryRow(statsQuery+`WHERE je.judge_id = ? GROUP BY je.judge_id, m.display_name`, judgeID)
+	s, err := scanStats(row)
+	if err == sql.ErrNoRows {
+		return &Stats{JudgeID: judgeID, Reliability: neutralAgreement, Weight: WeightFor(neutralAgreement)}, nil
+	}
+	return s, err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanStats(row scanner) (*Stats, error) {
+	var s Stats
+	if err := row.Scan(&s.JudgeID, &s.DisplayName, &s.SessionsJudged, &s.consensusSum, &s.UserComparisons, &s.userSum); err != nil {
+		return nil, err
+	}
+
+	if s.SessionsJudged > 0 {
+		s.ConsensusAgreement = s.consensusSum / float64(s.SessionsJudged)
+	}
+	if s.UserComparisons > 0 {
+		s.UserAgreement = s.userSum / float64(s.UserComparisons)
+	}
+
+	// Bayesian average of all evidence, with user comparisons weighted higher
+	evidence := float64(s.SessionsJudged) + userEvidenceFactor*float64(s.UserComparisons)
+	agreement := s.consensusSum + userEvidenceFactor*s.userSum
+	s.Reliability = (agreement + neutralAgreement*priorStrength) / (evidence + priorStrength)
+	s.Weight = WeightFor(s.Reliability)
+
+	return &s, nil
+}
+
+// WeightFor maps a reliability score in [0, 1] to a vote weight
+func WeightFor(reliability float64) float64 {
+	return MinWeight + (MaxWeight-MinWeight)*reliability
+}
+
+// Consensus aggregates ballots into a single ranking using a weighted Borda count
+func Consensus(ballots []Ballot) []string {
+	scores := make(map[string]float64)
+	for _, b := range ballots {
+		weight := b.Weight
+		if weight == 0 {
+			weight = 1.0
+		}
+		for i, label := range b.Ranking {
+			scores[label] += weight * float64(len(b.Ranking)-i)
+		}
+	}
+
+	ranking := make([]string, 0, len(scores))
+	for label := range scores {
+		ranking = append(ranking, label)
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if scores[ranking[i]] != scores[ranking[j]] {
+			return scores[ranking[i]] > scores[ranking[j]]
+		}
+		return ranking[i] < ranking[j]
+	})
+
+	return ranking
+}
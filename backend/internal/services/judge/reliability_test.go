@@ -0,0 +1,131 @@
+package judge
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+func TestWeightFor(t *testing.T) {
+	tests := []struct {
+		reliability float64
+		want        float64
+	}{
+		{0, MinWeight},
+		{0.5, 1},
+		{1, MaxWeight},
+	}
+	for _, tt := range tests {
+		if got := WeightFor(tt.reliability); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("WeightFor(%v) = %v, want %v", tt.reliability, got, tt.want)
+		}
+	}
+}
+
+func TestConsensus(t *testing.T) {
+	tests := []struct {
+		name    string
+		ballots []Ballot
+		want    []string
+	}{
+		{"majority", []Ballot{
+			{Ranking: []string{"A", "B", "C"}},
+			{Ranking: []string{"A", "C", "B"}},
+			{Ranking: []string{"B", "A", "C"}},
+		}, []string{"A", "B", "C"}},
+		{"weighted", []Ballot{
+			{Ranking: []string{"A", "B"}, Weight: 0.5},
+			{Ranking: []string{"B", "A"}, Weight: 1.5},
+		}, []string{"B", "A"}},
+		{"ties by label", []Ballot{
+			{Ranking: []string{"B", "A"}},
+			{Ranking: []string{"A", "B"}},
+		}, []string{"A", "B"}},
+		{"no ballots", nil, []string{}},
+	}
+	for _, tt := range tests {
+		if got := Consensus(tt.ballots); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Consensus = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	for _, id := range []string{"j1", "j2", "j3"} {
+		if _, err := db.Exec(`INSERT INTO models (id, display_name, provider) VALUES (?, ?, 'test')`, id, "Judge "+id); err != nil {
+			t.Fatalf("insert model %s: %v", id, err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO sessions (id, user_id, question, mode, status) VALUES ('s1', 'u1', 'Q', 'standard', 'completed')`); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+
+	tracker := NewTracker(db)
+	if got := tracker.Weight("j1"); got != WeightFor(neutralAgreement) {
+		t.Errorf("weight of an unevaluated judge = %v, want %v", got, WeightFor(neutralAgreement))
+	}
+
+	ballots := []Ballot{
+		{JudgeID: "j1", Ranking: []string{"A", "B", "C"}},
+		{JudgeID: "j2", Ranking: []string{"A", "B", "C"}},
+		{JudgeID: "j3", Ranking: []string{"C", "B", "A"}},
+	}
+	if err := tracker.RecordConsensus("s1", ballots); err != nil {
+		t.Fatalf("RecordConsensus: %v", err)
+	}
+
+	// One session of full (j1, j2) or no (j3) agreement, pulled towards
+	// neutral by the prior
+	agreeing := (1 + neutralAgreement*priorStrength) / (1 + priorStrength)
+	dissenting := (0 + neutralAgreement*priorStrength) / (1 + priorStrength)
+	checkLeaderboard(t, tracker, "after consensus", []string{"j1", "j2", "j3"}, []float64{agreeing, agreeing, dissenting})
+	if got := tracker.Weight("j3"); math.Abs(got-WeightFor(dissenting)) > 1e-9 {
+		t.Errorf("weight of j3 = %v, want %v", got, WeightFor(dissenting))
+	}
+
+	// The user sides with the dissenter, which counts for more than the
+	// consensus did: j3 now ranks above j1
+	for _, b := range []Ballot{ballots[0], ballots[2]} {
+		if _, err := db.Exec(`
+			INSERT INTO votes (session_id, voter_type, voter_id, ranked_responses) VALUES ('s1', 'model', ?, ?)
+		`, b.JudgeID, `["`+b.Ranking[0]+`","`+b.Ranking[1]+`","`+b.Ranking[2]+`"]`); err != nil {
+			t.Fatalf("insert vote: %v", err)
+		}
+	}
+	if err := tracker.RecordUserVote("s1", []string{"C", "B", "A"}); err != nil {
+		t.Fatalf("RecordUserVote: %v", err)
+	}
+
+	evidence := 1 + userEvidenceFactor + priorStrength
+	checkLeaderboard(t, tracker, "after user vote", []string{"j2", "j3", "j1"}, []float64{
+		agreeing,
+		(userEvidenceFactor + neutralAgreement*priorStrength) / evidence,
+		(1 + neutralAgreement*priorStrength) / evidence,
+	})
+}
+
+func checkLeaderboard(t *testing.T, tracker *Tracker, name string, judges []string, reliabilities []float64) {
+	t.Helper()
+	board, err := tracker.Leaderboard(0)
+	if err != nil {
+		t.Fatalf("%s: Leaderboard: %v", name, err)
+	}
+	if len(board) != len(judges) {
+		t.Fatalf("%s: Leaderboard = %+v, want %v", name, board, judges)
+	}
+	for i, s := range board {
+		if s.Rank != i+1 || s.JudgeID != judges[i] || math.Abs(s.Reliability-reliabilities[i]) > 1e-9 {
+			t.Errorf("%s: #%d = %s at %v, want %s at %v", name, s.Rank, s.JudgeID, s.Reliability, judges[i], reliabilities[i])
+		}
+	}
+}
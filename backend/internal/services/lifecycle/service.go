@@ -0,0 +1,126 @@
+package lifecycle
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/sainaif/council/internal/database"
+	"github.com/sainaif/council/internal/services/elo"
+)
+
+// leaseName is the job lease that lets one instance run maintenance
+const leaseName = "model-lifecycle"
+
+// Service retires models that Copilot no longer offers and decays the
+// ratings of models without recent games
+type Service struct {
+	db              *database.DB
+	leases          *database.Leases
+	elo             *elo.Calculator
+	retireAfterDays int
+	interval        time.Duration
+	shutdown        chan struct{}
+	done            chan struct{}
+}
+
+// NewService creates a new model lifecycle service. When instances share
+// the database, only the one holding the maintenance lease runs it.
+func NewService(db *database.DB, leases *database.Leases, elo *elo.Calculator, retireAfterDays int) *Service {
+	return &Service{
+		db:              db,
+		leases:          leases,
+		elo:             elo,
+		retireAfterDays: retireAfterDays,
+		interval:        time.Hour,
+		shutdown:        make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+}
+
+// Run performs maintenance immediately and then once per interval until Shutdown
+func (s *Service) Run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		// The lease outlives a tick so the holder keeps it while running
+		if held, err := s.leases.Acquire(leaseName, s.interval+database.LeaseGrace); err != nil {
+			log.Printf("[LIFECYCLE] ERROR: Failed to acquire maintenance lease: %v", err)
+		} else if held {
+			s.runOnce(time.Now())
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.shutdown:
+			// Let another instance take over without waiting for expiry
+			if err := s.leases.Release(leaseName); err != nil {
+				log.Printf("[LIFECYCLE] ERROR: Failed to release lease: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// Shutdown stops the maintenance loop and waits for it to exit
+func (s *Service) Shutdown() {
+	close(s.shutdown)
+	<-s.done
+}
+
+func (s *Service) runOnce(now time.Time) {
+	retired, err := s.RetireUnseenModels(now)
+	if err != nil {
+		log.Printf("[LIFECYCLE] ERROR: Failed to retire unseen models: %v", err)
+	} else if retired > 0 {
+		log.Printf("[LIFECYCLE] Marked %d models inactive", retired)
+	}
+
+	decayed, err := s.elo.ApplyDecay(now)
+	if err != nil {
+		log.Printf("[LIFECYCLE] ERROR: Failed to apply rating decay: %v", err)
+	} else if decayed > 0 {
+		log.Printf("[LIFECYCLE] Decayed %d inactive ratings", decayed)
+	}
+}
+
+// MarkSeen records that Copilot currently offers the given models,
+// reactivating any that had been retired
+func (s *Service) MarkSeen(modelIDs []string) error {
+	if len(modelIDs) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(modelIDs))
+	for i, id := range modelIDs {
+		args[i] = id
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE models SET last_seen_at = CURRENT_TIMESTAMP, is_active = TRUE, updated_at = CURRENT_TIMESTAMP
+		WHERE id IN (?`+strings.Repeat(", ?", len(modelIDs)-1)+`)
+	`, args...)
+	return err
+}
+
+// RetireUnseenModels marks models inactive once they have been missing from
+// Copilot's model list for the configured number of days
+func (s *Service) RetireUnseenModels(now time.Time) (int64, error) {
+	if s.retireAfterDays <= 0 {
+		return 0, nil
+	}
+
+	cutoff := now.UTC().AddDate(0, 0, -s.retireAfterDays).Format("2006-01-02 15:04:05")
+	result, err := s.db.Exec(`
+		UPDATE models SET is_active = FALSE, updated_at = CURRENT_TIMESTAMP
+		WHERE is_active = TRUE AND last_seen_at < ?
+	`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
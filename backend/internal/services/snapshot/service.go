@@ -0,0 +1,88 @@
+package snapshot
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/sainaif/council/internal/database"
+	"github.com/sainaif/council/internal/store"
+)
+
+// leaseName is the job lease that lets one instance take snapshots
+const leaseName = "leaderboard-snapshot"
+
+// Service keeps a daily copy of the leaderboard. Today's snapshot is refreshed
+// on every tick, so once the day is over it holds the end-of-day ratings.
+type Service struct {
+	db       *database.DB
+	leases   *database.Leases
+	interval time.Duration
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+// NewService creates a new snapshot service. When instances share the
+// database, only the one holding the snapshot lease takes snapshots.
+func NewService(db *database.DB, leases *database.Leases) *Service {
+	return &Service{
+		db:       db,
+		leases:   leases,
+		interval: time.Hour,
+		shutdown: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Run takes a snapshot immediately and then once per interval until Shutdown
+func (s *Service) Run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		// The lease outlives a tick so the holder keeps it while running
+		if held, err := s.leases.Acquire(leaseName, s.interval+database.LeaseGrace); err != nil {
+			log.Printf("[SNAPSHOT] ERROR: Failed to acquire snapshot lease: %v", err)
+		} else if held {
+			if err := s.Take(time.Now()); err != nil {
+				log.Printf("[SNAPSHOT] ERROR: Failed to take leaderboard snapshot: %v", err)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.shutdown:
+			// Let another instance take over without waiting for expiry
+			if err := s.leases.Release(leaseName); err != nil {
+				log.Printf("[SNAPSHOT] ERROR: Failed to release lease: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// Shutdown stops the snapshot loop and waits for it to exit
+func (s *Service) Shutdown() {
+	close(s.shutdown)
+	<-s.done
+}
+
+// Take replaces the snapshot for the given day with the current ratings
+func (s *Service) Take(day time.Time) error {
+	date := day.UTC().Format(store.DateFormat)
+
+	return s.db.WithTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM leaderboard_snapshots WHERE snapshot_date = ?`, date); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO leaderboard_snapshots (snapshot_date, model_id, category_id, rating, wins, losses, draws)
+			SELECT ?, model_id, category_id, rating, wins, losses, draws
+			FROM model_ratings
+		`, date)
+		return err
+	})
+}
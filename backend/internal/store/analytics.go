@@ -0,0 +1,422 @@
+package store
+
+import (
+	"database/sql"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+// Overview summarizes a user's councils
+type Overview struct {
+	TotalSessions    int     `json:"total_sessions"`
+	CompletedCount   int     `json:"completed_count"`
+	AverageModels    float64 `json:"average_models_per_session"`
+	MostUsedModel    string  `json:"most_used_model"`
+	TopPerformer     string  `json:"top_performer"`
+	TotalVotes       int     `json:"total_votes"`
+	SessionsToday    int     `json:"sessions_today"`
+	SessionsThisWeek int     `json:"sessions_this_week"`
+}
+
+// ModelTrend is an active model's rating and its change over the past week
+type ModelTrend struct {
+	ModelID     string  `json:"model_id"`
+	DisplayName string  `json:"display_name"`
+	Rating      int     `json:"rating"`
+	Trend7d     int     `json:"trend_7d"`
+	WinRate     float64 `json:"win_rate"`
+}
+
+// CategoryShare counts a user's sessions in a category
+type CategoryShare struct {
+	CategoryID   int    `json:"category_id"`
+	CategoryName string `json:"category_name"`
+	SessionCount int    `json:"session_count"`
+}
+
+// ModelPreference counts how often a user ranked a model's response first
+type ModelPreference struct {
+	ModelID       string  `json:"model_id"`
+	DisplayName   string  `json:"display_name"`
+	TimesVotedFor int     `json:"times_voted_for"`
+	TotalVotes    int     `json:"total_votes"`
+	Preference    float64 `json:"preference_rate"`
+}
+
+// AgreementSummary averages the agreement of voters across a user's sessions
+type AgreementSummary struct {
+	Sessions     int     `json:"sessions"`
+	MeanKendallW float64 `json:"mean_kendall_w"`
+}
+
+// AgreementPair is the mean agreement of two voters across a user's sessions
+type AgreementPair struct {
+	VoterA   string  `json:"voter_a"`
+	VoterB   string  `json:"voter_b"`
+	MeanTau  float64 `json:"mean_tau"`
+	Sessions int     `json:"sessions"`
+}
+
+// CostSummary totals the tokens spent on a user's councils
+type CostSummary struct {
+	TotalTokens      int     `json:"total_tokens"`
+	TotalSessions    int     `json:"total_sessions"`
+	AvgTokensSession float64 `json:"avg_tokens_per_session"`
+	TokensToday      int     `json:"tokens_today"`
+	TokensThisWeek   int     `json:"tokens_this_week"`
+	TokensThisMonth  int     `json:"tokens_this_month"`
+}
+
+// ModelUsage totals the tokens a model spent on a user's councils
+type ModelUsage struct {
+	ModelID     string `json:"model_id"`
+	DisplayName string `json:"display_name"`
+	TokenCount  int    `json:"token_count"`
+	Requests    int    `json:"requests"`
+}
+
+// DailyUsage totals a user's councils on one day
+type DailyUsage struct {
+	Date       string `json:"date"`
+	TokenCount int    `json:"token_count"`
+	Sessions   int    `json:"sessions"`
+}
+
+// AnalyticsStore aggregates a user's councils
+type AnalyticsStore interface {
+	// Overview summarizes the user's sessions and votes
+	Overview(userID string) (Overview, error)
+	// ModelTrends returns the highest rated active models
+	ModelTrends(limit int) ([]ModelTrend, error)
+	// CategoryDistribution counts the user's sessions per category
+	CategoryDistribution(userID string) ([]CategoryShare, error)
+	// Preferences counts the user's first-place votes per active model,
+	// most preferred first
+	Preferences(userID string) ([]ModelPreference, error)
+	// AgreementSummary averages voter agreement over the user's sessions
+	AgreementSummary(userID string) (AgreementSummary, error)
+	// AgreementPairs returns the mean agreement of each pair of voters in
+	// the user's sessions
+	AgreementPairs(userID string) ([]AgreementPair, error)
+	// Costs totals the tokens spent on the user's councils
+	Costs(userID string) (CostSummary, error)
+	// UsageByModel totals the tokens per model, highest first
+	UsageByModel(userID string) ([]ModelUsage, error)
+	// DailyUsage totals tokens and sessions per day over the past days,
+	// latest first
+	DailyUsage(userID string, days int) ([]DailyUsage, error)
+}
+
+type analyticsStore struct {
+	db            *database.DB
+	initialRating int
+}
+
+// NewAnalyticsStore creates an analytics store backed by the database.
+// Models without a rating are ranked at the initial rating.
+func NewAnalyticsStore(db *database.DB, initialRating int) AnalyticsStore {
+	return &analyticsStore{db: db, initialRating: initialRating}
+}
+
+func (s *analyticsStore) Overview(userID string) (Overview, error) {
+	var overview Overview
+
+	// Session counts
+	if err := s.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN created_at >= ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN created_at > ? THEN 1 ELSE 0 END), 0)
+		FROM sessions WHERE user_id = ?
+	`, startOfToday(), daysAgo(7), userID).Scan(
+		&overview.TotalSessions, &overview.CompletedCount,
+		&overview.SessionsToday, &overview.SessionsThisWeek,
+	); err != nil {
+		return Overview{}, err
+	}
+
+	// Average models per session
+	if err := s.db.QueryRow(`
+		SELECT COALESCE(AVG(model_count), 0) FROM (
+			SELECT session_id, COUNT(DISTINCT model_id) as model_count
+			FROM responses r
+			JOIN sessions s ON r.session_id = s.id
+			WHERE s.user_id = ?
+			GROUP BY session_id
+		) counts
+	`, userID).Scan(&overview.AverageModels); err != nil {
+		return Overview{}, err
+	}
+
+	// Most used model
+	var mostUsed sql.NullString
+	err := s.db.QueryRow(`
+		SELECT model_id FROM responses r
+		JOIN sessions s ON r.session_id = s.id
+		WHERE s.user_id = ?
+		GROUP BY model_id
+		ORDER BY COUNT(*) DESC
+		LIMIT 1
+	`, userID).Scan(&mostUsed)
+	if err != nil && err != sql.ErrNoRows {
+		return Overview{}, err
+	}
+	overview.MostUsedModel = mostUsed.String
+
+	// Top performer (highest ELO)
+	var topPerformer sql.NullString
+	err = s.db.QueryRow(`
+		SELECT m.id FROM models m
+		LEFT JOIN model_ratings mr ON m.id = mr.model_id
+		GROUP BY m.id
+		ORDER BY COALESCE(AVG(mr.rating), ?) DESC
+		LIMIT 1
+	`, s.initialRating).Scan(&topPerformer)
+	if err != nil && err != sql.ErrNoRows {
+		return Overview{}, err
+	}
+	overview.TopPerformer = topPerformer.String
+
+	// Total votes by user
+	if err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM votes WHERE voter_type = 'user' AND voter_id = ?
+	`, userID).Scan(&overview.TotalVotes); err != nil {
+		return Overview{}, err
+	}
+
+	return overview, nil
+}
+
+func (s *analyticsStore) ModelTrends(limit int) ([]ModelTrend, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			m.id, m.display_name,
+			COALESCE(AVG(mr.rating), ?) as rating,
+			COALESCE(SUM(mr.wins), 0) as wins,
+			COALESCE(SUM(mr.losses), 0) as losses
+		FROM models m
+		LEFT JOIN model_ratings mr ON m.id = mr.model_id
+		WHERE m.is_active = TRUE
+		GROUP BY m.id
+		ORDER BY rating DESC
+		LIMIT ?
+	`, s.initialRating, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var trends []ModelTrend
+	for rows.Next() {
+		var t ModelTrend
+		var wins, losses int
+		var rating float64
+		if err := rows.Scan(&t.ModelID, &t.DisplayName, &rating, &wins, &losses); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		t.Rating = int(rating)
+		if wins+losses > 0 {
+			t.WinRate = float64(wins) / float64(wins+losses)
+		}
+		trends = append(trends, t)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Release the connection before querying trends (the pool holds a single connection)
+	since := daysAgo(7)
+	for i := range trends {
+		var trend sql.NullInt64
+		if err := s.db.QueryRow(`
+			SELECT SUM(change) FROM elo_history
+			WHERE model_id = ? AND created_at > ?
+		`, trends[i].ModelID, since).Scan(&trend); err != nil {
+			return nil, err
+		}
+		trends[i].Trend7d = int(trend.Int64)
+	}
+
+	return trends, nil
+}
+
+func (s *analyticsStore) CategoryDistribution(userID string) ([]CategoryShare, error) {
+	rows, err := s.db.Query(`
+		SELECT c.id, c.name, COUNT(s.id) as session_count
+		FROM categories c
+		LEFT JOIN sessions s ON c.id = s.category_id AND s.user_id = ?
+		GROUP BY c.id
+		ORDER BY session_count DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var shares []CategoryShare
+	for rows.Next() {
+		var cs CategoryShare
+		if err := rows.Scan(&cs.CategoryID, &cs.CategoryName, &cs.SessionCount); err != nil {
+			return nil, err
+		}
+		shares = append(shares, cs)
+	}
+	return shares, rows.Err()
+}
+
+func (s *analyticsStore) Preferences(userID string) ([]ModelPreference, error) {
+	rows, err := s.db.Query(`
+		WITH user_votes AS (
+			SELECT ranked_responses FROM votes
+			WHERE voter_type = 'user' AND voter_id = ?
+		),
+		vote_counts AS (
+			SELECT
+				r.model_id,
+				COUNT(*) as times_voted_for
+			FROM user_votes uv, responses r
+			WHERE r.anonymous_label = (
+				SELECT `+s.db.Dialect.JSONIndex("uv.ranked_responses", 0)+`
+			)
+			GROUP BY r.model_id
+		)
+		SELECT
+			m.id, m.display_name,
+			COALESCE(vc.times_voted_for, 0) as times_voted_for,
+			(SELECT COUNT(*) FROM user_votes) as total
+		FROM models m
+		LEFT JOIN vote_counts vc ON m.id = vc.model_id
+		WHERE m.is_active = TRUE
+		ORDER BY times_voted_for DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var preferences []ModelPreference
+	for rows.Next() {
+		var p ModelPreference
+		if err := rows.Scan(&p.ModelID, &p.DisplayName, &p.TimesVotedFor, &p.TotalVotes); err != nil {
+			return nil, err
+		}
+		if p.TotalVotes > 0 {
+			p.Preference = float64(p.TimesVotedFor) / float64(p.TotalVotes)
+		}
+		preferences = append(preferences, p)
+	}
+	return preferences, rows.Err()
+}
+
+func (s *analyticsStore) AgreementSummary(userID string) (AgreementSummary, error) {
+	var summary AgreementSummary
+	err := s.db.QueryRow(`
+		SELECT COUNT(agreement_w), COALESCE(AVG(agreement_w), 0)
+		FROM sessions WHERE user_id = ? AND agreement_w IS NOT NULL
+	`, userID).Scan(&summary.Sessions, &summary.MeanKendallW)
+	return summary, err
+}
+
+func (s *analyticsStore) AgreementPairs(userID string) ([]AgreementPair, error) {
+	rows, err := s.db.Query(`
+		SELECT va.voter_a, va.voter_b, AVG(va.tau), COUNT(*)
+		FROM vote_agreements va
+		JOIN sessions s ON va.session_id = s.id
+		WHERE s.user_id = ?
+		GROUP BY va.voter_a, va.voter_b
+		ORDER BY va.voter_a, va.voter_b
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	pairs := make([]AgreementPair, 0)
+	for rows.Next() {
+		var p AgreementPair
+		if err := rows.Scan(&p.VoterA, &p.VoterB, &p.MeanTau, &p.Sessions); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, rows.Err()
+}
+
+func (s *analyticsStore) Costs(userID string) (CostSummary, error) {
+	var summary CostSummary
+	err := s.db.QueryRow(`
+		SELECT
+			COALESCE(SUM(r.token_count), 0),
+			COUNT(DISTINCT r.session_id),
+			COALESCE(SUM(CASE WHEN s.created_at >= ? THEN r.token_count ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN s.created_at > ? THEN r.token_count ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN s.created_at > ? THEN r.token_count ELSE 0 END), 0)
+		FROM responses r
+		JOIN sessions s ON r.session_id = s.id
+		WHERE s.user_id = ?
+	`, startOfToday(), daysAgo(7), daysAgo(30), userID).Scan(
+		&summary.TotalTokens, &summary.TotalSessions,
+		&summary.TokensToday, &summary.TokensThisWeek, &summary.TokensThisMonth,
+	)
+	if err != nil {
+		return CostSummary{}, err
+	}
+
+	if summary.TotalSessions > 0 {
+		summary.AvgTokensSession = float64(summary.TotalTokens) / float64(summary.TotalSessions)
+	}
+	return summary, nil
+}
+
+func (s *analyticsStore) UsageByModel(userID string) ([]ModelUsage, error) {
+	rows, err := s.db.Query(`
+		SELECT r.model_id, m.display_name, COALESCE(SUM(r.token_count), 0), COUNT(*)
+		FROM responses r
+		JOIN sessions s ON r.session_id = s.id
+		JOIN models m ON r.model_id = m.id
+		WHERE s.user_id = ?
+		GROUP BY r.model_id, m.display_name
+		ORDER BY SUM(r.token_count) DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var usage []ModelUsage
+	for rows.Next() {
+		var mu ModelUsage
+		if err := rows.Scan(&mu.ModelID, &mu.DisplayName, &mu.TokenCount, &mu.Requests); err != nil {
+			return nil, err
+		}
+		usage = append(usage, mu)
+	}
+	return usage, rows.Err()
+}
+
+func (s *analyticsStore) DailyUsage(userID string, days int) ([]DailyUsage, error) {
+	rows, err := s.db.Query(`
+		SELECT `+s.db.Dialect.Day("s.created_at")+` as day, COALESCE(SUM(r.token_count), 0), COUNT(DISTINCT s.id)
+		FROM sessions s
+		LEFT JOIN responses r ON s.id = r.session_id
+		WHERE s.user_id = ? AND s.created_at > ?
+		GROUP BY day
+		ORDER BY day DESC
+	`, userID, daysAgo(days))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var usage []DailyUsage
+	for rows.Next() {
+		var du DailyUsage
+		if err := rows.Scan(&du.Date, &du.TokenCount, &du.Sessions); err != nil {
+			return nil, err
+		}
+		usage = append(usage, du)
+	}
+	return usage, rows.Err()
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+func TestAnalyticsStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, db *database.DB) {
+		analytics := NewAnalyticsStore(db, testInitialRating)
+		sessions := NewSessionStore(db)
+		seedModels(t, db, "m1", "m2")
+		coding := categoryID(t, db, "coding")
+
+		seedSession(t, db, "s1", "u1", "First", &coding)
+		seedSession(t, db, "s2", "u1", "Second", nil)
+		seedSession(t, db, "s3", "u2", "Someone else's", &coding)
+		if _, err := sessions.Complete("s1", "completed"); err != nil {
+			t.Fatalf("Complete: %v", err)
+		}
+		responses := []*Response{
+			{SessionID: "s1", ModelID: "m1", Round: 1, Content: "a", AnonymousLabel: "Response A", TokenCount: 100},
+			{SessionID: "s1", ModelID: "m2", Round: 1, Content: "b", AnonymousLabel: "Response B", TokenCount: 50},
+			{SessionID: "s2", ModelID: "m1", Round: 1, Content: "c", AnonymousLabel: "Response A", TokenCount: 30},
+			{SessionID: "s3", ModelID: "m2", Round: 1, Content: "d", AnonymousLabel: "Response A", TokenCount: 999},
+		}
+		for _, r := range responses {
+			if err := sessions.AddResponse(r); err != nil {
+				t.Fatalf("AddResponse: %v", err)
+			}
+		}
+		if err := sessions.AddVote(&Vote{SessionID: "s1", VoterType: "user", VoterID: "u1", RankedResponses: []string{"Response B", "Response A"}, Weight: 1}); err != nil {
+			t.Fatalf("AddVote: %v", err)
+		}
+		mustExec(t, db, `INSERT INTO model_ratings (model_id, category_id, rating, wins, losses) VALUES (?, ?, ?, ?, ?)`, "m2", coding, 1600, 3, 1)
+
+		t.Run("Overview", func(t *testing.T) {
+			overview, err := analytics.Overview("u1")
+			if err != nil {
+				t.Fatalf("Overview: %v", err)
+			}
+			want := Overview{
+				TotalSessions:    2,
+				CompletedCount:   1,
+				AverageModels:    1.5,
+				MostUsedModel:    "m1",
+				TopPerformer:     "m2",
+				TotalVotes:       1,
+				SessionsToday:    2,
+				SessionsThisWeek: 2,
+			}
+			if overview != want {
+				t.Errorf("Overview = %+v, want %+v", overview, want)
+			}
+		})
+
+		t.Run("ModelTrends", func(t *testing.T) {
+			trends, err := analytics.ModelTrends(10)
+			if err != nil {
+				t.Fatalf("ModelTrends: %v", err)
+			}
+			want := []ModelTrend{
+				{ModelID: "m2", DisplayName: "Model m2", Rating: 1600, WinRate: 0.75},
+				{ModelID: "m1", DisplayName: "Model m1", Rating: testInitialRating},
+			}
+			if len(trends) != len(want) {
+				t.Fatalf("ModelTrends = %+v, want %+v", trends, want)
+			}
+			for i := range want {
+				if trends[i] != want[i] {
+					t.Errorf("ModelTrends[%d] = %+v, want %+v", i, trends[i], want[i])
+				}
+			}
+		})
+
+		t.Run("CategoryDistribution", func(t *testing.T) {
+			shares, err := analytics.CategoryDistribution("u1")
+			if err != nil {
+				t.Fatalf("CategoryDistribution: %v", err)
+			}
+			for _, s := range shares {
+				want := 0
+				if int64(s.CategoryID) == coding {
+					want = 1
+				}
+				if s.SessionCount != want {
+					t.Errorf("sessions in %s = %d, want %d", s.CategoryName, s.SessionCount, want)
+				}
+			}
+		})
+
+		t.Run("Preferences", func(t *testing.T) {
+			preferences, err := analytics.Preferences("u1")
+			if err != nil {
+				t.Fatalf("Preferences: %v", err)
+			}
+			if len(preferences) == 0 || preferences[0].ModelID != "m2" || preferences[0].TimesVotedFor != 1 || preferences[0].TotalVotes != 1 {
+				t.Errorf("Preferences = %+v, want m2 first with 1 of 1 votes", preferences)
+			}
+		})
+
+		t.Run("Costs", func(t *testing.T) {
+			tests := []struct {
+				userID string
+				want   CostSummary
+			}{
+				{"u1", CostSummary{TotalTokens: 180, TotalSessions: 2, AvgTokensSession: 90, TokensToday: 180, TokensThisWeek: 180, TokensThisMonth: 180}},
+				{"nobody", CostSummary{}},
+			}
+			for _, tt := range tests {
+				costs, err := analytics.Costs(tt.userID)
+				if err != nil {
+					t.Fatalf("Costs(%s): %v", tt.userID, err)
+				}
+				if costs != tt.want {
+					t.Errorf("Costs(%s) = %+v, want %+v", tt.userID, costs, tt.want)
+				}
+			}
+
+			usage, err := analytics.UsageByModel("u1")
+			if err != nil {
+				t.Fatalf("UsageByModel: %v", err)
+			}
+			if len(usage) != 2 || usage[0].ModelID != "m1" || usage[0].TokenCount != 130 || usage[0].Requests != 2 {
+				t.Errorf("UsageByModel = %+v, want m1 first with 130 tokens over 2 requests", usage)
+			}
+
+			daily, err := analytics.DailyUsage("u1", 7)
+			if err != nil {
+				t.Fatalf("DailyUsage: %v", err)
+			}
+			if len(daily) != 1 || daily[0].TokenCount != 180 || daily[0].Sessions != 2 {
+				t.Errorf("DailyUsage = %+v, want one day with 180 tokens over 2 sessions", daily)
+			}
+		})
+	})
+}
@@ -0,0 +1,284 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+// Category groups sessions and ratings by topic
+type Category struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// CategoryUpdate changes the fields that are set and keeps the rest
+type CategoryUpdate struct {
+	Name        *string
+	Description *string
+	Archived    *bool
+}
+
+// CategoryStore keeps the categories sessions and ratings are filed under
+type CategoryStore interface {
+	// List returns categories by ID, archived ones only with includeArchived
+	List(includeArchived bool) ([]Category, error)
+	// Get returns a category
+	Get(id int64) (*Category, error)
+	// IDByName returns the ID of the named category
+	IDByName(name string) (int64, error)
+	// Archived reports whether a category is archived
+	Archived(id int64) (bool, error)
+	// NameTaken reports whether a category other than exceptID has the name
+	NameTaken(name string, exceptID int64) (bool, error)
+	// Create adds a category and returns its ID
+	Create(name, description string) (int64, error)
+	// Update applies changes to a category
+	Update(id int64, update CategoryUpdate) error
+	// InUse reports whether sessions or ratings refer to a category
+	InUse(id int64) (bool, error)
+	// Delete removes a category
+	Delete(id int64) error
+	// Merge folds a category into another one. Ratings, snapshots and
+	// head-to-head records of the same model are combined (game counts
+	// summed, ratings averaged by games played), history and sessions move
+	// over, and the merged category is removed.
+	Merge(from, into int64) error
+}
+
+type categoryStore struct {
+	db *database.DB
+}
+
+// NewCategoryStore creates a category store backed by the database
+func NewCategoryStore(db *database.DB) CategoryStore {
+	return &categoryStore{db: db}
+}
+
+func (s *categoryStore) List(includeArchived bool) ([]Category, error) {
+	query := `SELECT id, name, COALESCE(description, ''), archived_at, created_at FROM categories`
+	if !includeArchived {
+		query += ` WHERE archived_at IS NULL`
+	}
+
+	rows, err := s.db.Query(query + ` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	categories := make([]Category, 0)
+	for rows.Next() {
+		var cat Category
+		var archivedAt sql.NullTime
+		if err := rows.Scan(&cat.ID, &cat.Name, &cat.Description, &archivedAt, &cat.CreatedAt); err != nil {
+			return nil, err
+		}
+		if archivedAt.Valid {
+			cat.ArchivedAt = &archivedAt.Time
+		}
+		categories = append(categories, cat)
+	}
+	return categories, rows.Err()
+}
+
+func (s *categoryStore) Get(id int64) (*Category, error) {
+	var cat Category
+	var archivedAt sql.NullTime
+	err := s.db.QueryRow(`
+		SELECT id, name, COALESCE(description, ''), archived_at, created_at FROM categories WHERE id = ?
+	`, id).Scan(&cat.ID, &cat.Name, &cat.Description, &archivedAt, &cat.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if archivedAt.Valid {
+		cat.ArchivedAt = &archivedAt.Time
+	}
+	return &cat, nil
+}
+
+func (s *categoryStore) IDByName(name string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`SELECT id FROM categories WHERE name = ?`, name).Scan(&id)
+	return id, notFound(err)
+}
+
+func (s *categoryStore) Archived(id int64) (bool, error) {
+	var archivedAt sql.NullTime
+	if err := s.db.QueryRow(`SELECT archived_at FROM categories WHERE id = ?`, id).Scan(&archivedAt); err != nil {
+		return false, notFound(err)
+	}
+	return archivedAt.Valid, nil
+}
+
+func (s *categoryStore) NameTaken(name string, exceptID int64) (bool, error) {
+	var taken bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM categories WHERE name = ? AND id != ?)`, name, exceptID).Scan(&taken)
+	return taken, err
+}
+
+func (s *categoryStore) Create(name, description string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`
+		INSERT INTO categories (name, description, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		RETURNING id
+	`, name, description).Scan(&id)
+	return id, err
+}
+
+func (s *categoryStore) Update(id int64, update CategoryUpdate) error {
+	return s.db.WithTx(func(tx *sql.Tx) error {
+		if update.Name != nil {
+			if _, err := tx.Exec(`UPDATE categories SET name = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, *update.Name, id); err != nil {
+				return err
+			}
+		}
+		if update.Description != nil {
+			if _, err := tx.Exec(`UPDATE categories SET description = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, *update.Description, id); err != nil {
+				return err
+			}
+		}
+		if update.Archived != nil {
+			query := `UPDATE categories SET archived_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+			if *update.Archived {
+				query = `UPDATE categories SET archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+			}
+			if _, err := tx.Exec(query, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *categoryStore) InUse(id int64) (bool, error) {
+	var used bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM sessions WHERE category_id = ?)
+			OR EXISTS (SELECT 1 FROM model_ratings WHERE category_id = ?)
+			OR EXISTS (SELECT 1 FROM elo_history WHERE category_id = ?)
+	`, id, id, id).Scan(&used)
+	return used, err
+}
+
+func (s *categoryStore) Delete(id int64) error {
+	result, err := s.db.Exec(`DELETE FROM categories WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *categoryStore) Merge(from, into int64) error {
+	lastGameAt := s.db.Dialect.Greatest("COALESCE(model_ratings.last_game_at, s.last_game_at)", "COALESCE(s.last_game_at, model_ratings.last_game_at)")
+	return s.db.WithTx(func(tx *sql.Tx) error {
+		rated := []struct {
+			table string
+			keys  []string
+			extra string
+		}{
+			{"model_ratings", []string{"model_id"}, `last_game_at = ` + lastGameAt + `,`},
+			{"user_model_ratings", []string{"user_id", "model_id"}, ""},
+			{"leaderboard_snapshots", []string{"snapshot_date", "model_id"}, ""},
+		}
+		for _, t := range rated {
+			if err := mergeRatingRows(tx, t.table, t.keys, t.extra, from, into); err != nil {
+				return err
+			}
+		}
+
+		if err := mergeMatchups(tx, from, into); err != nil {
+			return err
+		}
+
+		for _, table := range []string{"elo_history", "user_elo_history", "matchup_results", "sessions"} {
+			if _, err := tx.Exec(`UPDATE `+table+` SET category_id = ? WHERE category_id = ?`, into, from); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Exec(`DELETE FROM categories WHERE id = ?`, from)
+		return err
+	})
+}
+
+// mergeRatingRows moves rows of a ratings-shaped table from one category to
+// another, combining rows that share the same keys
+func mergeRatingRows(tx *sql.Tx, table string, keys []string, extra string, from, into int64) error {
+	match := make([]string, len(keys))
+	for i, k := range keys {
+		match[i] = table + "." + k + " = s." + k
+	}
+	sameKeys := strings.Join(match, " AND ")
+
+	// Columns of the row being updated must be qualified, the source is the same table
+	t := table + "."
+	_, err := tx.Exec(`
+		UPDATE `+table+` SET
+			`+extra+`
+			rating = CASE
+				WHEN `+t+`wins + `+t+`losses + `+t+`draws + s.wins + s.losses + s.draws = 0 THEN (`+t+`rating + s.rating) / 2
+				ELSE CAST(ROUND((`+t+`rating * (`+t+`wins + `+t+`losses + `+t+`draws) + s.rating * (s.wins + s.losses + s.draws)) * 1.0
+					/ (`+t+`wins + `+t+`losses + `+t+`draws + s.wins + s.losses + s.draws)) AS INTEGER)
+			END,
+			wins = `+t+`wins + s.wins,
+			losses = `+t+`losses + s.losses,
+			draws = `+t+`draws + s.draws
+		FROM `+table+` AS s
+		WHERE s.category_id = ? AND `+t+`category_id = ? AND `+sameKeys,
+		from, into)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM `+table+`
+		WHERE category_id = ? AND EXISTS (
+			SELECT 1 FROM `+table+` AS s WHERE s.category_id = ? AND `+sameKeys+`
+		)`, from, into); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE `+table+` SET category_id = ? WHERE category_id = ?`, into, from)
+	return err
+}
+
+// mergeMatchups combines head-to-head records of the same pair
+func mergeMatchups(tx *sql.Tx, from, into int64) error {
+	if _, err := tx.Exec(`
+		UPDATE matchups SET
+			model_a_wins = matchups.model_a_wins + s.model_a_wins,
+			model_b_wins = matchups.model_b_wins + s.model_b_wins,
+			draws = matchups.draws + s.draws,
+			updated_at = CURRENT_TIMESTAMP
+		FROM matchups AS s
+		WHERE s.category_id = ? AND matchups.category_id = ?
+		  AND matchups.model_a_id = s.model_a_id AND matchups.model_b_id = s.model_b_id
+	`, from, into); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM matchups
+		WHERE category_id = ? AND EXISTS (
+			SELECT 1 FROM matchups AS s
+			WHERE s.category_id = ? AND s.model_a_id = matchups.model_a_id AND s.model_b_id = matchups.model_b_id
+		)`, from, into); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`UPDATE matchups SET category_id = ? WHERE category_id = ?`, into, from)
+	return err
+}
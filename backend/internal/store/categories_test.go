@@ -0,0 +1,171 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+func TestCategoryStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, db *database.DB) {
+		categories := NewCategoryStore(db)
+
+		t.Run("Lifecycle", func(t *testing.T) {
+			id, err := categories.Create("physics", "Forces and fields")
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			tests := []struct {
+				name     string
+				exceptID int64
+				want     bool
+			}{
+				{"physics", 0, true},
+				{"physics", id, false},
+				{"chemistry", 0, false},
+			}
+			for _, tt := range tests {
+				if got, err := categories.NameTaken(tt.name, tt.exceptID); err != nil || got != tt.want {
+					t.Errorf("NameTaken(%q, %d) = %v, %v, want %v", tt.name, tt.exceptID, got, err, tt.want)
+				}
+			}
+
+			name, archived := "mechanics", true
+			if err := categories.Update(id, CategoryUpdate{Name: &name, Archived: &archived}); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			cat, err := categories.Get(id)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if cat.Name != "mechanics" || cat.Description != "Forces and fields" || cat.ArchivedAt == nil {
+				t.Errorf("updated category = %+v", cat)
+			}
+			if got, err := categories.Archived(id); err != nil || !got {
+				t.Errorf("Archived = %v, %v, want true", got, err)
+			}
+
+			listed := func(includeArchived bool) bool {
+				list, err := categories.List(includeArchived)
+				if err != nil {
+					t.Fatalf("List: %v", err)
+				}
+				for _, c := range list {
+					if c.ID == id {
+						return true
+					}
+				}
+				return false
+			}
+			if listed(false) || !listed(true) {
+				t.Errorf("archived category listed = %v, with archived = %v", listed(false), listed(true))
+			}
+
+			archived = false
+			if err := categories.Update(id, CategoryUpdate{Archived: &archived}); err != nil {
+				t.Fatalf("restore: %v", err)
+			}
+			if !listed(false) {
+				t.Errorf("restored category not listed")
+			}
+
+			if used, err := categories.InUse(id); err != nil || used {
+				t.Errorf("InUse = %v, %v, want false", used, err)
+			}
+			if err := categories.Delete(id); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if err := categories.Delete(id); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Delete again error = %v, want ErrNotFound", err)
+			}
+			if _, err := categories.Get(id); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get deleted error = %v, want ErrNotFound", err)
+			}
+		})
+
+		t.Run("Merge", func(t *testing.T) {
+			seedModels(t, db, "m1", "m2", "m3")
+			from, err := categories.Create("algebra", "")
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			into := categoryID(t, db, "math")
+			seedSession(t, db, "merge-session", "u1", "Solve x", &from)
+
+			rating := `INSERT INTO model_ratings (model_id, category_id, rating, wins, losses, draws, last_game_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+			mustExec(t, db, rating, "m1", from, 1600, 3, 1, 0, "2026-03-01 00:00:00")
+			mustExec(t, db, rating, "m1", into, 1400, 1, 3, 0, "2026-01-01 00:00:00")
+			mustExec(t, db, rating, "m2", from, 1550, 1, 0, 0, "2026-02-01 00:00:00")
+			mustExec(t, db, rating, "m3", into, 1450, 0, 1, 0, "2026-02-01 00:00:00")
+			matchup := `INSERT INTO matchups (model_a_id, model_b_id, category_id, model_a_wins, model_b_wins, draws) VALUES (?, ?, ?, ?, ?, ?)`
+			mustExec(t, db, matchup, "m1", "m2", from, 2, 1, 0)
+			mustExec(t, db, matchup, "m1", "m2", into, 1, 1, 1)
+			mustExec(t, db, `INSERT INTO elo_history (model_id, category_id, old_rating, new_rating, change) VALUES (?, ?, ?, ?, ?)`, "m1", from, 1500, 1600, 100)
+
+			if used, err := categories.InUse(from); err != nil || !used {
+				t.Errorf("InUse before merge = %v, %v, want true", used, err)
+			}
+			if err := categories.Merge(from, into); err != nil {
+				t.Fatalf("Merge: %v", err)
+			}
+
+			tests := []struct {
+				model      string
+				rating     int
+				wins       int
+				losses     int
+				lastGameAt string
+			}{
+				{"m1", 1500, 4, 4, "2026-03-01"}, // Averaged by games, latest game kept
+				{"m2", 1550, 1, 0, "2026-02-01"}, // Moved over
+				{"m3", 1450, 0, 1, "2026-02-01"}, // Untouched
+			}
+			for _, tt := range tests {
+				var rating, wins, losses int
+				var lastGameAt time.Time
+				err := db.QueryRow(`
+					SELECT rating, wins, losses, last_game_at FROM model_ratings WHERE model_id = ? AND category_id = ?
+				`, tt.model, into).Scan(&rating, &wins, &losses, &lastGameAt)
+				if err != nil {
+					t.Fatalf("rating of %s: %v", tt.model, err)
+				}
+				if rating != tt.rating || wins != tt.wins || losses != tt.losses || lastGameAt.Format("2006-01-02") != tt.lastGameAt {
+					t.Errorf("%s = %d %d-%d %s, want %d %d-%d %s", tt.model, rating, wins, losses, lastGameAt.Format("2006-01-02"),
+						tt.rating, tt.wins, tt.losses, tt.lastGameAt)
+				}
+			}
+
+			var aWins, bWins, draws int
+			if err := db.QueryRow(`
+				SELECT model_a_wins, model_b_wins, draws FROM matchups WHERE model_a_id = 'm1' AND model_b_id = 'm2' AND category_id = ?
+			`, into).Scan(&aWins, &bWins, &draws); err != nil {
+				t.Fatalf("matchup: %v", err)
+			}
+			if aWins != 3 || bWins != 2 || draws != 1 {
+				t.Errorf("matchup = %d-%d-%d, want 3-2-1", aWins, bWins, draws)
+			}
+
+			var left int
+			if err := db.QueryRow(`
+				SELECT (SELECT COUNT(*) FROM model_ratings WHERE category_id = ?)
+					+ (SELECT COUNT(*) FROM matchups WHERE category_id = ?)
+					+ (SELECT COUNT(*) FROM elo_history WHERE category_id = ?)
+					+ (SELECT COUNT(*) FROM sessions WHERE category_id = ?)
+			`, from, from, from, from).Scan(&left); err != nil {
+				t.Fatalf("count rows left: %v", err)
+			}
+			if left != 0 {
+				t.Errorf("%d rows left in the merged category", left)
+			}
+			if moved, err := NewSessionStore(db).Category("merge-session"); err != nil || moved == nil || *moved != into {
+				t.Errorf("session category = %v, %v, want %d", moved, err, into)
+			}
+			if _, err := categories.Get(from); !errors.Is(err, ErrNotFound) {
+				t.Errorf("merged category still exists: %v", err)
+			}
+		})
+	})
+}
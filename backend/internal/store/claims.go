@@ -0,0 +1,70 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+func (s *sessionStore) Claim(id, holder string, now, expires time.Time) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE sessions SET claimed_by = ?, claim_expires_at = ?
+		WHERE id = ? AND (claimed_by IS NULL OR claimed_by = ? OR claim_expires_at < ?)
+	`, holder, expires.UTC().Format(timestampFormat), id, holder, now.UTC().Format(timestampFormat))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (s *sessionStore) ReleaseClaim(id, holder string) error {
+	_, err := s.db.Exec(`
+		UPDATE sessions SET claimed_by = NULL, claim_expires_at = NULL
+		WHERE id = ? AND claimed_by = ?
+	`, id, holder)
+	return err
+}
+
+func (s *sessionStore) ClaimHolder(id string, now time.Time) (string, error) {
+	var holder sql.NullString
+	err := s.db.QueryRow(`
+		SELECT claimed_by FROM sessions WHERE id = ? AND claim_expires_at >= ?
+	`, id, now.UTC().Format(timestampFormat)).Scan(&holder)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return holder.String, err
+}
+
+func (s *sessionStore) Orphaned(now time.Time, statuses ...string) ([]string, error) {
+	orphaned := make([]string, 0)
+	if len(statuses) == 0 {
+		return orphaned, nil
+	}
+
+	args := make([]interface{}, 0, len(statuses)+1)
+	for _, status := range statuses {
+		args = append(args, status)
+	}
+	args = append(args, now.UTC().Format(timestampFormat))
+
+	rows, err := s.db.Query(`
+		SELECT id FROM sessions
+		WHERE status IN (?`+strings.Repeat(", ?", len(statuses)-1)+`)
+		  AND claimed_by IS NOT NULL AND claim_expires_at < ?
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		orphaned = append(orphaned, id)
+	}
+	return orphaned, rows.Err()
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+// Model is a model that took part in a council
+type Model struct {
+	ID          string
+	DisplayName string
+	Provider    string
+	IsActive    bool
+}
+
+// ModelStats is a model's record summed over all categories, rated with
+// the average of its category ratings
+type ModelStats struct {
+	Rating int
+	Wins   int
+	Losses int
+	Draws  int
+}
+
+// CategoryStats is a model's record in one category
+type CategoryStats struct {
+	CategoryID   int     `json:"category_id"`
+	CategoryName string  `json:"category_name"`
+	Rating       int     `json:"rating"`
+	Wins         int     `json:"wins"`
+	Losses       int     `json:"losses"`
+	Draws        int     `json:"draws"`
+	WinRate      float64 `json:"win_rate"`
+}
+
+// RatingChange is one entry of a model's rating history
+type RatingChange struct {
+	SessionID  string          `json:"session_id"`
+	CategoryID *int64          `json:"category_id,omitempty"`
+	OldRating  int             `json:"old_rating"`
+	NewRating  int             `json:"new_rating"`
+	Change     int             `json:"change"`
+	Reason     string          `json:"reason"`
+	KFactor    *int64          `json:"k_factor,omitempty"`
+	Params     json.RawMessage `json:"params,omitempty"`
+	CreatedAt  string          `json:"created_at"`
+}
+
+// ModelStore keeps the models seen in councils and their records
+type ModelStore interface {
+	// Register records a model before it takes part in a council, marking
+	// it active again if it was retired
+	Register(id, displayName, provider string) error
+	// Get returns a registered model
+	Get(id string) (*Model, error)
+	// Stats returns a model's overall record
+	Stats(id string) (ModelStats, error)
+	// CategoryStats returns a model's record in every category
+	CategoryStats(id string) ([]CategoryStats, error)
+	// History returns a model's latest rating changes, newest first
+	History(id string, limit int) ([]RatingChange, error)
+}
+
+type modelStore struct {
+	db            *database.DB
+	initialRating int
+}
+
+// NewModelStore creates a model store backed by the database. Models
+// without a rating are reported at the initial rating.
+func NewModelStore(db *database.DB, initialRating int) ModelStore {
+	return &modelStore{db: db, initialRating: initialRating}
+}
+
+func (s *modelStore) Register(id, displayName, provider string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO models (id, display_name, provider, last_seen_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(id) DO UPDATE SET last_seen_at = CURRENT_TIMESTAMP, is_active = TRUE
+	`, id, displayName, provider)
+	return err
+}
+
+func (s *modelStore) Get(id string) (*Model, error) {
+	var m Model
+	err := s.db.QueryRow(`
+		SELECT id, display_name, provider, is_active FROM models WHERE id = ?
+	`, id).Scan(&m.ID, &m.DisplayName, &m.Provider, &m.IsActive)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &m, nil
+}
+
+func (s *modelStore) Stats(id string) (ModelStats, error) {
+	stats := ModelStats{Rating: s.initialRating}
+	var avgRating sql.NullFloat64
+	err := s.db.QueryRow(`
+		SELECT AVG(rating), COALESCE(SUM(wins), 0), COALESCE(SUM(losses), 0), COALESCE(SUM(draws), 0)
+		FROM model_ratings WHERE model_id = ?
+	`, id).Scan(&avgRating, &stats.Wins, &stats.Losses, &stats.Draws)
+	if err != nil {
+		return ModelStats{}, err
+	}
+	if avgRating.Valid {
+		stats.Rating = int(avgRating.Float64)
+	}
+	return stats, nil
+}
+
+func (s *modelStore) CategoryStats(id string) ([]CategoryStats, error) {
+	rows, err := s.db.Query(`
+		SELECT c.id, c.name, COALESCE(mr.rating, ?), COALESCE(mr.wins, 0),
+			   COALESCE(mr.losses, 0), COALESCE(mr.draws, 0)
+		FROM categories c
+		LEFT JOIN model_ratings mr ON c.id = mr.category_id AND mr.model_id = ?
+	`, s.initialRating, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var stats []CategoryStats
+	for rows.Next() {
+		var cs CategoryStats
+		if err := rows.Scan(&cs.CategoryID, &cs.CategoryName, &cs.Rating, &cs.Wins, &cs.Losses, &cs.Draws); err != nil {
+			return nil, err
+		}
+		cs.WinRate = winRate(cs.Wins, cs.Losses, cs.Draws)
+		stats = append(stats, cs)
+	}
+	return stats, rows.Err()
+}
+
+func (s *modelStore) History(id string, limit int) ([]RatingChange, error) {
+	rows, err := s.db.Query(`
+		SELECT session_id, category_id, old_rating, new_rating, change, reason, k_factor, params, created_at
+		FROM elo_history
+		WHERE model_id = ?
+		ORDER BY created_at DESC
+		LIMIT ?
+	`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var history []RatingChange
+	for rows.Next() {
+		var h RatingChange
+		var categoryID, kFactor sql.NullInt64
+		var sessionID, reason, params sql.NullString
+		if err := rows.Scan(&sessionID, &categoryID, &h.OldRating, &h.NewRating, &h.Change, &reason, &kFactor, &params, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		h.SessionID = sessionID.String
+		h.CategoryID = nullInt64(categoryID)
+		h.Reason = reason.String
+		h.KFactor = nullInt64(kFactor)
+		if params.Valid {
+			h.Params = json.RawMessage(params.String)
+		}
+		history = append(history, h)
+	}
+	return history, rows.Err()
+}
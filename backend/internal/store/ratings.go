@@ -0,0 +1,362 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+// Uncategorized names the ratings of sessions without a category
+const Uncategorized = "uncategorized"
+
+// ModelStatus filters leaderboards by whether models are still offered
+type ModelStatus string
+
+const (
+	ModelsActive   ModelStatus = "active"
+	ModelsInactive ModelStatus = "inactive"
+	ModelsAll      ModelStatus = "all"
+)
+
+// statusConditions maps a model status to a models condition
+var statusConditions = map[ModelStatus]string{
+	ModelsActive:   "m.is_active = TRUE",
+	ModelsInactive: "m.is_active = FALSE",
+	ModelsAll:      "1 = 1",
+}
+
+// LeaderboardQuery selects the ratings a leaderboard is built from
+type LeaderboardQuery struct {
+	UserID     string // The user's personal ratings when set, otherwise the instance-wide ones
+	CategoryID *int64 // Ratings in one category, otherwise averaged across categories
+	Status     ModelStatus
+	Limit      int
+}
+
+// Standing is a model's place on a leaderboard
+type Standing struct {
+	ModelID     string
+	DisplayName string
+	Provider    string
+	Rating      int
+	Wins        int
+	Losses      int
+	Draws       int
+	Trend       int // Rating change over the past 7 days, on overall leaderboards
+	IsActive    bool
+}
+
+// HistoryQuery selects rating changes of some models
+type HistoryQuery struct {
+	Models     []string
+	Categories []string // Category names, Uncategorized included; all when empty
+	From       time.Time
+	Until      time.Time // Exclusive
+}
+
+// HistoryPoint is a rating change, with the rating it led to
+type HistoryPoint struct {
+	ModelID    string
+	CategoryID *int64
+	Category   string
+	CreatedAt  time.Time
+	Rating     int
+	Change     int
+}
+
+// Matchup is the head-to-head record of two models
+type Matchup struct {
+	CategoryID   *int64  `json:"category_id,omitempty"`
+	CategoryName *string `json:"category_name,omitempty"`
+	ModelAWins   int     `json:"model_a_wins"`
+	ModelBWins   int     `json:"model_b_wins"`
+	Draws        int     `json:"draws"`
+	TotalGames   int     `json:"total_games"`
+}
+
+// Encounter is a session in which two models met
+type Encounter struct {
+	SessionID    string  `json:"session_id"`
+	Question     string  `json:"question"`
+	CategoryID   *int64  `json:"category_id,omitempty"`
+	CategoryName *string `json:"category_name,omitempty"`
+	WinnerID     *string `json:"winner_id"` // nil for a draw
+	CreatedAt    string  `json:"created_at"`
+}
+
+// RatingStore reads leaderboards and rating history
+type RatingStore interface {
+	// Leaderboard returns models ordered by rating
+	Leaderboard(q LeaderboardQuery) ([]Standing, error)
+	// History returns rating changes ordered by model, category and time
+	History(q HistoryQuery) ([]HistoryPoint, error)
+	// LatestSnapshot returns the date of the last leaderboard snapshot on
+	// or before a date
+	LatestSnapshot(onOrBefore string) (string, error)
+	// SnapshotLeaderboard returns the leaderboard recorded on a date
+	SnapshotLeaderboard(date string, categoryID *int64, limit int) ([]Standing, error)
+	// Matchup returns the overall record of two models, ordered by ID
+	Matchup(modelA, modelB string) (Matchup, error)
+	// MatchupsByCategory returns the record of two models in every category
+	MatchupsByCategory(modelA, modelB string) ([]Matchup, error)
+	// Encounters returns the latest sessions two models met in
+	Encounters(modelA, modelB string, limit int) ([]Encounter, error)
+}
+
+type ratingStore struct {
+	db            *database.DB
+	initialRating int
+}
+
+// NewRatingStore creates a rating store backed by the database. Models
+// without a rating are ranked at the initial rating.
+func NewRatingStore(db *database.DB, initialRating int) RatingStore {
+	return &ratingStore{db: db, initialRating: initialRating}
+}
+
+func (s *ratingStore) Leaderboard(q LeaderboardQuery) ([]Standing, error) {
+	status, ok := statusConditions[q.Status]
+	if !ok {
+		status = statusConditions[ModelsActive]
+	}
+
+	join := `LEFT JOIN model_ratings mr ON m.id = mr.model_id`
+	args := []interface{}{s.initialRating}
+	if q.UserID != "" {
+		join = `LEFT JOIN user_model_ratings mr ON m.id = mr.model_id AND mr.user_id = ?`
+		args = append(args, q.UserID)
+	}
+
+	var query string
+	if q.CategoryID != nil {
+		query = `
+			SELECT
+				m.id, m.display_name, m.provider,
+				COALESCE(mr.rating, ?),
+				COALESCE(mr.wins, 0),
+				COALESCE(mr.losses, 0),
+				COALESCE(mr.draws, 0),
+				m.is_active
+			FROM models m
+			` + join + ` AND mr.category_id = ?
+			WHERE ` + status + `
+			ORDER BY COALESCE(mr.rating, ?) DESC
+			LIMIT ?`
+		args = append(args, *q.CategoryID, s.initialRating)
+	} else {
+		query = `
+			SELECT
+				m.id, m.display_name, m.provider,
+				COALESCE(AVG(mr.rating), ?) as avg_rating,
+				COALESCE(SUM(mr.wins), 0) as wins,
+				COALESCE(SUM(mr.losses), 0) as losses,
+				COALESCE(SUM(mr.draws), 0) as draws,
+				m.is_active
+			FROM models m
+			` + join + `
+			WHERE ` + status + `
+			GROUP BY m.id
+			ORDER BY avg_rating DESC
+			LIMIT ?`
+	}
+	args = append(args, q.Limit)
+
+	standings, err := s.standings(query, args...)
+	if err != nil || q.CategoryID != nil {
+		return standings, err
+	}
+
+	// Trends are queried once the standings are read, as SQLite holds a
+	// single connection
+	trend := `SELECT SUM(change) FROM elo_history WHERE model_id = ? AND created_at > ?`
+	if q.UserID != "" {
+		trend = `SELECT SUM(change) FROM user_elo_history WHERE user_id = ? AND model_id = ? AND created_at > ?`
+	}
+	since := daysAgo(7)
+	for i := range standings {
+		trendArgs := []interface{}{standings[i].ModelID, since}
+		if q.UserID != "" {
+			trendArgs = append([]interface{}{q.UserID}, trendArgs...)
+		}
+		var change sql.NullInt64
+		if err := s.db.QueryRow(trend, trendArgs...).Scan(&change); err != nil {
+			return nil, err
+		}
+		standings[i].Trend = int(change.Int64)
+	}
+	return standings, nil
+}
+
+// standings reads leaderboard rows: the model, its rating, record and
+// whether it is active
+func (s *ratingStore) standings(query string, args ...interface{}) ([]Standing, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var standings []Standing
+	for rows.Next() {
+		var st Standing
+		var rating float64
+		if err := rows.Scan(&st.ModelID, &st.DisplayName, &st.Provider, &rating, &st.Wins, &st.Losses, &st.Draws, &st.IsActive); err != nil {
+			return nil, err
+		}
+		st.Rating = int(rating)
+		standings = append(standings, st)
+	}
+	return standings, rows.Err()
+}
+
+func (s *ratingStore) History(q HistoryQuery) ([]HistoryPoint, error) {
+	if len(q.Models) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT eh.model_id, eh.category_id, COALESCE(c.name, ?), eh.created_at, eh.new_rating, eh.change
+		FROM elo_history eh
+		LEFT JOIN categories c ON eh.category_id = c.id
+		WHERE eh.model_id IN (?` + strings.Repeat(", ?", len(q.Models)-1) + `)
+		  AND eh.created_at >= ? AND eh.created_at < ?`
+	args := []interface{}{Uncategorized}
+	for _, m := range q.Models {
+		args = append(args, m)
+	}
+	args = append(args, q.From.UTC().Format(timestampFormat), q.Until.UTC().Format(timestampFormat))
+
+	if len(q.Categories) > 0 {
+		query += ` AND COALESCE(c.name, ?) IN (?` + strings.Repeat(", ?", len(q.Categories)-1) + `)`
+		args = append(args, Uncategorized)
+		for _, cat := range q.Categories {
+			args = append(args, cat)
+		}
+	}
+	query += ` ORDER BY eh.model_id, eh.category_id, eh.created_at, eh.id`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var points []HistoryPoint
+	for rows.Next() {
+		var p HistoryPoint
+		var categoryID sql.NullInt64
+		if err := rows.Scan(&p.ModelID, &categoryID, &p.Category, &p.CreatedAt, &p.Rating, &p.Change); err != nil {
+			return nil, err
+		}
+		p.CategoryID = nullInt64(categoryID)
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+func (s *ratingStore) LatestSnapshot(onOrBefore string) (string, error) {
+	var date sql.NullString
+	err := s.db.QueryRow(`
+		SELECT `+s.db.Dialect.Day("MAX(snapshot_date)")+` FROM leaderboard_snapshots WHERE snapshot_date <= ?
+	`, onOrBefore).Scan(&date)
+	if err != nil {
+		return "", err
+	}
+	if !date.Valid {
+		return "", ErrNotFound
+	}
+	return date.String, nil
+}
+
+func (s *ratingStore) SnapshotLeaderboard(date string, categoryID *int64, limit int) ([]Standing, error) {
+	// Without a category, ratings are averaged across categories like the live leaderboard
+	query := `
+		SELECT m.id, m.display_name, m.provider,
+			AVG(ls.rating), SUM(ls.wins), SUM(ls.losses), SUM(ls.draws), m.is_active
+		FROM leaderboard_snapshots ls
+		JOIN models m ON ls.model_id = m.id
+		WHERE ls.snapshot_date = ?`
+	args := []interface{}{date}
+	if categoryID != nil {
+		query += ` AND ls.category_id = ?`
+		args = append(args, *categoryID)
+	}
+	query += ` GROUP BY m.id ORDER BY AVG(ls.rating) DESC LIMIT ?`
+	args = append(args, limit)
+
+	return s.standings(query, args...)
+}
+
+func (s *ratingStore) Matchup(modelA, modelB string) (Matchup, error) {
+	var m Matchup
+	err := s.db.QueryRow(`
+		SELECT COALESCE(SUM(model_a_wins), 0), COALESCE(SUM(model_b_wins), 0), COALESCE(SUM(draws), 0)
+		FROM matchups
+		WHERE model_a_id = ? AND model_b_id = ?
+	`, modelA, modelB).Scan(&m.ModelAWins, &m.ModelBWins, &m.Draws)
+	if err != nil {
+		return Matchup{}, err
+	}
+	m.TotalGames = m.ModelAWins + m.ModelBWins + m.Draws
+	return m, nil
+}
+
+func (s *ratingStore) MatchupsByCategory(modelA, modelB string) ([]Matchup, error) {
+	rows, err := s.db.Query(`
+		SELECT c.id, c.name, COALESCE(m.model_a_wins, 0), COALESCE(m.model_b_wins, 0), COALESCE(m.draws, 0)
+		FROM categories c
+		LEFT JOIN matchups m ON c.id = m.category_id AND m.model_a_id = ? AND m.model_b_id = ?
+	`, modelA, modelB)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var matchups []Matchup
+	for rows.Next() {
+		var m Matchup
+		var categoryID int64
+		var categoryName string
+		if err := rows.Scan(&categoryID, &categoryName, &m.ModelAWins, &m.ModelBWins, &m.Draws); err != nil {
+			return nil, err
+		}
+		m.CategoryID = &categoryID
+		m.CategoryName = &categoryName
+		m.TotalGames = m.ModelAWins + m.ModelBWins + m.Draws
+		matchups = append(matchups, m)
+	}
+	return matchups, rows.Err()
+}
+
+func (s *ratingStore) Encounters(modelA, modelB string, limit int) ([]Encounter, error) {
+	rows, err := s.db.Query(`
+		SELECT mr.session_id, s.question, mr.category_id, c.name, mr.winner_id, mr.created_at
+		FROM matchup_results mr
+		JOIN sessions s ON mr.session_id = s.id
+		LEFT JOIN categories c ON mr.category_id = c.id
+		WHERE mr.model_a_id = ? AND mr.model_b_id = ?
+		ORDER BY mr.created_at DESC, mr.id DESC
+		LIMIT ?
+	`, modelA, modelB, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	encounters := make([]Encounter, 0)
+	for rows.Next() {
+		var e Encounter
+		var categoryID sql.NullInt64
+		var categoryName, winnerID sql.NullString
+		if err := rows.Scan(&e.SessionID, &e.Question, &categoryID, &categoryName, &winnerID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.CategoryID = nullInt64(categoryID)
+		e.CategoryName = nullString(categoryName)
+		e.WinnerID = nullString(winnerID)
+		encounters = append(encounters, e)
+	}
+	return encounters, rows.Err()
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+func TestRatingStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, db *database.DB) {
+		ratings := NewRatingStore(db, testInitialRating)
+		seedModels(t, db, "m1", "m2", "m3", "retired")
+		mustExec(t, db, `UPDATE models SET is_active = FALSE WHERE id = 'retired'`)
+		coding := categoryID(t, db, "coding")
+		math := categoryID(t, db, "math")
+
+		rating := `INSERT INTO model_ratings (model_id, category_id, rating, wins, losses, draws) VALUES (?, ?, ?, ?, ?, ?)`
+		mustExec(t, db, rating, "m1", coding, 1600, 3, 1, 0)
+		mustExec(t, db, rating, "m1", math, 1400, 1, 3, 0)
+		mustExec(t, db, rating, "m2", coding, 1700, 5, 0, 1)
+		mustExec(t, db, rating, "retired", nil, 1800, 9, 0, 0)
+		personal := `INSERT INTO user_model_ratings (user_id, model_id, category_id, rating, wins) VALUES (?, ?, ?, ?, ?)`
+		mustExec(t, db, personal, "u1", "m3", coding, 1650, 2)
+		mustExec(t, db, personal, "u1", "m1", coding, 1300, 0)
+		mustExec(t, db, `INSERT INTO elo_history (model_id, category_id, old_rating, new_rating, change, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			"m2", coding, 1680, 1700, 20, time.Now().UTC().Add(-time.Hour).Format(timestampFormat))
+
+		t.Run("Leaderboard", func(t *testing.T) {
+			type row struct {
+				model  string
+				rating int
+				wins   int
+			}
+			tests := []struct {
+				name string
+				q    LeaderboardQuery
+				want []row
+			}{
+				{"global", LeaderboardQuery{Status: ModelsActive, Limit: 10},
+					[]row{{"m2", 1700, 5}, {"m1", 1500, 4}, {"m3", testInitialRating, 0}}},
+				{"category", LeaderboardQuery{CategoryID: &coding, Status: ModelsActive, Limit: 10},
+					[]row{{"m2", 1700, 5}, {"m1", 1600, 3}, {"m3", testInitialRating, 0}}},
+				{"limited", LeaderboardQuery{Status: ModelsActive, Limit: 1},
+					[]row{{"m2", 1700, 5}}},
+				{"inactive", LeaderboardQuery{Status: ModelsInactive, Limit: 10},
+					[]row{{"retired", 1800, 9}}},
+				{"personal", LeaderboardQuery{UserID: "u1", CategoryID: &coding, Status: ModelsActive, Limit: 10},
+					[]row{{"m3", 1650, 2}, {"m1", 1300, 0}, {"m2", testInitialRating, 0}}},
+			}
+			for _, tt := range tests {
+				standings, err := ratings.Leaderboard(tt.q)
+				if err != nil {
+					t.Fatalf("%s: %v", tt.name, err)
+				}
+				got := make([]row, 0, len(standings))
+				for _, s := range standings {
+					got = append(got, row{s.ModelID, s.Rating, s.Wins})
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("%s: Leaderboard = %v, want %v", tt.name, got, tt.want)
+				}
+			}
+
+			standings, err := ratings.Leaderboard(LeaderboardQuery{Status: ModelsActive, Limit: 1})
+			if err != nil {
+				t.Fatalf("trend: %v", err)
+			}
+			if standings[0].Trend != 20 {
+				t.Errorf("trend of m2 = %d, want 20", standings[0].Trend)
+			}
+		})
+
+		t.Run("History", func(t *testing.T) {
+			now := time.Now().UTC()
+			tests := []struct {
+				name       string
+				categories []string
+				want       int
+			}{
+				{"all categories", nil, 1},
+				{"matching category", []string{"coding"}, 1},
+				{"other category", []string{Uncategorized}, 0},
+			}
+			for _, tt := range tests {
+				points, err := ratings.History(HistoryQuery{
+					Models:     []string{"m1", "m2"},
+					Categories: tt.categories,
+					From:       now.Add(-24 * time.Hour),
+					Until:      now.Add(time.Hour),
+				})
+				if err != nil {
+					t.Fatalf("%s: %v", tt.name, err)
+				}
+				if len(points) != tt.want {
+					t.Errorf("%s: %d points, want %d", tt.name, len(points), tt.want)
+				}
+				for _, p := range points {
+					if p.ModelID != "m2" || p.Rating != 1700 || p.Change != 20 || p.Category != "coding" {
+						t.Errorf("%s: point = %+v", tt.name, p)
+					}
+				}
+			}
+		})
+
+		t.Run("Matchups", func(t *testing.T) {
+			matchup := `INSERT INTO matchups (model_a_id, model_b_id, category_id, model_a_wins, model_b_wins, draws) VALUES (?, ?, ?, ?, ?, ?)`
+			mustExec(t, db, matchup, "m1", "m2", coding, 2, 1, 0)
+			mustExec(t, db, matchup, "m1", "m2", nil, 0, 1, 1)
+
+			total, err := ratings.Matchup("m1", "m2")
+			if err != nil {
+				t.Fatalf("Matchup: %v", err)
+			}
+			if total.ModelAWins != 2 || total.ModelBWins != 2 || total.Draws != 1 || total.TotalGames != 5 {
+				t.Errorf("Matchup = %+v", total)
+			}
+
+			byCategory, err := ratings.MatchupsByCategory("m1", "m2")
+			if err != nil {
+				t.Fatalf("MatchupsByCategory: %v", err)
+			}
+			for _, m := range byCategory {
+				want := 0
+				if *m.CategoryID == coding {
+					want = 3
+				}
+				if m.TotalGames != want {
+					t.Errorf("games in %s = %d, want %d", *m.CategoryName, m.TotalGames, want)
+				}
+			}
+		})
+
+		t.Run("Snapshots", func(t *testing.T) {
+			snapshot := `INSERT INTO leaderboard_snapshots (snapshot_date, model_id, category_id, rating, wins) VALUES (?, ?, ?, ?, ?)`
+			mustExec(t, db, snapshot, "2026-01-01", "m1", coding, 1550, 2)
+			mustExec(t, db, snapshot, "2026-01-01", "m1", math, 1450, 1)
+			mustExec(t, db, snapshot, "2026-01-03", "m2", coding, 1600, 3)
+
+			tests := []struct {
+				onOrBefore string
+				want       string
+				wantErr    error
+			}{
+				{"2026-01-02", "2026-01-01", nil},
+				{"2026-01-05", "2026-01-03", nil},
+				{"2025-12-31", "", ErrNotFound},
+			}
+			for _, tt := range tests {
+				got, err := ratings.LatestSnapshot(tt.onOrBefore)
+				if err != tt.wantErr || got != tt.want {
+					t.Errorf("LatestSnapshot(%s) = %q, %v, want %q, %v", tt.onOrBefore, got, err, tt.want, tt.wantErr)
+				}
+			}
+
+			standings, err := ratings.SnapshotLeaderboard("2026-01-01", nil, 10)
+			if err != nil {
+				t.Fatalf("SnapshotLeaderboard: %v", err)
+			}
+			if len(standings) != 1 || standings[0].Rating != 1500 || standings[0].Wins != 3 {
+				t.Errorf("SnapshotLeaderboard = %+v, want m1 at 1500 with 3 wins", standings)
+			}
+		})
+	})
+}
@@ -0,0 +1,293 @@
+package store
+
+import (
+	"database/sql"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+// MaxResponseMatches caps the highlighted responses returned per session
+const MaxResponseMatches = 3
+
+// Where a search match was found
+const (
+	MatchQuestion  = "question"
+	MatchSynthesis = "synthesis"
+	MatchResponse  = "response"
+)
+
+// SearchQuery selects sessions by the text of their questions, responses and
+// syntheses
+type SearchQuery struct {
+	Text     string
+	UserID   string // Only sessions the user owns or was shared
+	Mode     string
+	Status   string
+	ModelID  string // Only sessions the model answered in
+	Category string // Category name, Uncategorized included
+	From     time.Time
+	Until    time.Time // Exclusive
+	Limit    int
+	Offset   int
+}
+
+// SearchMatch is a passage of a session that matched, HTML-escaped with
+// the matched terms wrapped in <mark>
+type SearchMatch struct {
+	Source     string `json:"source"`
+	ResponseID *int64 `json:"response_id,omitempty"`
+	ModelID    string `json:"model_id,omitempty"`
+	Snippet    string `json:"snippet"`
+}
+
+// SearchResult is a session that matched a search, best match first
+type SearchResult struct {
+	ID          string        `json:"id"`
+	Question    string        `json:"question"`
+	Mode        string        `json:"mode"`
+	Status      string        `json:"status"`
+	CategoryID  *int64        `json:"category_id"`
+	Category    *string       `json:"category"`
+	CreatedAt   string        `json:"created_at"`
+	CompletedAt *string       `json:"completed_at"`
+	Matches     []SearchMatch `json:"matches"`
+}
+
+// searchSQL holds the full-text parts of a search in one SQL dialect. Lower
+// scores are better.
+type searchSQL struct {
+	// hits lists (session_id, score) of every matching question, synthesis
+	// and response, taking the search text twice
+	hits string
+	// sessionSnippets lists (session_id, question, synthesis) snippets of
+	// matching sessions, taking the search text once
+	sessionSnippets string
+	// sessionID is the session ID column of sessionSnippets
+	sessionID string
+	// responseSnippets lists (session_id, id, model_id, snippet, score) of
+	// matching responses, taking the search text once
+	responseSnippets string
+}
+
+var sqliteSearch = searchSQL{
+	hits: `
+		SELECT session_id, bm25(session_search) AS score
+		FROM session_search WHERE session_search MATCH ?
+		UNION ALL
+		SELECT r.session_id, bm25(response_search)
+		FROM response_search JOIN responses r ON r.id = response_search.rowid
+		WHERE response_search MATCH ?`,
+	sessionSnippets: `
+		SELECT session_id,
+			snippet(session_search, 1, '<mark>', '</mark>', '…', 24),
+			snippet(session_search, 2, '<mark>', '</mark>', '…', 24)
+		FROM session_search WHERE session_search MATCH ?`,
+	sessionID: "session_id",
+	responseSnippets: `
+		SELECT r.session_id, r.id, r.model_id, snippet(response_search, 0, '<mark>', '</mark>', '…', 24),
+			bm25(response_search) AS score
+		FROM response_search JOIN responses r ON r.id = response_search.rowid
+		WHERE response_search MATCH ?`,
+}
+
+const headlineOptions = `'StartSel=<mark>, StopSel=</mark>, MaxWords=24, MinWords=8, MaxFragments=2, FragmentDelimiter=" … "'`
+
+var postgresSearch = searchSQL{
+	hits: `
+		SELECT id AS session_id, -ts_rank(search_vector, q) AS score
+		FROM sessions, plainto_tsquery('english', ?) q WHERE search_vector @@ q
+		UNION ALL
+		SELECT session_id, -ts_rank(search_vector, q)
+		FROM responses, plainto_tsquery('english', ?) q WHERE search_vector @@ q`,
+	sessionSnippets: `
+		SELECT id,
+			ts_headline('english', question, q, ` + headlineOptions + `),
+			ts_headline('english', COALESCE(synthesis, ''), q, ` + headlineOptions + `)
+		FROM sessions, plainto_tsquery('english', ?) q WHERE search_vector @@ q`,
+	sessionID: "id",
+	responseSnippets: `
+		SELECT r.session_id, r.id, r.model_id, ts_headline('english', r.content, q, ` + headlineOptions + `),
+			-ts_rank(r.search_vector, q) AS score
+		FROM responses r, plainto_tsquery('english', ?) q WHERE r.search_vector @@ q`,
+}
+
+func (s *sessionStore) searchSQL() searchSQL {
+	if s.db.Dialect == database.Postgres {
+		return postgresSearch
+	}
+	return sqliteSearch
+}
+
+// searchText turns user input into a query for the database: every word
+// must appear, and FTS5 operators are taken literally
+func (s *sessionStore) searchText(text string) string {
+	if s.db.Dialect == database.Postgres {
+		return text // plainto_tsquery ignores operators
+	}
+	words := strings.Fields(text)
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+func (s *sessionStore) Search(q SearchQuery) ([]SearchResult, error) {
+	dialect := s.searchSQL()
+	text := s.searchText(q.Text)
+
+	query := `
+		WITH hits AS (` + dialect.hits + `)
+		SELECT s.id, s.question, s.mode, s.status, s.category_id, c.name, s.created_at, s.completed_at, MIN(h.score) AS score
+		FROM hits h
+		JOIN sessions s ON s.id = h.session_id
+		LEFT JOIN categories c ON c.id = s.category_id
+		WHERE (s.user_id = ? OR EXISTS (
+			SELECT 1 FROM session_shares sh WHERE sh.session_id = s.id AND sh.user_id = ?
+		))`
+	args := []interface{}{text, text, q.UserID, q.UserID}
+
+	if q.Mode != "" {
+		query += ` AND s.mode = ?`
+		args = append(args, q.Mode)
+	}
+	if q.Status != "" {
+		query += ` AND s.status = ?`
+		args = append(args, q.Status)
+	}
+	if q.ModelID != "" {
+		query += ` AND EXISTS (SELECT 1 FROM responses mr WHERE mr.session_id = s.id AND mr.model_id = ?)`
+		args = append(args, q.ModelID)
+	}
+	if q.Category != "" {
+		query += ` AND COALESCE(c.name, ?) = ?`
+		args = append(args, Uncategorized, q.Category)
+	}
+	if !q.From.IsZero() {
+		query += ` AND s.created_at >= ?`
+		args = append(args, q.From.UTC().Format(timestampFormat))
+	}
+	if !q.Until.IsZero() {
+		query += ` AND s.created_at < ?`
+		args = append(args, q.Until.UTC().Format(timestampFormat))
+	}
+	query += `
+		GROUP BY s.id, s.question, s.mode, s.status, s.category_id, c.name, s.created_at, s.completed_at
+		ORDER BY score, s.created_at DESC
+		LIMIT ? OFFSET ?`
+	args = append(args, q.Limit, q.Offset)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0)
+	index := make(map[string]int)
+	for rows.Next() {
+		var r SearchResult
+		var categoryID sql.NullInt64
+		var category, completedAt sql.NullString
+		var score float64
+		if err := rows.Scan(&r.ID, &r.Question, &r.Mode, &r.Status, &categoryID, &category,
+			&r.CreatedAt, &completedAt, &score); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		r.CategoryID = nullInt64(categoryID)
+		r.Category = nullString(category)
+		r.CompletedAt = nullString(completedAt)
+		r.Matches = make([]SearchMatch, 0)
+		index[r.ID] = len(results)
+		results = append(results, r)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	if err := s.searchSnippets(dialect, text, results, index); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// searchSnippets adds the highlighted passages of each result
+func (s *sessionStore) searchSnippets(dialect searchSQL, text string, results []SearchResult, index map[string]int) error {
+	in := ` IN (?` + strings.Repeat(", ?", len(results)-1) + `)`
+	args := []interface{}{text}
+	for _, r := range results {
+		args = append(args, r.ID)
+	}
+
+	rows, err := s.db.Query(dialect.sessionSnippets+` AND `+dialect.sessionID+in, args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var sessionID string
+		var question, synthesis sql.NullString
+		if err := rows.Scan(&sessionID, &question, &synthesis); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		r := &results[index[sessionID]]
+		if highlighted(question.String) {
+			r.Matches = append(r.Matches, SearchMatch{Source: MatchQuestion, Snippet: escapeSnippet(question.String)})
+		}
+		if highlighted(synthesis.String) {
+			r.Matches = append(r.Matches, SearchMatch{Source: MatchSynthesis, Snippet: escapeSnippet(synthesis.String)})
+		}
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = s.db.Query(dialect.responseSnippets+` AND r.session_id`+in+` ORDER BY score`, args...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	responses := make(map[string]int)
+	for rows.Next() {
+		var sessionID, modelID, snippet string
+		var responseID int64
+		var score float64
+		if err := rows.Scan(&sessionID, &responseID, &modelID, &snippet, &score); err != nil {
+			return err
+		}
+		if responses[sessionID] == MaxResponseMatches {
+			continue
+		}
+		responses[sessionID]++
+		r := &results[index[sessionID]]
+		r.Matches = append(r.Matches, SearchMatch{
+			Source:     MatchResponse,
+			ResponseID: &responseID,
+			ModelID:    modelID,
+			Snippet:    escapeSnippet(snippet),
+		})
+	}
+	return rows.Err()
+}
+
+// highlighted reports whether a snippet contains a matched term; snippets of
+// columns that did not match are returned without any
+func highlighted(snippet string) bool {
+	return strings.Contains(snippet, "<mark>")
+}
+
+// escapeSnippet escapes the text of a snippet so it can be rendered as
+// HTML, keeping only the <mark> tags around matched terms
+func escapeSnippet(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, "&lt;mark&gt;", "<mark>")
+	return strings.ReplaceAll(escaped, "&lt;/mark&gt;", "</mark>")
+}
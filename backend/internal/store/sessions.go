@@ -0,0 +1,388 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/sainaif/council/internal/database"
+	"github.com/sainaif/council/internal/services/agreement"
+)
+
+// Session is a stored council session, without its responses and votes
+type Session struct {
+	ID                 string
+	UserID             string
+	Question           string
+	Mode               string
+	Status             string
+	CategoryID         *int64
+	CategorySource     string
+	CategoryConfidence *float64
+	ChairpersonID      *string
+	DevilAdvocateID    *string
+	MysteryJudgeID     *string
+	Synthesis          string
+	MinorityReport     string
+	AgreementW         *float64
+	Config             string // JSON
+	CreatedAt          time.Time
+	CompletedAt        *time.Time
+}
+
+// Response is a model's answer in a session
+type Response struct {
+	ID             int64
+	SessionID      string
+	ModelID        string
+	Round          int
+	Content        string
+	AnonymousLabel string
+	ResponseTimeMs int64
+	TokenCount     int
+	CreatedAt      time.Time
+}
+
+// Vote is a model's or user's ranking of a session's responses
+type Vote struct {
+	ID              int64
+	SessionID       string
+	VoterType       string
+	VoterID         string
+	RankedResponses []string
+	Weight          float64
+	CreatedAt       time.Time
+}
+
+// SessionSummary is an entry of a user's session history
+type SessionSummary struct {
+	ID            string  `json:"id"`
+	Question      string  `json:"question"`
+	Mode          string  `json:"mode"`
+	Status        string  `json:"status"`
+	CreatedAt     string  `json:"created_at"`
+	CompletedAt   *string `json:"completed_at"`
+	ResponseCount int     `json:"response_count"`
+}
+
+// SessionStore keeps council sessions with their responses and votes
+type SessionStore interface {
+	// Create stores a new session
+	Create(s *Session) error
+	// Get returns a session
+	Get(id string) (*Session, error)
+	// Responses returns a session's responses by round
+	Responses(sessionID string) ([]Response, error)
+	// Votes returns a session's votes
+	Votes(sessionID string) ([]Vote, error)
+	// AddResponse stores a response and sets its ID
+	AddResponse(r *Response) error
+	// AddVote stores a vote and sets its ID
+	AddVote(v *Vote) error
+	// Advance moves an unfinished session to a new status. It reports false
+	// when the session already completed, failed or was cancelled.
+	Advance(id, status string) (bool, error)
+	// Classify files a session under a category picked by the classifier,
+	// unless its user chose one meanwhile. It reports whether it was filed.
+	Classify(id string, categoryID int64, source string, confidence float64) (bool, error)
+	// SetCategory files a session under a category its user chose, or
+	// under none with a nil categoryID
+	SetCategory(id string, categoryID *int64, source string) error
+	// Category returns the category a session is filed under, nil if none
+	Category(id string) (*int64, error)
+	// Claim marks a session as run by holder until expires. It reports
+	// false when another holder's claim is still live at now.
+	Claim(id, holder string, now, expires time.Time) (bool, error)
+	// ReleaseClaim gives up holder's claim on a session
+	ReleaseClaim(id, holder string) error
+	// ClaimHolder returns the instance whose claim on a session is live at
+	// now, or "" when no instance runs it
+	ClaimHolder(id string, now time.Time) (string, error)
+	// Orphaned returns the sessions in one of the statuses whose claim
+	// expired before now
+	Orphaned(now time.Time, statuses ...string) ([]string, error)
+	// Owner returns the user a session belongs to
+	Owner(id string) (string, error)
+	// SharedWith reports whether a session is shared with a user
+	SharedWith(id, userID string) (bool, error)
+	// Share grants a user access to a session and returns when it was
+	// granted; sharing again keeps the first grant
+	Share(id, userID, grantedBy string) (time.Time, error)
+	// Unshare withdraws a user's access. It reports whether a grant existed.
+	Unshare(id, userID string) (bool, error)
+	// Shares lists who a session is shared with, oldest grant first
+	Shares(id string) ([]Share, error)
+	// Complete moves an unfinished session to a final status and records
+	// when it finished. It reports false when the session already completed,
+	// failed or was cancelled.
+	Complete(id, status string) (bool, error)
+	// SetSynthesis records a session's conclusion
+	SetSynthesis(id, synthesis, minorityReport string) error
+	// SetAgreement replaces a session's inter-rater agreement
+	SetAgreement(id string, w float64, pairs []agreement.Pair) error
+	// Agreement returns the pairwise agreement of a session's voters
+	Agreement(id string) ([]agreement.Pair, error)
+	// History returns a user's latest sessions, newest first
+	History(userID string, limit int) ([]SessionSummary, error)
+	// Search returns the sessions a user can view whose text matches, best
+	// match first
+	Search(q SearchQuery) ([]SearchResult, error)
+}
+
+type sessionStore struct {
+	db *database.DB
+}
+
+// NewSessionStore creates a session store backed by the database
+func NewSessionStore(db *database.DB) SessionStore {
+	return &sessionStore{db: db}
+}
+
+func (s *sessionStore) Create(session *Session) error {
+	var categorySource *string
+	if session.CategorySource != "" {
+		categorySource = &session.CategorySource
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO sessions (id, user_id, question, category_id, category_source, mode, status, config, chairperson_id, devil_advocate_id, mystery_judge_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, session.ID, session.UserID, session.Question, session.CategoryID, categorySource, session.Mode, session.Status,
+		session.Config, session.ChairpersonID, session.DevilAdvocateID, session.MysteryJudgeID)
+	return err
+}
+
+func (s *sessionStore) Get(id string) (*Session, error) {
+	var session Session
+	var config, synthesis, minorityReport, categorySource sql.NullString
+	var chairpersonID, devilID, mysteryID sql.NullString
+	var categoryID sql.NullInt64
+	var categoryConfidence, agreementW sql.NullFloat64
+	var completedAt sql.NullTime
+
+	err := s.db.QueryRow(`
+		SELECT id, user_id, question, category_id, category_source, category_confidence, mode, status, config, chairperson_id,
+			   devil_advocate_id, mystery_judge_id, synthesis, minority_report, agreement_w, created_at, completed_at
+		FROM sessions WHERE id = ?
+	`, id).Scan(
+		&session.ID, &session.UserID, &session.Question, &categoryID, &categorySource, &categoryConfidence,
+		&session.Mode, &session.Status, &config, &chairpersonID,
+		&devilID, &mysteryID, &synthesis, &minorityReport, &agreementW,
+		&session.CreatedAt, &completedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	session.CategoryID = nullInt64(categoryID)
+	session.CategorySource = categorySource.String
+	session.CategoryConfidence = nullFloat64(categoryConfidence)
+	session.ChairpersonID = nullString(chairpersonID)
+	session.DevilAdvocateID = nullString(devilID)
+	session.MysteryJudgeID = nullString(mysteryID)
+	session.Synthesis = synthesis.String
+	session.MinorityReport = minorityReport.String
+	session.AgreementW = nullFloat64(agreementW)
+	session.Config = config.String
+	if completedAt.Valid {
+		session.CompletedAt = &completedAt.Time
+	}
+
+	return &session, nil
+}
+
+func (s *sessionStore) Responses(sessionID string) ([]Response, error) {
+	rows, err := s.db.Query(`
+		SELECT id, session_id, model_id, round, content, anonymous_label, response_time_ms, token_count, created_at
+		FROM responses WHERE session_id = ? ORDER BY round, id
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var responses []Response
+	for rows.Next() {
+		var r Response
+		var responseTime sql.NullInt64
+		var tokenCount sql.NullInt64
+		if err := rows.Scan(&r.ID, &r.SessionID, &r.ModelID, &r.Round, &r.Content,
+			&r.AnonymousLabel, &responseTime, &tokenCount, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.ResponseTimeMs = responseTime.Int64
+		r.TokenCount = int(tokenCount.Int64)
+		responses = append(responses, r)
+	}
+	return responses, rows.Err()
+}
+
+func (s *sessionStore) Votes(sessionID string) ([]Vote, error) {
+	rows, err := s.db.Query(`
+		SELECT id, session_id, voter_type, voter_id, ranked_responses, weight, created_at
+		FROM votes WHERE session_id = ? ORDER BY id
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var votes []Vote
+	for rows.Next() {
+		var v Vote
+		var rankedJSON string
+		if err := rows.Scan(&v.ID, &v.SessionID, &v.VoterType, &v.VoterID, &rankedJSON, &v.Weight, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(rankedJSON), &v.RankedResponses); err != nil {
+			return nil, err
+		}
+		votes = append(votes, v)
+	}
+	return votes, rows.Err()
+}
+
+func (s *sessionStore) AddResponse(r *Response) error {
+	return s.db.QueryRow(`
+		INSERT INTO responses (session_id, model_id, round, content, anonymous_label, response_time_ms, token_count)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, r.SessionID, r.ModelID, r.Round, r.Content, r.AnonymousLabel, r.ResponseTimeMs, r.TokenCount).Scan(&r.ID)
+}
+
+func (s *sessionStore) AddVote(v *Vote) error {
+	rankingJSON, err := json.Marshal(v.RankedResponses)
+	if err != nil {
+		return err
+	}
+	return s.db.QueryRow(`
+		INSERT INTO votes (session_id, voter_type, voter_id, ranked_responses, weight)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id
+	`, v.SessionID, v.VoterType, v.VoterID, string(rankingJSON), v.Weight).Scan(&v.ID)
+}
+
+// finished matches sessions that reached a final status
+const sessionFinished = `status IN ('completed', 'failed', 'cancelled')`
+
+func (s *sessionStore) Advance(id, status string) (bool, error) {
+	result, err := s.db.Exec(`UPDATE sessions SET status = ? WHERE id = ? AND NOT `+sessionFinished, status, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (s *sessionStore) Classify(id string, categoryID int64, source string, confidence float64) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE sessions SET category_id = ?, category_source = ?, category_confidence = ?
+		WHERE id = ? AND category_source IS NULL
+	`, categoryID, source, confidence, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (s *sessionStore) SetCategory(id string, categoryID *int64, source string) error {
+	_, err := s.db.Exec(`
+		UPDATE sessions SET category_id = ?, category_source = ?, category_confidence = NULL
+		WHERE id = ?
+	`, categoryID, source, id)
+	return err
+}
+
+func (s *sessionStore) Category(id string) (*int64, error) {
+	var categoryID sql.NullInt64
+	if err := s.db.QueryRow(`SELECT category_id FROM sessions WHERE id = ?`, id).Scan(&categoryID); err != nil {
+		return nil, notFound(err)
+	}
+	return nullInt64(categoryID), nil
+}
+
+func (s *sessionStore) Complete(id, status string) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE sessions SET status = ?, completed_at = CURRENT_TIMESTAMP WHERE id = ? AND NOT `+sessionFinished, status, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (s *sessionStore) SetSynthesis(id, synthesis, minorityReport string) error {
+	_, err := s.db.Exec(`
+		UPDATE sessions SET synthesis = ?, minority_report = ? WHERE id = ?
+	`, synthesis, minorityReport, id)
+	return err
+}
+
+func (s *sessionStore) SetAgreement(id string, w float64, pairs []agreement.Pair) error {
+	return s.db.WithTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`UPDATE sessions SET agreement_w = ? WHERE id = ?`, w, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM vote_agreements WHERE session_id = ?`, id); err != nil {
+			return err
+		}
+		for _, p := range pairs {
+			if _, err := tx.Exec(`
+				INSERT INTO vote_agreements (session_id, voter_a, voter_b, tau)
+				VALUES (?, ?, ?, ?)
+			`, id, p.VoterA, p.VoterB, p.Tau); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sessionStore) Agreement(id string) ([]agreement.Pair, error) {
+	rows, err := s.db.Query(`
+		SELECT voter_a, voter_b, tau FROM vote_agreements
+		WHERE session_id = ? ORDER BY voter_a, voter_b
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	pairs := make([]agreement.Pair, 0)
+	for rows.Next() {
+		var p agreement.Pair
+		if err := rows.Scan(&p.VoterA, &p.VoterB, &p.Tau); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, rows.Err()
+}
+
+func (s *sessionStore) History(userID string, limit int) ([]SessionSummary, error) {
+	rows, err := s.db.Query(`
+		SELECT s.id, s.question, s.mode, s.status, s.created_at, s.completed_at,
+			(SELECT COUNT(*) FROM responses r WHERE r.session_id = s.id)
+		FROM sessions s
+		WHERE s.user_id = ?
+		ORDER BY s.created_at DESC
+		LIMIT ?
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	sessions := make([]SessionSummary, 0)
+	for rows.Next() {
+		var ss SessionSummary
+		var completedAt sql.NullString
+		if err := rows.Scan(&ss.ID, &ss.Question, &ss.Mode, &ss.Status, &ss.CreatedAt, &completedAt, &ss.ResponseCount); err != nil {
+			return nil, err
+		}
+		ss.CompletedAt = nullString(completedAt)
+		sessions = append(sessions, ss)
+	}
+	return sessions, rows.Err()
+}
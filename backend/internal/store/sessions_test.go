@@ -0,0 +1,313 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+func TestSessionStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, db *database.DB) {
+		sessions := NewSessionStore(db)
+		seedModels(t, db, "m1", "m2")
+		coding := categoryID(t, db, "coding")
+		seedSession(t, db, "s1", "u1", "How do I reverse a list?", &coding)
+
+		t.Run("Get", func(t *testing.T) {
+			tests := []struct {
+				id      string
+				wantErr error
+			}{
+				{"s1", nil},
+				{"missing", ErrNotFound},
+			}
+			for _, tt := range tests {
+				got, err := sessions.Get(tt.id)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Get(%q) error = %v, want %v", tt.id, err, tt.wantErr)
+				}
+				if err != nil {
+					continue
+				}
+				if got.UserID != "u1" || got.Question != "How do I reverse a list?" || got.Status != "pending" {
+					t.Errorf("Get(%q) = %+v", tt.id, got)
+				}
+				if got.CategoryID == nil || *got.CategoryID != coding {
+					t.Errorf("Get(%q) category = %v, want %d", tt.id, got.CategoryID, coding)
+				}
+			}
+		})
+
+		t.Run("ResponsesAndVotes", func(t *testing.T) {
+			responses := []*Response{
+				{SessionID: "s1", ModelID: "m2", Round: 2, Content: "later", AnonymousLabel: "Response B", TokenCount: 7},
+				{SessionID: "s1", ModelID: "m1", Round: 1, Content: "first", AnonymousLabel: "Response A", TokenCount: 5},
+			}
+			for _, r := range responses {
+				if err := sessions.AddResponse(r); err != nil {
+					t.Fatalf("AddResponse: %v", err)
+				}
+			}
+			vote := &Vote{SessionID: "s1", VoterType: "model", VoterID: "m1", RankedResponses: []string{"Response B", "Response A"}, Weight: 1}
+			if err := sessions.AddVote(vote); err != nil {
+				t.Fatalf("AddVote: %v", err)
+			}
+
+			got, err := sessions.Responses("s1")
+			if err != nil {
+				t.Fatalf("Responses: %v", err)
+			}
+			var order []string
+			for _, r := range got {
+				order = append(order, r.Content)
+			}
+			if want := []string{"first", "later"}; !reflect.DeepEqual(order, want) {
+				t.Errorf("Responses order = %v, want %v", order, want)
+			}
+
+			votes, err := sessions.Votes("s1")
+			if err != nil {
+				t.Fatalf("Votes: %v", err)
+			}
+			if len(votes) != 1 || !reflect.DeepEqual(votes[0].RankedResponses, vote.RankedResponses) || votes[0].ID != vote.ID {
+				t.Errorf("Votes = %+v, want %+v", votes, vote)
+			}
+		})
+
+		t.Run("Category", func(t *testing.T) {
+			math := categoryID(t, db, "math")
+			seedSession(t, db, "s2", "u1", "What is 2+2?", nil)
+
+			steps := []struct {
+				name      string
+				run       func() (bool, error)
+				wantFiled bool
+				want      *int64
+			}{
+				{"classified", func() (bool, error) { return sessions.Classify("s2", math, "keyword", 0.9) }, true, &math},
+				{"overridden", func() (bool, error) { return true, sessions.SetCategory("s2", &coding, "override") }, true, &coding},
+				{"classifier keeps override", func() (bool, error) { return sessions.Classify("s2", math, "keyword", 0.9) }, false, &coding},
+				{"uncategorized", func() (bool, error) { return true, sessions.SetCategory("s2", nil, "override") }, true, nil},
+			}
+			for _, step := range steps {
+				filed, err := step.run()
+				if err != nil {
+					t.Fatalf("%s: %v", step.name, err)
+				}
+				if filed != step.wantFiled {
+					t.Errorf("%s: filed = %v, want %v", step.name, filed, step.wantFiled)
+				}
+				got, err := sessions.Category("s2")
+				if err != nil {
+					t.Fatalf("%s: Category: %v", step.name, err)
+				}
+				if !reflect.DeepEqual(got, step.want) {
+					t.Errorf("%s: Category = %v, want %v", step.name, got, step.want)
+				}
+			}
+
+			if _, err := sessions.Category("missing"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Category(missing) error = %v, want ErrNotFound", err)
+			}
+		})
+
+		t.Run("Claims", func(t *testing.T) {
+			seedSession(t, db, "s3", "u1", "Claimed", nil)
+			mustExec(t, db, `UPDATE sessions SET status = 'responding' WHERE id = 's3'`)
+			now := time.Now().UTC().Truncate(time.Second)
+			ttl := 2 * time.Minute
+
+			steps := []struct {
+				name       string
+				holder     string
+				at         time.Time
+				wantClaim  bool
+				wantHolder string // Holder seen at the same time
+			}{
+				{"first claim", "a", now, true, "a"},
+				{"held by another", "b", now.Add(time.Minute), false, "a"},
+				{"renewed", "a", now.Add(time.Minute), true, "a"},
+				{"still held after first expiry", "b", now.Add(ttl + time.Second), false, "a"},
+				{"taken over once expired", "b", now.Add(time.Minute + ttl + time.Second), true, "b"},
+			}
+			for _, step := range steps {
+				claimed, err := sessions.Claim("s3", step.holder, step.at, step.at.Add(ttl))
+				if err != nil {
+					t.Fatalf("%s: %v", step.name, err)
+				}
+				if claimed != step.wantClaim {
+					t.Errorf("%s: claimed = %v, want %v", step.name, claimed, step.wantClaim)
+				}
+				holder, err := sessions.ClaimHolder("s3", step.at)
+				if err != nil {
+					t.Fatalf("%s: ClaimHolder: %v", step.name, err)
+				}
+				if holder != step.wantHolder {
+					t.Errorf("%s: holder = %q, want %q", step.name, holder, step.wantHolder)
+				}
+			}
+
+			last := steps[len(steps)-1].at
+			orphaned, err := sessions.Orphaned(last.Add(ttl+time.Second), "pending", "responding")
+			if err != nil {
+				t.Fatalf("Orphaned: %v", err)
+			}
+			if want := []string{"s3"}; !reflect.DeepEqual(orphaned, want) {
+				t.Errorf("Orphaned = %v, want %v", orphaned, want)
+			}
+			if orphaned, err := sessions.Orphaned(last, "pending", "responding"); err != nil || len(orphaned) != 0 {
+				t.Errorf("Orphaned before expiry = %v, %v, want none", orphaned, err)
+			}
+
+			if err := sessions.ReleaseClaim("s3", "a"); err != nil {
+				t.Fatalf("ReleaseClaim by another holder: %v", err)
+			}
+			if holder, _ := sessions.ClaimHolder("s3", last); holder != "b" {
+				t.Errorf("holder after release by another = %q, want b", holder)
+			}
+			if err := sessions.ReleaseClaim("s3", "b"); err != nil {
+				t.Fatalf("ReleaseClaim: %v", err)
+			}
+			if holder, _ := sessions.ClaimHolder("s3", last); holder != "" {
+				t.Errorf("holder after release = %q, want none", holder)
+			}
+		})
+
+		t.Run("Status", func(t *testing.T) {
+			seedSession(t, db, "s5", "u5", "Cancelled halfway", nil)
+
+			steps := []struct {
+				name string
+				run  func() (bool, error)
+				want bool
+			}{
+				{"advance", func() (bool, error) { return sessions.Advance("s5", "responding") }, true},
+				{"cancel", func() (bool, error) { return sessions.Complete("s5", "cancelled") }, true},
+				{"advance after cancel", func() (bool, error) { return sessions.Advance("s5", "voting") }, false},
+				{"complete after cancel", func() (bool, error) { return sessions.Complete("s5", "completed") }, false},
+				{"fail after cancel", func() (bool, error) { return sessions.Complete("s5", "failed") }, false},
+				{"missing", func() (bool, error) { return sessions.Advance("missing", "voting") }, false},
+			}
+			for _, step := range steps {
+				updated, err := step.run()
+				if err != nil {
+					t.Fatalf("%s: %v", step.name, err)
+				}
+				if updated != step.want {
+					t.Errorf("%s: updated = %v, want %v", step.name, updated, step.want)
+				}
+			}
+
+			got, err := sessions.Get("s5")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if got.Status != "cancelled" || got.CompletedAt == nil {
+				t.Errorf("session = %s finished at %v, want cancelled with a finish time", got.Status, got.CompletedAt)
+			}
+		})
+
+		t.Run("Shares", func(t *testing.T) {
+			seedUser(t, db, "u2", "Reviewer")
+
+			if owner, err := sessions.Owner("s1"); err != nil || owner != "u1" {
+				t.Errorf("Owner = %q, %v, want u1", owner, err)
+			}
+			if _, err := sessions.Owner("missing"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Owner(missing) error = %v, want ErrNotFound", err)
+			}
+
+			first, err := sessions.Share("s1", "u2", "u1")
+			if err != nil {
+				t.Fatalf("Share: %v", err)
+			}
+			again, err := sessions.Share("s1", "u2", "u1")
+			if err != nil {
+				t.Fatalf("Share again: %v", err)
+			}
+			if !again.Equal(first) {
+				t.Errorf("sharing again changed the grant time from %v to %v", first, again)
+			}
+
+			tests := []struct {
+				userID string
+				want   bool
+			}{
+				{"u2", true},
+				{"u3", false},
+			}
+			for _, tt := range tests {
+				if got, err := sessions.SharedWith("s1", tt.userID); err != nil || got != tt.want {
+					t.Errorf("SharedWith(%s) = %v, %v, want %v", tt.userID, got, err, tt.want)
+				}
+			}
+
+			shares, err := sessions.Shares("s1")
+			if err != nil {
+				t.Fatalf("Shares: %v", err)
+			}
+			if len(shares) != 1 || shares[0].UserID != "u2" || shares[0].Username != "Reviewer" {
+				t.Errorf("Shares = %+v", shares)
+			}
+
+			for _, want := range []bool{true, false} {
+				if removed, err := sessions.Unshare("s1", "u2"); err != nil || removed != want {
+					t.Errorf("Unshare = %v, %v, want %v", removed, err, want)
+				}
+			}
+		})
+
+		t.Run("History", func(t *testing.T) {
+			history, err := sessions.History("u1", 10)
+			if err != nil {
+				t.Fatalf("History: %v", err)
+			}
+			counts := make(map[string]int)
+			for _, h := range history {
+				counts[h.ID] = h.ResponseCount
+			}
+			if want := map[string]int{"s1": 2, "s2": 0, "s3": 0}; !reflect.DeepEqual(counts, want) {
+				t.Errorf("History response counts = %v, want %v", counts, want)
+			}
+			if history, err := sessions.History("u2", 10); err != nil || len(history) != 0 {
+				t.Errorf("History of another user = %v, %v, want none", history, err)
+			}
+		})
+
+		t.Run("Search", func(t *testing.T) {
+			seedSession(t, db, "s4", "u2", "Explain photosynthesis", nil)
+			if err := sessions.AddResponse(&Response{SessionID: "s1", ModelID: "m1", Round: 3, Content: "Use photosynthesis", AnonymousLabel: "Response C"}); err != nil {
+				t.Fatalf("AddResponse: %v", err)
+			}
+
+			tests := []struct {
+				name   string
+				userID string
+				want   []string
+			}{
+				{"own question", "u2", []string{"s4"}},
+				{"own response", "u1", []string{"s1"}},
+				{"nobody's", "u3", []string{}},
+			}
+			for _, tt := range tests {
+				results, err := sessions.Search(SearchQuery{Text: "photosynthesis", UserID: tt.userID, Limit: 10})
+				if err != nil {
+					t.Fatalf("%s: %v", tt.name, err)
+				}
+				ids := make([]string, 0)
+				for _, r := range results {
+					ids = append(ids, r.ID)
+					if len(r.Matches) == 0 {
+						t.Errorf("%s: result %s has no highlighted matches", tt.name, r.ID)
+					}
+				}
+				if !reflect.DeepEqual(ids, tt.want) {
+					t.Errorf("%s: results = %v, want %v", tt.name, ids, tt.want)
+				}
+			}
+		})
+	})
+}
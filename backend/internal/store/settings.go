@@ -0,0 +1,175 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+// Settings are a user's preferences
+type Settings struct {
+	DefaultModels       []string `json:"default_models"`
+	PreferredCategories []string `json:"preferred_categories"`
+	UIDensity           string   `json:"ui_density"`
+	Language            string   `json:"language"`
+	AutoSaveSessions    bool     `json:"auto_save_sessions"`
+	UserFeedbackWeight  float64  `json:"user_feedback_weight"`
+	RatingScope         string   `json:"rating_scope"`
+}
+
+// DefaultSettings are the preferences of a user who never changed them
+func DefaultSettings() Settings {
+	return Settings{
+		DefaultModels:       []string{},
+		PreferredCategories: []string{},
+		UIDensity:           "comfortable",
+		Language:            "en",
+		AutoSaveSessions:    true,
+		UserFeedbackWeight:  0.5,
+		RatingScope:         "global",
+	}
+}
+
+// SettingsUpdate changes the preferences that are set and keeps the rest
+type SettingsUpdate struct {
+	DefaultModels       *[]string
+	PreferredCategories *[]string
+	UIDensity           *string
+	Language            *string
+	AutoSaveSessions    *bool
+	UserFeedbackWeight  *float64
+	RatingScope         *string
+}
+
+// SettingsStore keeps user preferences
+type SettingsStore interface {
+	// Get returns the user's preferences, or the defaults if none are stored
+	Get(userID string) (Settings, error)
+	// Update applies changes to the user's preferences. It reports false
+	// when the update sets nothing.
+	Update(userID, username string, update SettingsUpdate) (bool, error)
+}
+
+type settingsStore struct {
+	db *database.DB
+}
+
+// NewSettingsStore creates a settings store backed by the database
+func NewSettingsStore(db *database.DB) SettingsStore {
+	return &settingsStore{db: db}
+}
+
+func (s *settingsStore) Get(userID string) (Settings, error) {
+	settings := DefaultSettings()
+	var defaultModels, preferredCategories, uiDensity, language, ratingScope sql.NullString
+	var autoSave sql.NullBool
+	var feedbackWeight sql.NullFloat64
+
+	err := s.db.QueryRow(`
+		SELECT default_models, preferred_categories, ui_density, language,
+			   auto_save_sessions, user_feedback_weight, rating_scope
+		FROM user_preferences WHERE user_id = ?
+	`, userID).Scan(
+		&defaultModels, &preferredCategories, &uiDensity,
+		&language, &autoSave, &feedbackWeight, &ratingScope,
+	)
+	if err == sql.ErrNoRows {
+		return settings, nil
+	}
+	if err != nil {
+		return Settings{}, err
+	}
+
+	if defaultModels.Valid {
+		if err := json.Unmarshal([]byte(defaultModels.String), &settings.DefaultModels); err != nil {
+			return Settings{}, err
+		}
+	}
+	if preferredCategories.Valid {
+		if err := json.Unmarshal([]byte(preferredCategories.String), &settings.PreferredCategories); err != nil {
+			return Settings{}, err
+		}
+	}
+	if uiDensity.Valid {
+		settings.UIDensity = uiDensity.String
+	}
+	if language.Valid {
+		settings.Language = language.String
+	}
+	if autoSave.Valid {
+		settings.AutoSaveSessions = autoSave.Bool
+	}
+	if feedbackWeight.Valid {
+		settings.UserFeedbackWeight = feedbackWeight.Float64
+	}
+	if ratingScope.Valid && ratingScope.String != "" {
+		settings.RatingScope = ratingScope.String
+	}
+
+	return settings, nil
+}
+
+func (s *settingsStore) Update(userID, username string, update SettingsUpdate) (bool, error) {
+	var updates []string
+	var args []interface{}
+
+	if update.DefaultModels != nil {
+		modelsJSON, err := json.Marshal(update.DefaultModels)
+		if err != nil {
+			return false, err
+		}
+		updates = append(updates, "default_models = ?")
+		args = append(args, string(modelsJSON))
+	}
+	if update.PreferredCategories != nil {
+		catsJSON, err := json.Marshal(update.PreferredCategories)
+		if err != nil {
+			return false, err
+		}
+		updates = append(updates, "preferred_categories = ?")
+		args = append(args, string(catsJSON))
+	}
+	if update.UIDensity != nil {
+		updates = append(updates, "ui_density = ?")
+		args = append(args, *update.UIDensity)
+	}
+	if update.Language != nil {
+		updates = append(updates, "language = ?")
+		args = append(args, *update.Language)
+	}
+	if update.AutoSaveSessions != nil {
+		updates = append(updates, "auto_save_sessions = ?")
+		args = append(args, *update.AutoSaveSessions)
+	}
+	if update.UserFeedbackWeight != nil {
+		updates = append(updates, "user_feedback_weight = ?")
+		args = append(args, *update.UserFeedbackWeight)
+	}
+	if update.RatingScope != nil {
+		updates = append(updates, "rating_scope = ?")
+		args = append(args, *update.RatingScope)
+	}
+
+	if len(updates) == 0 {
+		return false, nil
+	}
+	updates = append(updates, "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, userID)
+
+	err := s.db.WithTx(func(tx *sql.Tx) error {
+		// Ensure user exists in preferences
+		if _, err := tx.Exec(`
+			INSERT INTO user_preferences (user_id, github_username)
+			VALUES (?, ?)
+			ON CONFLICT(user_id) DO NOTHING
+		`, userID, username); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(`UPDATE user_preferences SET `+strings.Join(updates, ", ")+` WHERE user_id = ?`, args...)
+		return err
+	})
+	return err == nil, err
+}
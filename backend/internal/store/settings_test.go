@@ -0,0 +1,61 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+func TestSettingsStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, db *database.DB) {
+		settings := NewSettingsStore(db)
+
+		density, weight := "compact", 0.8
+		models := []string{"m1", "m2"}
+		steps := []struct {
+			name        string
+			update      SettingsUpdate
+			wantUpdated bool
+			want        func(s *Settings)
+		}{
+			{"defaults", SettingsUpdate{}, false, func(s *Settings) {}},
+			{"partial", SettingsUpdate{UIDensity: &density, DefaultModels: &models}, true, func(s *Settings) {
+				s.UIDensity = density
+				s.DefaultModels = models
+			}},
+			{"keeps earlier changes", SettingsUpdate{UserFeedbackWeight: &weight}, true, func(s *Settings) {
+				s.UIDensity = density
+				s.DefaultModels = models
+				s.UserFeedbackWeight = weight
+			}},
+		}
+		for _, step := range steps {
+			updated, err := settings.Update("u1", "tester", step.update)
+			if err != nil {
+				t.Fatalf("%s: Update: %v", step.name, err)
+			}
+			if updated != step.wantUpdated {
+				t.Errorf("%s: updated = %v, want %v", step.name, updated, step.wantUpdated)
+			}
+
+			got, err := settings.Get("u1")
+			if err != nil {
+				t.Fatalf("%s: Get: %v", step.name, err)
+			}
+			want := DefaultSettings()
+			step.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: Get = %+v, want %+v", step.name, got, want)
+			}
+		}
+
+		users, err := NewUserStore(db).List()
+		if err != nil {
+			t.Fatalf("List users: %v", err)
+		}
+		if len(users) != 1 || users[0].ID != "u1" || users[0].Username != "tester" {
+			t.Errorf("users after updating settings = %+v", users)
+		}
+	})
+}
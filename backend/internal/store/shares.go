@@ -0,0 +1,78 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Share grants a user other than the owner access to watch and read a session
+type Share struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	AvatarURL string    `json:"avatar_url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *sessionStore) Owner(id string) (string, error) {
+	var owner string
+	err := s.db.QueryRow(`SELECT user_id FROM sessions WHERE id = ?`, id).Scan(&owner)
+	return owner, notFound(err)
+}
+
+func (s *sessionStore) SharedWith(id, userID string) (bool, error) {
+	var shared bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM session_shares WHERE session_id = ? AND user_id = ?)
+	`, id, userID).Scan(&shared)
+	return shared, err
+}
+
+func (s *sessionStore) Share(id, userID, grantedBy string) (time.Time, error) {
+	var createdAt time.Time
+	err := s.db.WithTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`
+			INSERT INTO session_shares (session_id, user_id, granted_by)
+			VALUES (?, ?, ?)
+			ON CONFLICT(session_id, user_id) DO NOTHING
+		`, id, userID, grantedBy); err != nil {
+			return err
+		}
+		return tx.QueryRow(`
+			SELECT created_at FROM session_shares WHERE session_id = ? AND user_id = ?
+		`, id, userID).Scan(&createdAt)
+	})
+	return createdAt, err
+}
+
+func (s *sessionStore) Unshare(id, userID string) (bool, error) {
+	result, err := s.db.Exec(`DELETE FROM session_shares WHERE session_id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (s *sessionStore) Shares(id string) ([]Share, error) {
+	rows, err := s.db.Query(`
+		SELECT s.user_id, COALESCE(u.github_username, s.user_id), COALESCE(u.github_avatar_url, ''), s.created_at
+		FROM session_shares s
+		LEFT JOIN user_preferences u ON u.user_id = s.user_id
+		WHERE s.session_id = ?
+		ORDER BY s.created_at
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	shares := make([]Share, 0)
+	for rows.Next() {
+		var share Share
+		if err := rows.Scan(&share.UserID, &share.Username, &share.AvatarURL, &share.CreatedAt); err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
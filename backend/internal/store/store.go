@@ -0,0 +1,68 @@
+// Package store holds the queries behind the API. Handlers and services
+// depend on the interfaces declared here rather than on SQL, so they can be
+// exercised against an in-memory database (database.New(":memory:")) or
+// served by another backend.
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when the requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+// timestampFormat matches CURRENT_TIMESTAMP so stored times compare as text
+const timestampFormat = "2006-01-02 15:04:05"
+
+// DateFormat is the layout of snapshot dates and of dates in queries
+const DateFormat = "2006-01-02"
+
+// daysAgo is the UTC time the given number of days ago, for comparing with
+// stored timestamps
+func daysAgo(days int) string {
+	return time.Now().UTC().AddDate(0, 0, -days).Format(timestampFormat)
+}
+
+// startOfToday is midnight UTC, for comparing with stored timestamps
+func startOfToday() string {
+	return time.Now().UTC().Truncate(24 * time.Hour).Format(timestampFormat)
+}
+
+// notFound maps a missing row to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func nullString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+func nullInt64(i sql.NullInt64) *int64 {
+	if !i.Valid {
+		return nil
+	}
+	return &i.Int64
+}
+
+func nullFloat64(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
+
+// winRate is the share of games won, or 0 before any were played
+func winRate(wins, losses, draws int) float64 {
+	if games := wins + losses + draws; games > 0 {
+		return float64(wins) / float64(games)
+	}
+	return 0
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"os"
+	"testing"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+// testInitialRating is the rating of unrated models in tests, away from the
+// default so tests notice when it is not passed through
+const testInitialRating = 1200
+
+// forEachDatabase runs a test against a freshly migrated database of every
+// dialect the stores are tested with. PostgreSQL is only tested when
+// TEST_DATABASE_URL points at a database the tests may wipe.
+func forEachDatabase(t *testing.T, test func(t *testing.T, db *database.DB)) {
+	t.Helper()
+	t.Run("sqlite", func(t *testing.T) {
+		test(t, openSQLite(t))
+	})
+	t.Run("postgres", func(t *testing.T) {
+		test(t, openPostgres(t))
+	})
+}
+
+func openSQLite(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// openPostgres empties the database at TEST_DATABASE_URL and migrates it
+func openPostgres(t *testing.T) *database.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := database.NewPostgres(url)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	mustExec(t, db, `DROP SCHEMA public CASCADE`)
+	mustExec(t, db, `CREATE SCHEMA public`)
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func mustExec(t *testing.T, db *database.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// seedModels registers models named after their IDs
+func seedModels(t *testing.T, db *database.DB, ids ...string) {
+	t.Helper()
+	models := NewModelStore(db, testInitialRating)
+	for _, id := range ids {
+		if err := models.Register(id, "Model "+id, "test"); err != nil {
+			t.Fatalf("register %s: %v", id, err)
+		}
+	}
+}
+
+// seedSession stores a pending standard session
+func seedSession(t *testing.T, db *database.DB, id, userID, question string, categoryID *int64) {
+	t.Helper()
+	err := NewSessionStore(db).Create(&Session{
+		ID:         id,
+		UserID:     userID,
+		Question:   question,
+		Mode:       "standard",
+		Status:     "pending",
+		CategoryID: categoryID,
+		Config:     "{}",
+	})
+	if err != nil {
+		t.Fatalf("create session %s: %v", id, err)
+	}
+}
+
+// seedUser records a signed-in user
+func seedUser(t *testing.T, db *database.DB, id, username string) {
+	t.Helper()
+	mustExec(t, db, `INSERT INTO user_preferences (user_id, github_username) VALUES (?, ?)`, id, username)
+}
+
+// categoryID returns the ID of a seeded category
+func categoryID(t *testing.T, db *database.DB, name string) int64 {
+	t.Helper()
+	id, err := NewCategoryStore(db).IDByName(name)
+	if err != nil {
+		t.Fatalf("category %s: %v", name, err)
+	}
+	return id
+}
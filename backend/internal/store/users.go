@@ -0,0 +1,76 @@
+package store
+
+import (
+	"github.com/sainaif/council/internal/database"
+)
+
+// User is someone who signed in to this instance
+type User struct {
+	ID        string
+	Username  string
+	AvatarURL string
+}
+
+// UserStore looks up the users who signed in
+type UserStore interface {
+	// List returns every user, by username
+	List() ([]User, error)
+	// ByUsername returns the user with a GitHub username, ignoring case
+	ByUsername(username string) (*User, error)
+	// Save records a user's GitHub profile as of their latest sign-in
+	Save(user User) error
+}
+
+type userStore struct {
+	db *database.DB
+}
+
+// NewUserStore creates a user store backed by the database
+func NewUserStore(db *database.DB) UserStore {
+	return &userStore{db: db}
+}
+
+func (s *userStore) List() ([]User, error) {
+	rows, err := s.db.Query(`
+		SELECT user_id, github_username, COALESCE(github_avatar_url, '')
+		FROM user_preferences ORDER BY github_username
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	users := make([]User, 0)
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username, &u.AvatarURL); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *userStore) ByUsername(username string) (*User, error) {
+	var u User
+	err := s.db.QueryRow(`
+		SELECT user_id, github_username, COALESCE(github_avatar_url, '')
+		FROM user_preferences WHERE LOWER(github_username) = LOWER(?)
+	`, username).Scan(&u.ID, &u.Username, &u.AvatarURL)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &u, nil
+}
+
+func (s *userStore) Save(user User) error {
+	_, err := s.db.Exec(`
+		INSERT INTO user_preferences (user_id, github_username, github_avatar_url, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(user_id) DO UPDATE SET
+			github_username = excluded.github_username,
+			github_avatar_url = excluded.github_avatar_url,
+			updated_at = CURRENT_TIMESTAMP
+	`, user.ID, user.Username, user.AvatarURL)
+	return err
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+func TestUserStore(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, db *database.DB) {
+		users := NewUserStore(db)
+
+		saves := []User{
+			{ID: "u1", Username: "zed", AvatarURL: "https://example.com/zed.png"},
+			{ID: "u2", Username: "Amy"},
+			{ID: "u1", Username: "Zed-Renamed", AvatarURL: "https://example.com/new.png"}, // Profile changed on GitHub
+		}
+		for _, u := range saves {
+			if err := users.Save(u); err != nil {
+				t.Fatalf("Save(%+v): %v", u, err)
+			}
+		}
+
+		list, err := users.List()
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if want := []User{saves[1], saves[2]}; !reflect.DeepEqual(list, want) {
+			t.Errorf("List = %+v, want %+v", list, want)
+		}
+
+		tests := []struct {
+			username string
+			want     string
+			wantErr  error
+		}{
+			{"zed-renamed", "u1", nil},
+			{"AMY", "u2", nil},
+			{"zed", "", ErrNotFound},
+		}
+		for _, tt := range tests {
+			u, err := users.ByUsername(tt.username)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ByUsername(%q) error = %v, want %v", tt.username, err, tt.wantErr)
+			}
+			if err == nil && u.ID != tt.want {
+				t.Errorf("ByUsername(%q) = %+v, want %s", tt.username, u, tt.want)
+			}
+		}
+
+		// Saving a profile keeps the user's settings
+		density := "compact"
+		if _, err := NewSettingsStore(db).Update("u2", "Amy", SettingsUpdate{UIDensity: &density}); err != nil {
+			t.Fatalf("Update settings: %v", err)
+		}
+		if err := users.Save(User{ID: "u2", Username: "Amy"}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		if settings, err := NewSettingsStore(db).Get("u2"); err != nil || settings.UIDensity != density {
+			t.Errorf("settings after signing in again = %+v, %v, want density %s", settings, err, density)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+)
+
+// Actions an instance may request of the one running a session
+const (
+	RequestCancel = "cancel"
+)
+
+// Request asks the instance running a session to act on it, so its events
+// are numbered and logged in one place
+type Request struct {
+	Instance  string `json:"instance"` // Instance the request is for
+	SessionID string `json:"session_id"`
+	Action    string `json:"action"`
+}
+
+// ErrNoInstances is returned for requests to other instances when the hub
+// runs alone
+var ErrNoInstances = errors.New("no other instances to send the request to")
+
+// Broker relays numbered messages between instances serving the same
+// sessions. Each instance numbers and logs the events it publishes and
+// delivers them to its own clients; the broker hands them to the others.
+type Broker interface {
+	// Publish sends a message to the other instances
+	Publish(ctx context.Context, msg *Message) error
+	// Request sends a request to the instance it names
+	Request(ctx context.Context, req *Request) error
+	// Subscribe calls deliver with messages published by other instances,
+	// and handle with requests for this one, until ctx is done or the
+	// broker is closed
+	Subscribe(ctx context.Context, deliver func(*Message), handle func(*Request)) error
+	Close() error
+}
+
+// MemoryBroker is the broker of a single instance: there is no one else to
+// relay to, so messages only reach the local hub
+type MemoryBroker struct{}
+
+// NewMemoryBroker creates the single-instance broker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, msg *Message) error {
+	return nil
+}
+
+func (b *MemoryBroker) Request(ctx context.Context, req *Request) error {
+	return ErrNoInstances
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, deliver func(*Message), handle func(*Request)) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (b *MemoryBroker) Close() error {
+	return nil
+}
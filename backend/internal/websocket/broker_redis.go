@@ -0,0 +1,97 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisChannel is the pub/sub channel all instances share
+const redisChannel = "council:events"
+
+// RedisBroker relays messages between instances over Redis pub/sub
+type RedisBroker struct {
+	client   *redis.Client
+	instance string
+}
+
+// redisEnvelope tags a message or request with the instance that published
+// it, so instances skip their own messages
+type redisEnvelope struct {
+	Origin  string   `json:"origin"`
+	Message *Message `json:"message,omitempty"`
+	Request *Request `json:"request,omitempty"`
+}
+
+// NewRedisBroker connects to the Redis server at url ("redis://..." or
+// "rediss://...") and publishes in the name of the given instance
+func NewRedisBroker(ctx context.Context, url, instance string) (*RedisBroker, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid broker url: %w", err)
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to reach redis: %w", err)
+	}
+	return &RedisBroker{client: client, instance: instance}, nil
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, msg *Message) error {
+	payload, err := json.Marshal(redisEnvelope{Origin: b.instance, Message: msg})
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, redisChannel, payload).Err()
+}
+
+func (b *RedisBroker) Request(ctx context.Context, req *Request) error {
+	payload, err := json.Marshal(redisEnvelope{Origin: b.instance, Request: req})
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, redisChannel, payload).Err()
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, deliver func(*Message), handle func(*Request)) error {
+	pubsub := b.client.Subscribe(ctx, redisChannel)
+	defer func() { _ = pubsub.Close() }()
+
+	// Wait for the subscription so nothing published after Subscribe
+	// returns control is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var env redisEnvelope
+			if err := json.Unmarshal([]byte(m.Payload), &env); err != nil || (env.Message == nil && env.Request == nil) {
+				log.Printf("[BROKER] Ignoring malformed message: %v", err)
+				continue
+			}
+			switch {
+			case env.Origin == b.instance:
+			case env.Message != nil:
+				deliver(env.Message)
+			case env.Request.Instance == b.instance:
+				handle(env.Request)
+			}
+		}
+	}
+}
+
+func (b *RedisBroker) Close() error {
+	return b.client.Close()
+}
@@ -0,0 +1,141 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// runRedisHubs starts a hub per instance, all relaying through one Redis
+// server, and waits until each is subscribed
+func runRedisHubs(t *testing.T, instances ...string) []*Hub {
+	t.Helper()
+	mr := miniredis.RunT(t)
+
+	hubs := make([]*Hub, len(instances))
+	for i, instance := range instances {
+		broker, err := NewRedisBroker(context.Background(), "redis://"+mr.Addr(), instance)
+		if err != nil {
+			t.Fatalf("connect broker %s: %v", instance, err)
+		}
+		hub := newTestHub(t, broker)
+		go hub.Run()
+		t.Cleanup(hub.Shutdown)
+		hubs[i] = hub
+	}
+
+	waitFor(t, func() bool { return mr.PubSubNumSub(redisChannel)[redisChannel] == len(instances) })
+	return hubs
+}
+
+// waitFor fails the test unless cond becomes true within a second
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRedisBrokerRelaysEvents(t *testing.T) {
+	hubs := runRedisHubs(t, "a", "b")
+	a, b := hubs[0], hubs[1]
+
+	local, err := a.Subscribe("s1", "u1", "test", -1)
+	if err != nil {
+		t.Fatalf("subscribe a: %v", err)
+	}
+	remote, err := b.Subscribe("s1", "u1", "test", -1)
+	if err != nil {
+		t.Fatalf("subscribe b: %v", err)
+	}
+
+	a.Broadcast("s1", EventCouncilStarted, map[string]string{"mode": "standard"})
+	a.Broadcast("s1", EventVotingStarted, nil)
+
+	waitFor(t, func() bool { return len(queuedMessages(remote)) == 2 })
+	for i, msg := range queuedMessages(remote) {
+		if want := uint64(i + 1); msg.Seq != want {
+			t.Errorf("remote event %d has seq %d, want %d", i, msg.Seq, want)
+		}
+	}
+	data, _ := queuedMessages(remote)[0].Data.(map[string]interface{})
+	if data["mode"] != "standard" {
+		t.Errorf("remote event data = %v", queuedMessages(remote)[0].Data)
+	}
+
+	// Give an echo time to arrive before checking there was none
+	waitFor(t, func() bool { return a.Metrics().EventsRelayed == 2 })
+	time.Sleep(50 * time.Millisecond)
+	if got := len(queuedMessages(local)); got != 2 {
+		t.Errorf("publishing hub queued %d events, want 2", got)
+	}
+	if got := a.Metrics().EventsReceived; got != 0 {
+		t.Errorf("publishing hub received %d of its own events", got)
+	}
+	if got := b.Metrics().EventsReceived; got != 2 {
+		t.Errorf("other hub received %d events, want 2", got)
+	}
+}
+
+func TestRedisBrokerRoutesRequests(t *testing.T) {
+	hubs := runRedisHubs(t, "a", "b", "c")
+
+	var mu sync.Mutex
+	got := make(map[string][]Request)
+	for i, instance := range []string{"a", "b", "c"} {
+		hubs[i].HandleRequests(func(req *Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			got[instance] = append(got[instance], *req)
+		})
+	}
+
+	tests := []struct {
+		from   int
+		target string
+	}{
+		{0, "b"},
+		{1, "c"},
+		{0, "a"}, // Never reaches the instance that sent it
+	}
+	for _, tt := range tests {
+		req := &Request{Instance: tt.target, SessionID: "s1", Action: RequestCancel}
+		if err := hubs[tt.from].Request(context.Background(), req); err != nil {
+			t.Fatalf("request %s: %v", tt.target, err)
+		}
+	}
+
+	count := func(instance string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got[instance])
+	}
+	waitFor(t, func() bool { return count("b") == 1 && count("c") == 1 })
+	time.Sleep(50 * time.Millisecond)
+	if n := count("a"); n != 0 {
+		t.Errorf("a handled %d requests, want none", n)
+	}
+	for _, instance := range []string{"b", "c"} {
+		if n := count(instance); n != 1 {
+			t.Errorf("%s handled %d requests, want 1", instance, n)
+		}
+	}
+	if req := got["b"][0]; req.SessionID != "s1" || req.Action != RequestCancel {
+		t.Errorf("b handled %+v", req)
+	}
+}
+
+func TestMemoryBrokerRefusesRequests(t *testing.T) {
+	hub := newTestHub(t, nil)
+	err := hub.Request(context.Background(), &Request{Instance: "b", SessionID: "s1", Action: RequestCancel})
+	if err != ErrNoInstances {
+		t.Errorf("Request() = %v, want ErrNoInstances", err)
+	}
+}
@@ -0,0 +1,280 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+)
+
+const (
+	// coalesceAfter is the queue length from which a client is considered
+	// slow and new response chunks are merged into queued ones
+	coalesceAfter = 64
+	// maxQueue is the queue length at which a client is dropped; it can
+	// reconnect and replay what it missed
+	maxQueue = 256
+)
+
+// Client is a subscription to a session's events, served over a WebSocket
+// or a Server-Sent Events stream. The hub queues frames without waiting on
+// the client; a writer goroutine drains them at the client's pace.
+type Client struct {
+	Conn        *websocket.Conn // Nil for Server-Sent Events
+	SessionID   string          // The session the client connected for; empty on a user channel
+	UserID      string
+	Transport   string // TransportWebSocket or TransportSSE
+	RemoteAddr  string
+	ConnectedAt time.Time
+
+	replay   []queued        // Missed events, written before anything queued
+	lastSeen atomic.Int64    // Unix nanoseconds of the last sign of life
+	sessions map[string]bool // Every session subscribed to; guarded by the hub's mu
+
+	follows   string // User whose sessions a user channel follows
+	allEvents bool   // Whether a user channel gets every event, not only lifecycle ones
+
+	mu        sync.Mutex
+	queue     []queued
+	pending   chan struct{}  // Signalled when the queue becomes non-empty
+	done      chan struct{}  // Closed once the hub has removed the client
+	closeCode int            // Why the hub removed the client, if it was dropped
+	rejecting sync.WaitGroup // Close frames still being sent to the WebSocket
+}
+
+// Frame is an encoded Message with its sequence number
+type Frame struct {
+	Seq  uint64
+	Data []byte
+}
+
+type queued struct {
+	msg   *Message // Nil for command replies
+	frame Frame
+}
+
+// Transports a client can be served over
+const (
+	TransportWebSocket = "websocket"
+	TransportSSE       = "sse"
+)
+
+// ClientInfo describes a connected client for the admin view
+type ClientInfo struct {
+	Channel      string    `json:"channel"` // "session <id>" or "user <id>"
+	SessionID    string    `json:"session_id"`
+	UserID       string    `json:"user_id"`
+	Transport    string    `json:"transport"`
+	RemoteAddr   string    `json:"remote_addr"`
+	ConnectedAt  time.Time `json:"connected_at"`
+	LastSeenAt   time.Time `json:"last_seen_at"`
+	QueuedFrames int       `json:"queued_frames"`
+}
+
+// enqueueResult says what happened to a frame offered to a client
+type enqueueResult int
+
+const (
+	enqueued enqueueResult = iota
+	coalesced
+	overflowed
+)
+
+func newClient(conn *websocket.Conn, transport, sessionID, userID, remoteAddr string) *Client {
+	c := &Client{
+		Conn:        conn,
+		SessionID:   sessionID,
+		UserID:      userID,
+		Transport:   transport,
+		RemoteAddr:  remoteAddr,
+		ConnectedAt: time.Now(),
+		sessions:    make(map[string]bool),
+		pending:     make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	c.Touch()
+	return c
+}
+
+// channel names what the client is connected to, for logs
+func (c *Client) channel() string {
+	if c.follows != "" {
+		return "user " + c.follows
+	}
+	return "session " + c.SessionID
+}
+
+// Touch records that the client is still there, e.g. it answered a ping
+func (c *Client) Touch() {
+	c.lastSeen.Store(time.Now().UnixNano())
+}
+
+// Info describes the client for the admin view
+func (c *Client) Info() ClientInfo {
+	return ClientInfo{
+		Channel:      c.channel(),
+		SessionID:    c.SessionID,
+		UserID:       c.UserID,
+		Transport:    c.Transport,
+		RemoteAddr:   c.RemoteAddr,
+		ConnectedAt:  c.ConnectedAt,
+		LastSeenAt:   time.Unix(0, c.lastSeen.Load()),
+		QueuedFrames: c.queueLen(),
+	}
+}
+
+// Replay returns the events the client missed before it connected
+func (c *Client) Replay() []Frame {
+	frames := make([]Frame, len(c.replay))
+	for i, q := range c.replay {
+		frames[i] = q.frame
+	}
+	return frames
+}
+
+// Pending is signalled when frames are waiting to be drained
+func (c *Client) Pending() <-chan struct{} {
+	return c.pending
+}
+
+// Done is closed when the client was unsubscribed, dropped for being too
+// slow or the hub shut down
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Drain takes all queued frames, oldest first
+func (c *Client) Drain() []Frame {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	frames := make([]Frame, len(c.queue))
+	for i, q := range c.queue {
+		frames[i] = q.frame
+	}
+	c.queue = nil
+	return frames
+}
+
+// queueLen returns the number of frames waiting to be written
+func (c *Client) queueLen() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.queue)
+}
+
+// enqueue offers a frame without blocking. A slow client gets response
+// chunks merged into ones it has not read yet; one that falls maxQueue
+// frames behind overflows.
+func (c *Client) enqueue(msg *Message, frame Frame) enqueueResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := enqueued
+	switch {
+	case len(c.queue) >= coalesceAfter && c.coalesce(msg):
+		result = coalesced
+	case len(c.queue) >= maxQueue:
+		return overflowed
+	default:
+		c.queue = append(c.queue, queued{msg: msg, frame: frame})
+	}
+
+	select {
+	case c.pending <- struct{}{}:
+	default:
+	}
+	return result
+}
+
+// insertReplay queues the missed events of a session added to the client
+// ahead of that session's live events, which all came after them
+func (c *Client) insertReplay(sessionID string, replay []queued) {
+	if len(replay) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	at := len(c.queue)
+	for i, q := range c.queue {
+		if q.msg != nil && q.msg.SessionID == sessionID {
+			at = i
+			break
+		}
+	}
+	queue := make([]queued, 0, len(c.queue)+len(replay))
+	queue = append(queue, c.queue[:at]...)
+	queue = append(queue, replay...)
+	c.queue = append(queue, c.queue[at:]...)
+
+	select {
+	case c.pending <- struct{}{}:
+	default:
+	}
+}
+
+// coalesce merges a response chunk into the last queued chunk of the same
+// session and label. Only the trailing run of chunks is searched, and the merged chunk
+// moves to the end with the newest sequence number, so frames stay in
+// sequence order. Callers hold mu.
+func (c *Client) coalesce(msg *Message) bool {
+	label, ok := chunkLabel(msg)
+	if !ok {
+		return false
+	}
+
+	for i := len(c.queue) - 1; i >= 0; i-- {
+		prev := c.queue[i].msg
+		if prev == nil || prev.Event != EventModelResponseChunk {
+			return false
+		}
+		if prevLabel, _ := chunkLabel(prev); prevLabel != label || prev.SessionID != msg.SessionID {
+			continue
+		}
+
+		merged := make(map[string]interface{})
+		for k, v := range prev.Data.(map[string]interface{}) {
+			merged[k] = v
+		}
+		for k, v := range msg.Data.(map[string]interface{}) {
+			merged[k] = v
+		}
+		prevContent, _ := prev.Data.(map[string]interface{})["content"].(string)
+		content, _ := msg.Data.(map[string]interface{})["content"].(string)
+		merged["content"] = prevContent + content
+
+		mergedMsg := &Message{SessionID: msg.SessionID, Seq: msg.Seq, Event: msg.Event, Data: merged}
+		data, err := json.Marshal(mergedMsg)
+		if err != nil {
+			return false
+		}
+
+		c.queue = append(c.queue[:i], c.queue[i+1:]...)
+		c.queue = append(c.queue, queued{msg: mergedMsg, frame: Frame{Seq: msg.Seq, Data: data}})
+		return true
+	}
+	return false
+}
+
+// close marks the client removed. Callers hold the hub's mu.
+func (c *Client) close(code int) {
+	c.closeCode = code
+	close(c.done)
+}
+
+// chunkLabel returns the anonymous label of a response chunk
+func chunkLabel(msg *Message) (string, bool) {
+	if msg == nil || msg.Event != EventModelResponseChunk {
+		return "", false
+	}
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	label, ok := data["label"].(string)
+	return label, ok
+}
@@ -0,0 +1,156 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+)
+
+// Commands a client can send over its WebSocket
+const (
+	CommandPing           = "ping"
+	CommandSubscribe      = "subscribe"
+	CommandVote           = "vote"
+	CommandCancel         = "cancel"
+	CommandInjectFollowUp = "inject_followup"
+)
+
+// Events answering a command, carrying its id
+const (
+	EventCommandAck   = "command.ack"
+	EventCommandError = "command.error"
+)
+
+// Codes of command errors
+const (
+	CodeBadRequest     = "bad_request"
+	CodeUnauthorized   = "unauthorized"
+	CodeForbidden      = "forbidden"
+	CodeNotFound       = "not_found"
+	CodeUnknownCommand = "unknown_command"
+	CodeInternal       = "internal"
+)
+
+const (
+	commandTimeout = 30 * time.Second
+	maxCommandSize = 64 * 1024
+)
+
+// Command is a request sent by a client over its WebSocket
+type Command struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	SessionID string          `json:"session_id,omitempty"` // Defaults to the session the socket was opened for
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// CommandError is a failure reported back to the client with its code
+type CommandError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *CommandError) Error() string {
+	return e.Message
+}
+
+// CommandFunc runs a command for the user of a connection and returns the
+// acknowledgement's data. For subscribe it only decides whether the user may
+// watch the session; the hub does the subscribing. The council handlers
+// provide it, since the hub cannot depend on them.
+type CommandFunc func(ctx context.Context, cmd *Command) (interface{}, error)
+
+// reply answers a command. Unlike events it has no sequence number.
+type reply struct {
+	ID        string      `json:"id"`
+	SessionID string      `json:"session_id,omitempty"`
+	Event     string      `json:"event"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+type subscribeData struct {
+	LastSeq *int64 `json:"last_seq"`
+}
+
+// handleCommand runs a command a client sent and queues the reply
+func (h *Hub) handleCommand(client *Client, raw []byte, commands CommandFunc) {
+	var cmd Command
+	if err := json.Unmarshal(raw, &cmd); err != nil || cmd.Type == "" {
+		h.reply(client, &cmd, nil, &CommandError{Code: CodeBadRequest, Message: "Commands must be JSON objects with a type"})
+		return
+	}
+	if cmd.SessionID == "" {
+		cmd.SessionID = client.SessionID
+	}
+
+	var data interface{}
+	var err error
+	switch {
+	case cmd.Type == CommandPing:
+		data = map[string]interface{}{"time": time.Now().UTC()}
+	case commands == nil:
+		err = &CommandError{Code: CodeForbidden, Message: "Commands are not available on this connection"}
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+		data, err = commands(ctx, &cmd)
+		cancel()
+		if err == nil && cmd.Type == CommandSubscribe {
+			data, err = h.subscribeCommand(client, &cmd)
+		}
+	}
+	h.reply(client, &cmd, data, err)
+}
+
+// subscribeCommand adds another session to the client's connection,
+// replaying events after last_seq when given
+func (h *Hub) subscribeCommand(client *Client, cmd *Command) (interface{}, error) {
+	var req subscribeData
+	if len(cmd.Data) > 0 {
+		if err := json.Unmarshal(cmd.Data, &req); err != nil {
+			return nil, &CommandError{Code: CodeBadRequest, Message: "Invalid subscribe data"}
+		}
+	}
+	lastSeq := int64(-1)
+	if req.LastSeq != nil && *req.LastSeq >= 0 {
+		lastSeq = *req.LastSeq
+	}
+
+	replayed, err := h.addSession(client, cmd.SessionID, lastSeq)
+	switch {
+	case errors.Is(err, ErrTooManyLiveSessions):
+		return nil, &CommandError{Code: CodeForbidden, Message: "Too many live connections for this session"}
+	case err != nil:
+		return nil, &CommandError{Code: CodeBadRequest, Message: "Connection is closing"}
+	}
+	return map[string]interface{}{"replayed": replayed}, nil
+}
+
+// reply queues the acknowledgement or error for a command
+func (h *Hub) reply(client *Client, cmd *Command, data interface{}, err error) {
+	r := reply{ID: cmd.ID, SessionID: cmd.SessionID, Event: EventCommandAck, Data: data}
+	if err != nil {
+		var cmdErr *CommandError
+		if !errors.As(err, &cmdErr) {
+			log.Printf("WebSocket command %s failed for session %s: %v", cmd.Type, cmd.SessionID, err)
+			cmdErr = &CommandError{Code: CodeInternal, Message: "Command failed"}
+		}
+		r.Event, r.Data = EventCommandError, cmdErr
+	}
+
+	encoded, err := json.Marshal(r)
+	if err != nil {
+		log.Printf("Error marshaling reply: %v", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if client.enqueue(nil, Frame{Data: encoded}) == overflowed {
+		h.metrics.ClientsDropped++
+		h.remove(client, websocket.CloseTryAgainLater)
+	}
+}
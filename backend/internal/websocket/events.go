@@ -0,0 +1,303 @@
+package websocket
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sainaif/council/internal/database"
+)
+
+const (
+	// maxLoggedSessions caps how many sessions keep events in memory; older
+	// ones are served from the database
+	maxLoggedSessions = 200
+
+	flushInterval = 250 * time.Millisecond
+	pruneInterval = time.Hour
+)
+
+// EventLog numbers every event of a session, keeps the most recent ones in
+// a bounded buffer per session and persists all of them, so subscribers
+// that connect late can catch up from the last sequence number they saw
+type EventLog struct {
+	db        *database.DB
+	capacity  int
+	retention time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*sessionLog
+	pending  []*Message // Appended but not yet written
+
+	flushMu  sync.Mutex
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+type sessionLog struct {
+	lastSeq  uint64
+	events   []*Message // Oldest first, at most capacity
+	lastUsed time.Time
+}
+
+// NewEventLog creates an event log keeping capacity events per session in
+// memory and persisted events for the retention period (0 keeps them)
+func NewEventLog(db *database.DB, capacity int, retention time.Duration) *EventLog {
+	return &EventLog{
+		db:        db,
+		capacity:  capacity,
+		retention: retention,
+		sessions:  make(map[string]*sessionLog),
+		shutdown:  make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Run writes appended events to the database until Shutdown
+func (l *EventLog) Run() {
+	defer close(l.done)
+
+	flush := time.NewTicker(flushInterval)
+	defer flush.Stop()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-flush.C:
+			l.Flush()
+		case <-prune.C:
+			l.prune()
+		case <-l.shutdown:
+			l.Flush()
+			return
+		}
+	}
+}
+
+// Shutdown writes pending events and stops the log
+func (l *EventLog) Shutdown() {
+	close(l.shutdown)
+	<-l.done
+}
+
+// Load brings a session's numbering into memory, continuing from its last
+// persisted event. It reads the database, so the hub calls it before taking
+// the lock broadcasts wait on; the methods below then find the session in
+// memory.
+func (l *EventLog) Load(sessionID string) {
+	l.mu.Lock()
+	if sl, ok := l.sessions[sessionID]; ok {
+		sl.lastUsed = time.Now()
+		l.mu.Unlock()
+		return
+	}
+	l.mu.Unlock()
+
+	var lastSeq uint64
+	if err := l.db.QueryRow(`
+		SELECT COALESCE(MAX(seq), 0) FROM session_events WHERE session_id = ?
+	`, sessionID).Scan(&lastSeq); err != nil {
+		log.Printf("[EVENTS] Failed to read last sequence of session %s: %v", sessionID, err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.sessions[sessionID]; !ok {
+		l.add(sessionID, lastSeq)
+	}
+}
+
+// Append assigns the message the next sequence number of its session and
+// records it
+func (l *EventLog) Append(msg *Message) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sl := l.session(msg.SessionID)
+	sl.lastSeq++
+	sl.lastUsed = time.Now()
+	msg.Seq = sl.lastSeq
+
+	sl.events = append(sl.events, msg)
+	if len(sl.events) > l.capacity {
+		sl.events = sl.events[len(sl.events)-l.capacity:]
+	}
+	l.pending = append(l.pending, msg)
+}
+
+// Observe records a message another instance numbered and persisted, so
+// clients here can catch up from it. Older buffered events are dropped
+// when messages were missed, leaving those to the database.
+func (l *EventLog) Observe(msg *Message) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sl := l.session(msg.SessionID)
+	sl.lastUsed = time.Now()
+	if msg.Seq <= sl.lastSeq {
+		return
+	}
+	if msg.Seq != sl.lastSeq+1 {
+		sl.events = nil
+	}
+	sl.lastSeq = msg.Seq
+
+	sl.events = append(sl.events, msg)
+	if len(sl.events) > l.capacity {
+		sl.events = sl.events[len(sl.events)-l.capacity:]
+	}
+}
+
+// LastSeq returns the sequence number of the session's latest event
+func (l *EventLog) LastSeq(sessionID string) uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.session(sessionID).lastSeq
+}
+
+// Since returns the session's events after lastSeq, in order. Events that
+// have left the in-memory buffer are read from the database.
+func (l *EventLog) Since(sessionID string, lastSeq uint64) ([]*Message, error) {
+	l.mu.Lock()
+	if sl, ok := l.sessions[sessionID]; ok {
+		if lastSeq >= sl.lastSeq {
+			l.mu.Unlock()
+			return nil, nil
+		}
+		if len(sl.events) > 0 && sl.events[0].Seq <= lastSeq+1 {
+			var missed []*Message
+			for _, msg := range sl.events {
+				if msg.Seq > lastSeq {
+					missed = append(missed, msg)
+				}
+			}
+			l.mu.Unlock()
+			return missed, nil
+		}
+	}
+	l.mu.Unlock()
+
+	// Make sure everything appended so far is in the database
+	l.Flush()
+
+	rows, err := l.db.Query(`
+		SELECT seq, event, data FROM session_events
+		WHERE session_id = ? AND seq > ?
+		ORDER BY seq
+	`, sessionID, lastSeq)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var missed []*Message
+	for rows.Next() {
+		msg := &Message{SessionID: sessionID}
+		var data sql.NullString
+		if err := rows.Scan(&msg.Seq, &msg.Event, &data); err != nil {
+			return nil, err
+		}
+		if data.Valid {
+			msg.Data = json.RawMessage(data.String)
+		}
+		missed = append(missed, msg)
+	}
+	return missed, rows.Err()
+}
+
+// Flush writes pending events to the database
+func (l *EventLog) Flush() {
+	l.flushMu.Lock()
+	defer l.flushMu.Unlock()
+
+	l.mu.Lock()
+	pending := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	err := l.db.WithTx(func(tx *sql.Tx) error {
+		for _, msg := range pending {
+			var data interface{}
+			if msg.Data != nil {
+				raw, err := json.Marshal(msg.Data)
+				if err != nil {
+					return err
+				}
+				data = string(raw)
+			}
+			if _, err := tx.Exec(`
+				INSERT INTO session_events (session_id, seq, event, data) VALUES (?, ?, ?, ?)
+				ON CONFLICT(session_id, seq) DO NOTHING
+			`, msg.SessionID, msg.Seq, msg.Event, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[EVENTS] Failed to persist %d events: %v", len(pending), err)
+	}
+}
+
+// session returns the in-memory log of a session loaded with Load. It never
+// touches the database: a session missing here, evicted since it was
+// loaded, continues from its events not yet written. Callers hold mu.
+func (l *EventLog) session(sessionID string) *sessionLog {
+	if sl, ok := l.sessions[sessionID]; ok {
+		return sl
+	}
+	return l.add(sessionID, 0)
+}
+
+// add puts a session in memory, numbering after lastSeq and any of its
+// pending events. Callers hold mu.
+func (l *EventLog) add(sessionID string, lastSeq uint64) *sessionLog {
+	sl := &sessionLog{lastSeq: lastSeq, lastUsed: time.Now()}
+	for _, msg := range l.pending {
+		if msg.SessionID == sessionID && msg.Seq > sl.lastSeq {
+			sl.lastSeq = msg.Seq
+		}
+	}
+
+	if len(l.sessions) >= maxLoggedSessions {
+		l.evictOldest()
+	}
+	l.sessions[sessionID] = sl
+	return sl
+}
+
+// evictOldest drops the least recently used session from memory. Callers hold mu.
+func (l *EventLog) evictOldest() {
+	var oldestID string
+	var oldest time.Time
+	for id, sl := range l.sessions {
+		if oldestID == "" || sl.lastUsed.Before(oldest) {
+			oldestID, oldest = id, sl.lastUsed
+		}
+	}
+	delete(l.sessions, oldestID)
+}
+
+// prune deletes persisted events older than the retention period
+func (l *EventLog) prune() {
+	if l.retention <= 0 {
+		return
+	}
+
+	cutoff := time.Now().UTC().Add(-l.retention).Format("2006-01-02 15:04:05")
+	result, err := l.db.Exec(`DELETE FROM session_events WHERE created_at < ?`, cutoff)
+	if err != nil {
+		log.Printf("[EVENTS] Failed to prune old events: %v", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("[EVENTS] Pruned %d events older than %s", n, l.retention)
+	}
+}
@@ -1,166 +1,670 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"net"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gofiber/contrib/websocket"
+
+	"github.com/sainaif/council/internal/store"
 )
 
 type Message struct {
 	SessionID string      `json:"session_id"`
+	Seq       uint64      `json:"seq"` // Increases by one per event of the session
 	Event     string      `json:"event"`
 	Data      interface{} `json:"data"`
 }
 
-type Client struct {
-	Conn      *websocket.Conn
-	SessionID string
-	Send      chan []byte
+type Hub struct {
+	// mu orders numbering and delivery, so every client sees a session's
+	// events in sequence. Nothing under it waits on a client.
+	mu       sync.Mutex
+	clients  map[*Client]bool
+	sessions map[string]map[*Client]bool
+	users    map[string]int // Connected clients per user
+	// User channel clients by the user whose sessions they follow, and the
+	// owners of sessions they were looked up for
+	userClients map[string]map[*Client]bool
+	owners      map[string]string
+	closed      bool
+	metrics     HubMetrics
+	events      *EventLog
+	store       store.SessionStore
+	config      HubConfig
+
+	// Events published here, waiting to be relayed to other instances.
+	// Nothing is relayed by a hub running alone.
+	relaying bool
+	relay    chan *Message
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	// Acts on requests other instances send for sessions run here
+	requests func(*Request)
 }
 
-type Hub struct {
-	clients    map[*Client]bool
-	sessions   map[string]map[*Client]bool
-	broadcast  chan *Message
-	register   chan *Client
-	unregister chan *Client
-	mu         sync.RWMutex
-	shutdown   chan struct{}
+// HubConfig sets heartbeats and connection limits
+type HubConfig struct {
+	PingInterval         time.Duration // Between WebSocket pings and SSE keep-alives
+	PongTimeout          time.Duration // Silence after which a WebSocket is closed
+	MaxClientsPerUser    int           // 0 is unlimited
+	MaxClientsPerSession int           // 0 is unlimited
+	Broker               Broker        // Relays events between instances; nil runs alone
+}
+
+// relayBuffer is how many events may wait to be relayed before new ones
+// are dropped
+const relayBuffer = 1024
+
+// Errors returned by Subscribe
+var (
+	ErrHubClosed           = errors.New("hub is shut down")
+	ErrTooManyUserClients  = errors.New("too many live connections for this user")
+	ErrTooManyLiveSessions = errors.New("too many live connections for this session")
+)
+
+// writeWait bounds how long a single write may take before the
+// connection is considered dead
+const writeWait = 10 * time.Second
+
+// HubMetrics counts what the hub delivered since startup
+type HubMetrics struct {
+	Clients         int    `json:"clients"`           // Currently subscribed
+	Sessions        int    `json:"sessions"`          // Sessions with at least one subscriber
+	QueuedFrames    int    `json:"queued_frames"`     // Frames waiting for slow clients right now
+	Broadcasts      uint64 `json:"broadcasts"`        // Events numbered and logged
+	FramesDelivered uint64 `json:"frames_delivered"`  // Frames queued for clients
+	FramesCoalesced uint64 `json:"frames_coalesced"`  // Response chunks merged into earlier ones
+	ClientsDropped  uint64 `json:"clients_dropped"`   // Clients removed for falling too far behind
+	ClientsTimedOut uint64 `json:"clients_timed_out"` // WebSockets closed for missing heartbeats
+	ClientsRejected uint64 `json:"clients_rejected"`  // Subscriptions refused by connection limits
+	QueueHighWater  int    `json:"queue_high_water"`  // Longest queue seen
+	EventsRelayed   uint64 `json:"events_relayed"`    // Events sent to other instances
+	EventsReceived  uint64 `json:"events_received"`   // Events received from other instances
+	RelayDropped    uint64 `json:"relay_dropped"`     // Events not relayed because the broker fell behind
+}
+
+// HubStats is the admin view of the hub: its counters, limits and clients
+type HubStats struct {
+	HubMetrics
+	MaxClientsPerUser    int          `json:"max_clients_per_user"`
+	MaxClientsPerSession int          `json:"max_clients_per_session"`
+	ConnectedClients     []ClientInfo `json:"connected_clients"`
 }
 
-func NewHub() *Hub {
+// NewHub creates a hub whose events are numbered and logged for replay.
+// Session owners are looked up in sessions to reach their user channels.
+func NewHub(events *EventLog, sessions store.SessionStore, config HubConfig) *Hub {
+	if config.Broker == nil {
+		config.Broker = NewMemoryBroker()
+	}
+	_, alone := config.Broker.(*MemoryBroker)
 	return &Hub{
-		clients:    make(map[*Client]bool),
-		sessions:   make(map[string]map[*Client]bool),
-		broadcast:  make(chan *Message, 256),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		shutdown:   make(chan struct{}),
+		events:   events,
+		store:    sessions,
+		config:   config,
+		clients:  make(map[*Client]bool),
+		sessions: make(map[string]map[*Client]bool),
+		users:    make(map[string]int),
+
+		userClients: make(map[string]map[*Client]bool),
+		owners:      make(map[string]string),
+
+		relaying: !alone,
+		relay:    make(chan *Message, relayBuffer),
+		done:     make(chan struct{}),
 	}
 }
 
+// Run persists logged events and exchanges them with other instances
+// until Shutdown
 func (h *Hub) Run() {
-	for {
-		select {
-		case client := <-h.register:
-			h.mu.Lock()
-			h.clients[client] = true
-			if h.sessions[client.SessionID] == nil {
-				h.sessions[client.SessionID] = make(map[*Client]bool)
-			}
-			h.sessions[client.SessionID][client] = true
-			h.mu.Unlock()
-			log.Printf("Client connected to session %s", client.SessionID)
-
-		case client := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				if h.sessions[client.SessionID] != nil {
-					delete(h.sessions[client.SessionID], client)
-					if len(h.sessions[client.SessionID]) == 0 {
-						delete(h.sessions, client.SessionID)
-					}
-				}
-				close(client.Send)
-			}
-			h.mu.Unlock()
-			log.Printf("Client disconnected from session %s", client.SessionID)
+	ctx, cancel := context.WithCancel(context.Background())
+	h.mu.Lock()
+	h.cancel = cancel
+	h.mu.Unlock()
 
-		case message := <-h.broadcast:
-			h.mu.RLock()
-			clients := h.sessions[message.SessionID]
-			h.mu.RUnlock()
+	go h.relayEvents(ctx)
+	go func() {
+		if err := h.config.Broker.Subscribe(ctx, h.deliverRemote, h.handleRequest); err != nil && ctx.Err() == nil {
+			log.Printf("[BROKER] Subscription ended, events from other instances will be missed: %v", err)
+		}
+	}()
+	h.events.Run()
+}
 
-			data, err := json.Marshal(message)
-			if err != nil {
-				log.Printf("Error marshaling message: %v", err)
-				continue
-			}
+// Shutdown disconnects every client and flushes the event log
+func (h *Hub) Shutdown() {
+	h.mu.Lock()
+	h.closed = true
+	for client := range h.clients {
+		h.remove(client, websocket.CloseGoingAway)
+	}
+	close(h.relay)
+	cancel := h.cancel
+	h.mu.Unlock()
 
-			for client := range clients {
-				select {
-				case client.Send <- data:
-				default:
-					h.unregister <- client
-				}
-			}
+	if cancel != nil {
+		<-h.done
+		cancel()
+	}
+	if err := h.config.Broker.Close(); err != nil {
+		log.Printf("[BROKER] Error closing broker: %v", err)
+	}
+	h.events.Shutdown()
+}
 
-		case <-h.shutdown:
-			h.mu.Lock()
-			for client := range h.clients {
-				close(client.Send)
-			}
-			h.clients = make(map[*Client]bool)
-			h.sessions = make(map[string]map[*Client]bool)
+// Metrics returns a snapshot of the hub's counters
+func (h *Hub) Metrics() HubMetrics {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	m := h.metrics
+	m.Clients = len(h.clients)
+	m.Sessions = len(h.sessions)
+	for client := range h.clients {
+		m.QueuedFrames += client.queueLen()
+	}
+	return m
+}
+
+// Stats lists connected clients, oldest first, along with the counters
+func (h *Hub) Stats() HubStats {
+	stats := HubStats{
+		HubMetrics:           h.Metrics(),
+		MaxClientsPerUser:    h.config.MaxClientsPerUser,
+		MaxClientsPerSession: h.config.MaxClientsPerSession,
+		ConnectedClients:     []ClientInfo{},
+	}
+
+	h.mu.Lock()
+	for client := range h.clients {
+		stats.ConnectedClients = append(stats.ConnectedClients, client.Info())
+	}
+	h.mu.Unlock()
+
+	sort.Slice(stats.ConnectedClients, func(i, j int) bool {
+		return stats.ConnectedClients[i].ConnectedAt.Before(stats.ConnectedClients[j].ConnectedAt)
+	})
+	return stats
+}
+
+// KeepAliveInterval is how often idle streams should show signs of life
+func (h *Hub) KeepAliveInterval() time.Duration {
+	return h.config.PingInterval
+}
+
+// Subscribe registers a Server-Sent Events client for a session's events.
+// With lastSeq zero or above, the events after that sequence number are
+// replayed ahead of new ones; a negative lastSeq only receives new events.
+func (h *Hub) Subscribe(sessionID, userID, remoteAddr string, lastSeq int64) (*Client, error) {
+	return h.subscribe(newClient(nil, TransportSSE, strings.Clone(sessionID), userID, remoteAddr), lastSeq)
+}
+
+func (h *Hub) subscribe(client *Client, lastSeq int64) (*Client, error) {
+	if client.follows == "" {
+		h.events.Load(client.SessionID)
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil, ErrHubClosed
+	}
+	if max := h.config.MaxClientsPerUser; max > 0 && h.users[client.UserID] >= max {
+		h.metrics.ClientsRejected++
+		h.mu.Unlock()
+		return nil, ErrTooManyUserClients
+	}
+	if max := h.config.MaxClientsPerSession; max > 0 && len(h.sessions[client.SessionID]) >= max {
+		h.metrics.ClientsRejected++
+		h.mu.Unlock()
+		return nil, ErrTooManyLiveSessions
+	}
+	h.users[client.UserID]++
+	h.clients[client] = true
+	if client.follows != "" {
+		if h.userClients[client.follows] == nil {
+			h.userClients[client.follows] = make(map[*Client]bool)
+		}
+		h.userClients[client.follows][client] = true
+		h.mu.Unlock()
+		log.Printf("Client connected to %s", client.channel())
+		return client, nil
+	}
+	h.join(client, client.SessionID)
+	// Events up to here are replayed, later ones arrive through the queue
+	upTo := h.events.LastSeq(client.SessionID)
+	h.mu.Unlock()
+
+	if lastSeq >= 0 && uint64(lastSeq) < upTo {
+		client.replay = h.replayFor(client.SessionID, uint64(lastSeq), upTo)
+	}
+	log.Printf("Client connected to %s", client.channel())
+	return client, nil
+}
+
+// addSession subscribes a connected client to another session and queues
+// the events after lastSeq (none when negative). It returns how many events
+// were replayed.
+func (h *Hub) addSession(client *Client, sessionID string, lastSeq int64) (int, error) {
+	sessionID = strings.Clone(sessionID)
+	h.events.Load(sessionID)
+
+	h.mu.Lock()
+	if h.closed || !h.clients[client] {
+		h.mu.Unlock()
+		return 0, ErrHubClosed
+	}
+	if !client.sessions[sessionID] {
+		if max := h.config.MaxClientsPerSession; max > 0 && len(h.sessions[sessionID]) >= max {
+			h.metrics.ClientsRejected++
 			h.mu.Unlock()
-			return
+			return 0, ErrTooManyLiveSessions
 		}
+		h.join(client, sessionID)
+	}
+	upTo := h.events.LastSeq(sessionID)
+	h.mu.Unlock()
+
+	if lastSeq < 0 || uint64(lastSeq) >= upTo {
+		return 0, nil
 	}
+	replay := h.replayFor(sessionID, uint64(lastSeq), upTo)
+	client.insertReplay(sessionID, replay)
+	return len(replay), nil
 }
 
-func (h *Hub) Shutdown() {
-	close(h.shutdown)
+// join adds a client to a session's subscribers. Callers hold mu.
+func (h *Hub) join(client *Client, sessionID string) {
+	client.sessions[sessionID] = true
+	if h.sessions[sessionID] == nil {
+		h.sessions[sessionID] = make(map[*Client]bool)
+	}
+	h.sessions[sessionID][client] = true
+}
+
+// Unsubscribe removes a client
+func (h *Hub) Unsubscribe(client *Client) {
+	h.mu.Lock()
+	removed := h.remove(client, 0)
+	h.mu.Unlock()
+
+	if removed {
+		log.Printf("Client disconnected from %s", client.channel())
+	}
+}
+
+// remove drops a client from the hub and reports whether it was still
+// subscribed. A non-zero code closes its WebSocket with that code. Callers
+// hold mu.
+func (h *Hub) remove(client *Client, code int) bool {
+	if !h.clients[client] {
+		return false
+	}
+	delete(h.clients, client)
+	if h.users[client.UserID]--; h.users[client.UserID] <= 0 {
+		delete(h.users, client.UserID)
+	}
+	for sessionID := range client.sessions {
+		delete(h.sessions[sessionID], client)
+		if len(h.sessions[sessionID]) == 0 {
+			delete(h.sessions, sessionID)
+		}
+	}
+	if client.follows != "" {
+		delete(h.userClients[client.follows], client)
+		if len(h.userClients[client.follows]) == 0 {
+			delete(h.userClients, client.follows)
+		}
+	}
+	client.close(code)
+
+	// Unblocks a writer stuck on a stalled connection; safe alongside writes
+	if code != 0 && client.Conn != nil {
+		client.rejecting.Add(1)
+		go func() {
+			defer client.rejecting.Done()
+			h.Reject(client.Conn, code, closeReason(code))
+		}()
+	}
+	return true
+}
+
+func closeReason(code int) string {
+	switch code {
+	case websocket.CloseTryAgainLater:
+		return "Too slow, reconnect to catch up"
+	case CloseTooManyConnections:
+		return "Too many live connections"
+	}
+	return "Server is shutting down"
+}
+
+// replayFor returns the events of a session after lastSeq, up to upTo
+func (h *Hub) replayFor(sessionID string, lastSeq, upTo uint64) []queued {
+	missed, err := h.events.Since(sessionID, lastSeq)
+	if err != nil {
+		log.Printf("Error loading missed events for session %s: %v", sessionID, err)
+		return nil
+	}
+
+	replay := make([]queued, 0, len(missed))
+	for _, msg := range missed {
+		if msg.Seq > upTo {
+			break
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			log.Printf("Error marshaling message: %v", err)
+			continue
+		}
+		replay = append(replay, queued{msg: msg, frame: Frame{Seq: msg.Seq, Data: data}})
+	}
+	return replay
 }
 
-func (h *Hub) HandleConnection(c *websocket.Conn, sessionID string) {
-	client := &Client{
-		Conn:      c,
-		SessionID: sessionID,
-		Send:      make(chan []byte, 256),
+// HandleConnection streams a session's events to a WebSocket client,
+// replaying those after lastSeq first (see Subscribe), and runs the
+// commands the client sends with commands. The connection is pinged every
+// PingInterval and closed after PongTimeout without a pong or message.
+func (h *Hub) HandleConnection(c *websocket.Conn, sessionID, userID string, lastSeq int64, commands CommandFunc) {
+	client, err := h.subscribe(newClient(c, TransportWebSocket, strings.Clone(sessionID), userID, c.RemoteAddr().String()), lastSeq)
+	switch {
+	case errors.Is(err, ErrHubClosed):
+		h.Reject(c, websocket.CloseGoingAway, closeReason(websocket.CloseGoingAway))
+		return
+	case err != nil:
+		h.Reject(c, CloseTooManyConnections, closeReason(CloseTooManyConnections))
+		return
 	}
+	h.serve(c, client, commands)
+}
 
-	h.register <- client
+// serve writes a WebSocket client's events and runs the commands it sends
+// until it disconnects, misses its heartbeat or is dropped
+func (h *Hub) serve(c *websocket.Conn, client *Client, commands CommandFunc) {
+	c.SetReadLimit(maxCommandSize)
+	_ = c.SetReadDeadline(time.Now().Add(h.config.PongTimeout))
+	c.SetPongHandler(func(string) error {
+		client.Touch()
+		return c.SetReadDeadline(time.Now().Add(h.config.PongTimeout))
+	})
 
 	// Writer goroutine
+	written := make(chan struct{})
 	go func() {
+		ping := time.NewTicker(h.config.PingInterval)
 		defer func() {
+			ping.Stop()
 			_ = c.Close()
+			close(written)
 		}()
-		for message := range client.Send {
-			if err := c.WriteMessage(websocket.TextMessage, message); err != nil {
+
+		write := func(frames []Frame) bool {
+			for _, frame := range frames {
+				_ = c.SetWriteDeadline(time.Now().Add(writeWait))
+				if err := c.WriteMessage(websocket.TextMessage, frame.Data); err != nil {
+					return false
+				}
+			}
+			return true
+		}
+
+		if !write(client.Replay()) {
+			return
+		}
+		for {
+			select {
+			case <-client.Pending():
+				if !write(client.Drain()) {
+					return
+				}
+			case <-ping.C:
+				if err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+					return
+				}
+			case <-client.Done():
 				return
 			}
 		}
 	}()
 
-	// Reader goroutine (mainly for keeping connection alive)
+	// Reader goroutine: runs commands and detects closes and missed heartbeats.
+	// The connection is released once serve returns, so wait for the writer
+	// and any close frame the hub is sending.
 	defer func() {
-		h.unregister <- client
+		h.Unsubscribe(client)
 		_ = c.Close()
+		<-written
+		client.rejecting.Wait()
 	}()
 
 	for {
-		_, _, err := c.ReadMessage()
+		messageType, data, err := c.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				h.mu.Lock()
+				h.metrics.ClientsTimedOut++
+				h.mu.Unlock()
+				log.Printf("WebSocket for %s missed its heartbeat", client.channel())
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
+		client.Touch()
+		_ = c.SetReadDeadline(time.Now().Add(h.config.PongTimeout))
+
+		if messageType == websocket.TextMessage {
+			h.handleCommand(client, data, commands)
+		}
 	}
 }
 
-// Broadcast sends a message to all clients in a session
+// Close codes sent when a subscription is refused. They mirror the HTTP
+// status in the private 4000-4999 range so browsers can tell them apart.
+const (
+	CloseUnauthorized = 4401
+	CloseForbidden    = 4403
+	CloseNotFound     = 4404
+
+	// CloseInternalError is sent when the server could not decide
+	CloseInternalError = 4500
+
+	// CloseTooManyConnections is sent when a connection limit is reached
+	CloseTooManyConnections = 4429
+)
+
+// Reject closes a connection that may not subscribe, with a close code and
+// reason the client can read
+func (h *Hub) Reject(c *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	_ = c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	_ = c.Close()
+}
+
+// Broadcast sends a message to all clients in a session. It never waits
+// on clients: slow ones get chunks coalesced and are eventually dropped.
 func (h *Hub) Broadcast(sessionID, event string, data interface{}) {
-	h.broadcast <- &Message{
-		SessionID: sessionID,
+	h.publish(&Message{
+		SessionID: strings.Clone(sessionID), // May come from a reused Fiber request buffer
 		Event:     event,
 		Data:      data,
-	}
+	})
 }
 
 // BroadcastToSession sends an event to all clients watching a specific session
 func (h *Hub) BroadcastToSession(sessionID string, msg *Message) {
-	msg.SessionID = sessionID
-	h.broadcast <- msg
+	msg.SessionID = strings.Clone(sessionID)
+	h.publish(msg)
+}
+
+// publish numbers and logs a message and queues it for the session's
+// clients and the owner's user channels
+func (h *Hub) publish(msg *Message) {
+	// Read the session's numbering and owner before locking, so a busy
+	// database cannot hold up broadcasts of other sessions
+	h.events.Load(msg.SessionID)
+	h.loadOwner(msg.SessionID)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+
+	h.events.Append(msg)
+	h.metrics.Broadcasts++
+	if h.relaying {
+		h.queueRelay(msg)
+	}
+	h.fanOut(msg)
+}
+
+// queueRelay queues a copy of a message for the broker, with its data
+// marshaled now so callers reusing their data cannot change it in flight.
+// Callers hold mu.
+func (h *Hub) queueRelay(msg *Message) {
+	data, err := json.Marshal(msg.Data)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+	select {
+	case h.relay <- &Message{SessionID: msg.SessionID, Seq: msg.Seq, Event: msg.Event, Data: json.RawMessage(data)}:
+	default:
+		h.metrics.RelayDropped++
+	}
+}
+
+// deliverRemote records an event another instance published and queues it
+// for the clients here
+func (h *Hub) deliverRemote(msg *Message) {
+	decodeData(msg)
+	h.events.Load(msg.SessionID)
+	h.loadOwner(msg.SessionID)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+
+	h.events.Observe(msg)
+	h.metrics.EventsReceived++
+	h.fanOut(msg)
+}
+
+// HandleRequests sets what acts on requests other instances send for the
+// sessions this one runs. Call it before Run.
+func (h *Hub) HandleRequests(handle func(*Request)) {
+	h.requests = handle
+}
+
+// Request asks the instance running a session to act on it
+func (h *Hub) Request(ctx context.Context, req *Request) error {
+	return h.config.Broker.Request(ctx, req)
+}
+
+// handleRequest passes a request from another instance on
+func (h *Hub) handleRequest(req *Request) {
+	if h.requests == nil {
+		log.Printf("[BROKER] Ignoring %s request for session %s", req.Action, req.SessionID)
+		return
+	}
+	h.requests(req)
+}
+
+// decodeData turns the marshaled data of a relayed message back into plain
+// values, so chunks from other instances coalesce like local ones
+func decodeData(msg *Message) {
+	raw, ok := msg.Data.(json.RawMessage)
+	if !ok {
+		return
+	}
+	var data interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		log.Printf("[BROKER] Failed to decode %s of session %s: %v", msg.Event, msg.SessionID, err)
+		return
+	}
+	msg.Data = data
+}
+
+// relayEvents hands events published here to the broker until the relay
+// queue is closed by Shutdown
+func (h *Hub) relayEvents(ctx context.Context) {
+	defer close(h.done)
+
+	for msg := range h.relay {
+		if err := h.config.Broker.Publish(ctx, msg); err != nil {
+			log.Printf("[BROKER] Failed to relay %s of session %s: %v", msg.Event, msg.SessionID, err)
+			continue
+		}
+		h.mu.Lock()
+		h.metrics.EventsRelayed++
+		h.mu.Unlock()
+	}
+}
+
+// fanOut queues a numbered message for the session's clients and the
+// owner's user channels. Callers hold mu.
+func (h *Hub) fanOut(msg *Message) {
+	clients := h.sessions[msg.SessionID]
+	followers := h.followers(msg)
+	if len(clients) == 0 && len(followers) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+	frame := Frame{Seq: msg.Seq, Data: data}
+
+	for client := range followers {
+		if !clients[client] {
+			h.deliver(client, msg, frame)
+		}
+	}
+	for client := range clients {
+		h.deliver(client, msg, frame)
+	}
+}
+
+// deliver queues a frame for a client, dropping the client when it has
+// fallen too far behind. Callers hold mu.
+func (h *Hub) deliver(client *Client, msg *Message, frame Frame) {
+	switch client.enqueue(msg, frame) {
+	case enqueued:
+		h.metrics.FramesDelivered++
+	case coalesced:
+		h.metrics.FramesCoalesced++
+	case overflowed:
+		h.metrics.ClientsDropped++
+		h.remove(client, websocket.CloseTryAgainLater)
+		log.Printf("Dropped slow client from %s", client.channel())
+		return
+	}
+	if n := client.queueLen(); n > h.metrics.QueueHighWater {
+		h.metrics.QueueHighWater = n
+	}
 }
 
 // Event constants
 const (
 	EventCouncilStarted     = "council.started"
+	EventCategoryAssigned   = "category.assigned"
 	EventModelResponding    = "model.responding"
 	EventModelResponseChunk = "model.response_chunk"
 	EventModelComplete      = "model.complete"
@@ -170,5 +674,6 @@ const (
 	EventSynthesisComplete  = "synthesis.complete"
 	EventCouncilCompleted   = "council.completed"
 	EventCouncilFailed      = "council.failed"
+	EventCouncilCancelled   = "council.cancelled"
 	EventError              = "error"
 )
@@ -0,0 +1,73 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sainaif/council/internal/database"
+	"github.com/sainaif/council/internal/store"
+)
+
+func newTestHub(t *testing.T, broker Broker) *Hub {
+	t.Helper()
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return NewHub(NewEventLog(db, 1000, time.Hour), store.NewSessionStore(db), HubConfig{Broker: broker})
+}
+
+// queuedMessages returns the events waiting to be written to a client
+func queuedMessages(c *Client) []*Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var msgs []*Message
+	for _, q := range c.queue {
+		if q.msg != nil {
+			msgs = append(msgs, q.msg)
+		}
+	}
+	return msgs
+}
+
+func TestDeliverRemoteCoalescesChunks(t *testing.T) {
+	tests := []struct {
+		name string
+		data interface{}
+	}{
+		{"decoded", map[string]interface{}{"label": "Response A", "content": "x"}},
+		{"raw", json.RawMessage(`{"label":"Response A","content":"x"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := newTestHub(t, nil)
+			client, err := hub.Subscribe("s1", "u1", "test", -1)
+			if err != nil {
+				t.Fatalf("subscribe: %v", err)
+			}
+
+			const sent = coalesceAfter + 10
+			for i := 1; i <= sent; i++ {
+				hub.deliverRemote(&Message{SessionID: "s1", Seq: uint64(i), Event: EventModelResponseChunk, Data: tt.data})
+			}
+
+			if got := hub.Metrics().FramesCoalesced; got == 0 {
+				t.Fatalf("no frames coalesced")
+			}
+			queued := queuedMessages(client)
+			last := queued[len(queued)-1]
+			if last.Seq != sent {
+				t.Errorf("last queued seq = %d, want %d", last.Seq, sent)
+			}
+			content, _ := last.Data.(map[string]interface{})["content"].(string)
+			if want := sent - coalesceAfter + 1; len(content) != want {
+				t.Errorf("merged content has %d chunks, want %d", len(content), want)
+			}
+		})
+	}
+}
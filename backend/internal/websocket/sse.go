@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StreamSSE streams a subscribed client's events as Server-Sent Events, for
+// clients behind proxies that break WebSocket upgrades. Each event's id is
+// its sequence number and its data is the same JSON message sent over the
+// WebSocket, so a reconnecting EventSource resumes through Last-Event-ID.
+// Idle streams get a comment line every PingInterval so proxies keep them
+// open. The client is unsubscribed when the stream ends.
+func (h *Hub) StreamSSE(c *fiber.Ctx, client *Client) error {
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer h.Unsubscribe(client)
+
+		keepAlive := time.NewTicker(h.config.PingInterval)
+		defer keepAlive.Stop()
+
+		// Ask EventSource to reconnect quickly, then catch up
+		_, _ = fmt.Fprint(w, "retry: 1000\n\n")
+		for _, frame := range client.Replay() {
+			writeSSEFrame(w, frame)
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		for {
+			select {
+			case <-client.Pending():
+				for _, frame := range client.Drain() {
+					writeSSEFrame(w, frame)
+				}
+			case <-keepAlive.C:
+				_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+			case <-client.Done():
+				// Dropped or shutting down; EventSource reconnects and
+				// resumes from the last id it saw
+				return
+			}
+			// A failed flush means the client went away
+			if err := w.Flush(); err != nil {
+				return
+			}
+			client.Touch()
+		}
+	})
+	return nil
+}
+
+func writeSSEFrame(w *bufio.Writer, frame Frame) {
+	_, _ = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", frame.Seq, frame.Data)
+}
@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gofiber/contrib/websocket"
+
+	"github.com/sainaif/council/internal/store"
+)
+
+// lifecycleEvents are the events a user channel gets for each of the
+// user's sessions without asking for full streams
+var lifecycleEvents = map[string]bool{
+	EventCouncilStarted:   true,
+	EventCouncilCompleted: true,
+	EventCouncilFailed:    true,
+	EventCouncilCancelled: true,
+}
+
+// HandleUserConnection streams the lifecycle events of all the user's
+// sessions over one WebSocket, or every event of them with allEvents.
+// Other sessions can be added with the subscribe command.
+func (h *Hub) HandleUserConnection(c *websocket.Conn, userID string, allEvents bool, commands CommandFunc) {
+	client := newClient(c, TransportWebSocket, "", userID, c.RemoteAddr().String())
+	client.follows = userID
+	client.allEvents = allEvents
+
+	client, err := h.subscribe(client, -1)
+	switch {
+	case errors.Is(err, ErrHubClosed):
+		h.Reject(c, websocket.CloseGoingAway, closeReason(websocket.CloseGoingAway))
+		return
+	case err != nil:
+		h.Reject(c, CloseTooManyConnections, closeReason(CloseTooManyConnections))
+		return
+	}
+	h.serve(c, client, commands)
+}
+
+// followers returns the user channels of the session's owner that should
+// get the message. Callers hold mu.
+func (h *Hub) followers(msg *Message) map[*Client]bool {
+	owner := h.ownerOf(msg.SessionID)
+	if lifecycleEvents[msg.Event] && msg.Event != EventCouncilStarted {
+		// The session is over; look the owner up again if it continues
+		delete(h.owners, msg.SessionID)
+	}
+	if len(h.userClients) == 0 {
+		return nil
+	}
+
+	followers := make(map[*Client]bool)
+	for client := range h.userClients[owner] {
+		if client.allEvents || lifecycleEvents[msg.Event] {
+			followers[client] = true
+		}
+	}
+	return followers
+}
+
+// loadOwner looks up who owns a session before mu is taken, so fan-out
+// never waits on the database. The owner is remembered while the session
+// runs.
+func (h *Hub) loadOwner(sessionID string) {
+	h.mu.Lock()
+	_, ok := h.owners[sessionID]
+	h.mu.Unlock()
+	if ok {
+		return
+	}
+
+	owner, err := h.store.Owner(sessionID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("[EVENTS] Failed to look up the owner of session %s: %v", sessionID, err)
+		return
+	}
+
+	h.mu.Lock()
+	h.owners[sessionID] = owner
+	h.mu.Unlock()
+}
+
+// ownerOf returns the user a session belongs to, as found by loadOwner.
+// Callers hold mu.
+func (h *Hub) ownerOf(sessionID string) string {
+	return h.owners[sessionID]
+}
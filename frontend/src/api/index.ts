@@ -24,15 +24,22 @@ api.interceptors.response.use(
 // API modules
 export const authApi = {
   me: () => api.get('/auth/me'),
-  logout: () => api.get('/auth/logout')
+  logout: () => api.get('/auth/logout'),
+  sessions: () => api.get('/auth/sessions'),
+  revokeSession: (id: string) => api.delete(`/auth/sessions/${id}`),
+  revokeOtherSessions: () => api.delete('/auth/sessions')
 }
 
 export const councilApi = {
   start: (data: any) => api.post('/api/council/start', data),
+  search: (params: Record<string, string | number>) => api.get('/api/council/search', { params }),
   get: (id: string) => api.get(`/api/council/${id}`),
   vote: (id: string, data: any) => api.post(`/api/council/${id}/vote`, data),
   appeal: (id: string) => api.post(`/api/council/${id}/appeal`),
-  cancel: (id: string) => api.post(`/api/council/${id}/cancel`)
+  cancel: (id: string) => api.post(`/api/council/${id}/cancel`),
+  shares: (id: string) => api.get(`/api/council/${id}/shares`),
+  share: (id: string, username: string) => api.post(`/api/council/${id}/shares`, { username }),
+  unshare: (id: string, userId: string) => api.delete(`/api/council/${id}/shares/${userId}`)
 }
 
 export const modelsApi = {
@@ -47,6 +54,10 @@ export const rankingsApi = {
   headToHead: (a: string, b: string) => api.get(`/api/matchups/${a}/${b}`)
 }
 
+export const categoriesApi = {
+  list: () => api.get('/api/categories')
+}
+
 export const analyticsApi = {
   overview: () => api.get('/api/analytics/overview'),
   userBias: () => api.get('/api/analytics/user-bias'),
@@ -57,3 +68,9 @@ export const settingsApi = {
   get: () => api.get('/api/settings'),
   update: (data: any) => api.put('/api/settings', data)
 }
+
+export const tokensApi = {
+  list: () => api.get('/api/tokens'),
+  create: (data: { name: string; scopes: string[]; expires_in_days?: number }) => api.post('/api/tokens', data),
+  revoke: (id: string) => api.delete(`/api/tokens/${id}`)
+}
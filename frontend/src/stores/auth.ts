@@ -6,6 +6,7 @@ export interface User {
   user_id: string
   username: string
   avatar_url: string
+  role: 'admin' | 'member' | 'viewer'
   language: string
   ui_density: 'compact' | 'comfortable'
 }
@@ -34,6 +34,8 @@ export interface Session {
   mode: CouncilMode
   status: SessionStatus
   category_id?: number
+  category_source?: 'user' | 'keyword' | 'model' | 'override'
+  category_confidence?: number
   chairperson_id?: string
   devil_advocate_id?: string
   mystery_judge_id?: string
@@ -69,6 +71,11 @@ export const useCouncilStore = defineStore('council', () => {
   const responses = ref<Map<string, Response>>(new Map())
   const status = ref<SessionStatus>('idle')
   const ws = ref<WebSocket | null>(null)
+  // Sequence number of the last event seen, so reconnects resume from there
+  const lastSeq = ref(0)
+  // Commands sent over the socket that await their ack, by request id
+  const pendingCommands = new Map<string, { resolve: (data: any) => void; reject: (e: Error) => void }>()
+  let nextCommandId = 1
   const loading = ref(false)
   const error = ref<string | null>(null)
 
@@ -98,8 +105,9 @@ export const useCouncilStore = defineStore('council', () => {
 
       // Store the session ID
       currentSessionId.value = session_id
+      lastSeq.value = 0
 
-      // Connect to WebSocket
+      // Connect to WebSocket; events sent before it opened are replayed
       connectWebSocket(session_id)
 
       status.value = 'pending'
@@ -114,31 +122,95 @@ export const useCouncilStore = defineStore('council', () => {
 
   function connectWebSocket(sessionId: string) {
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:'
-    const wsUrl = `${protocol}//${window.location.host}/ws/council/${sessionId}`
+    const wsUrl = `${protocol}//${window.location.host}/ws/council/${sessionId}?last_seq=${lastSeq.value}`
 
-    ws.value = new WebSocket(wsUrl)
+    const socket = new WebSocket(wsUrl)
+    ws.value = socket
 
-    ws.value.onmessage = (event) => {
+    socket.onmessage = (event) => {
       const message = JSON.parse(event.data)
+      if (message.event === 'command.ack' || message.event === 'command.error') {
+        handleCommandReply(message)
+        return
+      }
+      // Skip events already seen before a reconnect
+      if (message.seq && message.seq <= lastSeq.value) return
+      if (message.seq) lastSeq.value = message.seq
       handleWebSocketMessage(message)
     }
 
-    ws.value.onclose = () => {
+    socket.onclose = (event) => {
+      failPendingCommands()
+
+      // Closed by disconnect() or replaced by a newer connection
+      if (ws.value !== socket) return
       ws.value = null
+
+      // 4401/4403/4404: not signed in, not shared with us, or no such session
+      if (event.code >= 4400 && event.code < 4500) {
+        error.value = event.reason || 'Not allowed to watch this session'
+        return
+      }
+
+      // Resume from the last event while the council is still running
+      if (currentSessionId.value === sessionId && isRunning()) {
+        setTimeout(() => {
+          if (!ws.value && currentSessionId.value === sessionId) connectWebSocket(sessionId)
+        }, 1000)
+      }
     }
 
-    ws.value.onerror = (e) => {
+    socket.onerror = (e) => {
       console.error('WebSocket error:', e)
       error.value = 'Connection error'
     }
   }
 
+  // Sends a command over the socket and resolves with its ack data
+  function sendCommand(type: string, data?: any, sessionId?: string): Promise<any> {
+    const socket = ws.value
+    if (!socket || socket.readyState !== WebSocket.OPEN) {
+      return Promise.reject(new Error('Not connected'))
+    }
+    const id = String(nextCommandId++)
+    return new Promise((resolve, reject) => {
+      pendingCommands.set(id, { resolve, reject })
+      socket.send(JSON.stringify({ id, type, session_id: sessionId, data }))
+    })
+  }
+
+  function handleCommandReply(message: { id: string; event: string; data: any }) {
+    const pending = pendingCommands.get(message.id)
+    if (!pending) return
+    pendingCommands.delete(message.id)
+    if (message.event === 'command.ack') {
+      pending.resolve(message.data)
+    } else {
+      pending.reject(new Error(message.data?.message || 'Command failed'))
+    }
+  }
+
+  function failPendingCommands() {
+    for (const pending of pendingCommands.values()) {
+      pending.reject(new Error('Connection closed'))
+    }
+    pendingCommands.clear()
+  }
+
   function handleWebSocketMessage(message: { event: string; data: any }) {
     switch (message.event) {
       case 'council.started':
         status.value = 'responding'
         break
 
+      case 'category.assigned':
+        if (currentSession.value) {
+          currentSession.value.category_id = message.data.category_id
+          currentSession.value.category_source = message.data.source
+          currentSession.value.category_confidence = message.data.confidence
+        }
+        break
+
       case 'model.responding':
         const { model_id, label } = message.data
         responses.value.set(label, {
@@ -234,6 +306,11 @@ export const useCouncilStore = defineStore('council', () => {
     if (!currentSession.value) return
 
     try {
+      // Prefer the open socket, fall back to REST
+      if (ws.value?.readyState === WebSocket.OPEN && currentSessionId.value === currentSession.value.id) {
+        await sendCommand('vote', { ranked_responses: rankedResponses })
+        return
+      }
       await api.post(`/api/council/${currentSession.value.id}/vote`, {
         ranked_responses: rankedResponses
       })
@@ -242,16 +319,22 @@ export const useCouncilStore = defineStore('council', () => {
     }
   }
 
+  function isRunning() {
+    return !['idle', 'completed', 'failed', 'cancelled'].includes(status.value)
+  }
+
   function disconnect() {
     if (ws.value) {
-      ws.value.close()
+      const socket = ws.value
       ws.value = null
+      socket.close()
     }
   }
 
   function reset() {
     currentSession.value = null
     currentSessionId.value = null
+    lastSeq.value = 0
     responses.value.clear()
     status.value = 'idle'
     error.value = null
@@ -270,6 +353,7 @@ export const useCouncilStore = defineStore('council', () => {
     startSession,
     fetchSession,
     submitVote,
+    sendCommand,
     disconnect,
     reset
   }